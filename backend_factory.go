@@ -4,6 +4,8 @@ import (
 	"context"
 
 	amqp "github.com/devopsfaith/krakend-amqp"
+	"github.com/devopsfaith/krakend-ce/ext/introspect"
+	"github.com/devopsfaith/krakend-ce/ext/lint"
 	cel "github.com/devopsfaith/krakend-cel"
 	cb "github.com/devopsfaith/krakend-circuitbreaker/gobreaker/proxy"
 	httpcache "github.com/devopsfaith/krakend-httpcache"
@@ -41,6 +43,11 @@ func NewBackendFactory(logger logging.Logger, metricCollector *metrics.Metrics)
 
 // NewBackendFactory creates a BackendFactory by stacking all the available middlewares and injecting the received context
 func NewBackendFactoryWithContext(ctx context.Context, logger logging.Logger, metricCollector *metrics.Metrics) proxy.BackendFactory {
+	return defaultStacks().NewBackendFactory(ctx, logger, metricCollector)
+}
+
+// NewBackendFactory returns a BackendFactory wrapping the martian one with the backend stack
+func (s *MiddlewareStacks) NewBackendFactory(ctx context.Context, logger logging.Logger, metricCollector *metrics.Metrics) proxy.BackendFactory {
 	requestExecutorFactory := func(cfg *config.Backend) client.HTTPRequestExecutor {
 		var clientFactory client.HTTPClientFactory
 		if _, ok := cfg.ExtraConfig[oauth2client.Namespace]; ok {
@@ -52,23 +59,91 @@ func NewBackendFactoryWithContext(ctx context.Context, logger logging.Logger, me
 		return opencensus.HTTPRequestExecutor(clientFactory)
 	}
 	requestExecutorFactory = httprequestexecutor.HTTPRequestExecutor(logger, requestExecutorFactory)
+
+	deps := MiddlewareDeps{Context: ctx, Logger: logger, Metrics: metricCollector}
 	backendFactory := martian.NewConfiguredBackendFactory(logger, requestExecutorFactory)
-	bf := pubsub.NewBackendFactory(ctx, logger, backendFactory)
-	backendFactory = bf.New
-	backendFactory = amqp.NewBackendFactory(ctx, logger, backendFactory)
-	backendFactory = lambda.BackendFactory(backendFactory)
-	backendFactory = cel.BackendFactory(logger, backendFactory)
-	backendFactory = lua.BackendFactory(logger, backendFactory)
-	backendFactory = juju.BackendFactory(backendFactory)
-	backendFactory = cb.BackendFactory(backendFactory, logger)
-	backendFactory = metricCollector.BackendFactory("backend", backendFactory)
-	backendFactory = opencensus.BackendFactory(backendFactory)
-	backendFactory = newrelic.BackendFactory("backend", backendFactory)
+	for i := len(s.backend) - 1; i >= 0; i-- {
+		backendFactory = s.backend[i].Wrap(deps, backendFactory)
+	}
 	return backendFactory
 }
 
-type backendFactory struct{}
+// backendMiddlewares are the bundled backend middlewares in their default order, outermost first
+var backendMiddlewares = []BackendMiddleware{
+	{
+		Middleware: Middleware{Name: "newrelic", Namespaces: []string{"github_com/letgoapp/krakend-newrelic"}, Scope: lint.ScopeService, Priority: 100},
+		Wrap: func(_ MiddlewareDeps, next proxy.BackendFactory) proxy.BackendFactory {
+			return newrelic.BackendFactory("backend", next)
+		},
+	},
+	{
+		Middleware: Middleware{Name: "opencensus", Namespaces: []string{"github_com/devopsfaith/krakend-opencensus"}, Scope: lint.ScopeService, Priority: 200},
+		Wrap: func(_ MiddlewareDeps, next proxy.BackendFactory) proxy.BackendFactory {
+			return opencensus.BackendFactory(next)
+		},
+	},
+	{
+		Middleware: Middleware{Name: "metrics", Namespaces: []string{"github_com/devopsfaith/krakend-metrics"}, Scope: lint.ScopeService, Priority: 300},
+		Wrap: func(d MiddlewareDeps, next proxy.BackendFactory) proxy.BackendFactory {
+			return d.Metrics.BackendFactory("backend", next)
+		},
+	},
+	{
+		Middleware: Middleware{Name: "circuitbreaker", Namespaces: []string{"github.com/devopsfaith/krakend-circuitbreaker/gobreaker"}, Priority: 400},
+		Wrap: func(d MiddlewareDeps, next proxy.BackendFactory) proxy.BackendFactory {
+			return cb.BackendFactory(next, d.Logger)
+		},
+	},
+	{
+		Middleware: Middleware{Name: "ratelimit", Namespaces: []string{"github.com/devopsfaith/krakend-ratelimit/juju/proxy"}, Priority: 500},
+		Wrap: func(_ MiddlewareDeps, next proxy.BackendFactory) proxy.BackendFactory {
+			return juju.BackendFactory(next)
+		},
+	},
+	{
+		Middleware: Middleware{Name: "lua", Namespaces: []string{"github.com/devopsfaith/krakend-lua/proxy/backend"}, Priority: 600},
+		Wrap: func(d MiddlewareDeps, next proxy.BackendFactory) proxy.BackendFactory {
+			return lua.BackendFactory(d.Logger, next)
+		},
+	},
+	{
+		Middleware: Middleware{Name: "cel", Namespaces: []string{"github.com/devopsfaith/krakend-cel"}, Priority: 700},
+		Wrap: func(d MiddlewareDeps, next proxy.BackendFactory) proxy.BackendFactory {
+			return cel.BackendFactory(d.Logger, next)
+		},
+	},
+	{
+		Middleware: Middleware{Name: "lambda", Namespaces: []string{"github.com/devopsfaith/krakend-lambda"}, Priority: 800},
+		Wrap: func(_ MiddlewareDeps, next proxy.BackendFactory) proxy.BackendFactory {
+			return lambda.BackendFactory(next)
+		},
+	},
+	{
+		Middleware: Middleware{Name: "amqp", Namespaces: []string{
+			"github.com/devopsfaith/krakend-amqp/consume",
+			"github.com/devopsfaith/krakend-amqp/produce",
+		}, Priority: 900},
+		Wrap: func(d MiddlewareDeps, next proxy.BackendFactory) proxy.BackendFactory {
+			return amqp.NewBackendFactory(d.Context, d.Logger, next)
+		},
+	},
+	{
+		Middleware: Middleware{Name: "pubsub", Namespaces: []string{
+			"github.com/devopsfaith/krakend-pubsub/subscriber",
+			"github.com/devopsfaith/krakend-pubsub/publisher",
+		}, Priority: 1000},
+		Wrap: func(d MiddlewareDeps, next proxy.BackendFactory) proxy.BackendFactory {
+			bf := pubsub.NewBackendFactory(d.Context, d.Logger, next)
+			return bf.New
+		},
+	},
+}
 
-func (b backendFactory) NewBackendFactory(ctx context.Context, l logging.Logger, m *metrics.Metrics) proxy.BackendFactory {
-	return NewBackendFactoryWithContext(ctx, l, m)
+// backendBase describes the backend proxy wrapped by the backend stack
+var backendBase = introspect.Stack{
+	{Name: "martian", Namespaces: []string{"github.com/devopsfaith/krakend-martian"}},
+	{Name: "http executor plugin", Namespaces: []string{"github.com/devopsfaith/krakend/transport/http/client/executor"}},
+	{Name: "oauth2 client credentials", Namespaces: []string{oauth2client.Namespace}},
+	{Name: "httpcache", Namespaces: []string{"github.com/devopsfaith/krakend-httpcache"}},
+	{Name: "http client"},
 }
@@ -0,0 +1,104 @@
+package krakend
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	cache "github.com/devopsfaith/krakend-ce/ext/cache"
+	metrics "github.com/devopsfaith/krakend-metrics/gin"
+	gometrics "github.com/rcrowley/go-metrics"
+	"go.opencensus.io/stats"
+	"go.opencensus.io/stats/view"
+	"go.opencensus.io/tag"
+)
+
+const cacheStatsInterval = 10 * time.Second
+
+var (
+	cacheModuleKey   = tag.MustNewKey("krakend.io/cache/module")
+	cacheEndpointKey = tag.MustNewKey("krakend.io/cache/endpoint")
+
+	cacheHits        = stats.Int64("krakend.io/cache/hits", "Number of ext cache hits", stats.UnitDimensionless)
+	cacheMisses      = stats.Int64("krakend.io/cache/misses", "Number of ext cache misses", stats.UnitDimensionless)
+	cacheSets        = stats.Int64("krakend.io/cache/sets", "Number of ext cache writes", stats.UnitDimensionless)
+	cacheEvictions   = stats.Int64("krakend.io/cache/evictions", "Number of ext cache evictions", stats.UnitDimensionless)
+	cacheExpirations = stats.Int64("krakend.io/cache/expirations", "Number of expired ext cache entries", stats.UnitDimensionless)
+	cacheSize        = stats.Int64("krakend.io/cache/size", "Number of ext cache entries", stats.UnitDimensionless)
+
+	// CacheOpenCensusViews are the OpenCensus views exposing the counters of the ext module caches
+	CacheOpenCensusViews = []*view.View{
+		cacheView(cacheHits, view.Sum()),
+		cacheView(cacheMisses, view.Sum()),
+		cacheView(cacheSets, view.Sum()),
+		cacheView(cacheEvictions, view.Sum()),
+		cacheView(cacheExpirations, view.Sum()),
+		cacheView(cacheSize, view.LastValue()),
+	}
+)
+
+func cacheView(m *stats.Int64Measure, agg *view.Aggregation) *view.View {
+	return &view.View{
+		Name:        m.Name(),
+		Description: m.Description(),
+		Measure:     m,
+		Aggregation: agg,
+		TagKeys:     []tag.Key{cacheModuleKey, cacheEndpointKey},
+	}
+}
+
+// publishCacheStats periodically copies the counters of the registered ext caches into the metrics
+// collector registry and records their increments as OpenCensus measurements
+func publishCacheStats(ctx context.Context, metricCollector *metrics.Metrics, interval time.Duration) {
+	var registry gometrics.Registry
+	if metricCollector != nil && metricCollector.Registry != nil {
+		registry = *metricCollector.Registry
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	last := map[*cache.Entry]cache.Stats{}
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		current := map[*cache.Entry]cache.Stats{}
+		for _, e := range cache.Registered() {
+			s, ok := e.Stats()
+			if !ok {
+				continue
+			}
+			current[e] = s
+			prev := last[e]
+
+			if registry != nil {
+				prefix := fmt.Sprintf("cache.%s.%s.", e.Module, e.Endpoint)
+				gometrics.GetOrRegisterGauge(prefix+"hits", registry).Update(int64(s.Hits))
+				gometrics.GetOrRegisterGauge(prefix+"misses", registry).Update(int64(s.Misses))
+				gometrics.GetOrRegisterGauge(prefix+"sets", registry).Update(int64(s.Sets))
+				gometrics.GetOrRegisterGauge(prefix+"evictions", registry).Update(int64(s.Evictions))
+				gometrics.GetOrRegisterGauge(prefix+"expirations", registry).Update(int64(s.Expirations))
+				gometrics.GetOrRegisterGauge(prefix+"size", registry).Update(int64(s.Size))
+			}
+
+			tctx, err := tag.New(ctx, tag.Upsert(cacheModuleKey, e.Module), tag.Upsert(cacheEndpointKey, e.Endpoint))
+			if err != nil {
+				continue
+			}
+			stats.Record(
+				tctx,
+				cacheHits.M(int64(s.Hits-prev.Hits)),
+				cacheMisses.M(int64(s.Misses-prev.Misses)),
+				cacheSets.M(int64(s.Sets-prev.Sets)),
+				cacheEvictions.M(int64(s.Evictions-prev.Evictions)),
+				cacheExpirations.M(int64(s.Expirations-prev.Expirations)),
+				cacheSize.M(int64(s.Size)),
+			)
+		}
+		last = current
+	}
+}
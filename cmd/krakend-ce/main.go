@@ -10,6 +10,7 @@ import (
 	"syscall"
 
 	krakend "github.com/devopsfaith/krakend-ce"
+	"github.com/devopsfaith/krakend-ce/ext/shutdown"
 	"github.com/devopsfaith/krakend-cobra"
 	flexibleconfig "github.com/devopsfaith/krakend-flexibleconfig"
 	"github.com/devopsfaith/krakend-viper"
@@ -22,25 +23,59 @@ const (
 	fcSettings  = "FC_SETTINGS"
 	fcPath      = "FC_OUT"
 	fcEnable    = "FC_ENABLE"
+
+	hotReloadEnable = "HOT_RELOAD_ENABLE"
+
+	lintFlag  = "--lint"
+	schemaCmd = "schema"
 )
 
 func main() {
+	// krakend schema > krakend.schema.json
+	if len(os.Args) > 1 && os.Args[1] == schemaCmd {
+		if err := krakend.WriteConfigSchema(os.Stdout); err != nil {
+			log.Fatal("ERROR:", err.Error())
+		}
+		return
+	}
+
 	sigs := make(chan os.Signal, 1)
 	signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
+	// the first signal starts the graceful shutdown, the second one stops the gateway right away
+	sequence := shutdown.New()
 	go func() {
-		select {
-		case sig := <-sigs:
-			log.Println("Signal intercepted:", sig)
-			cancel()
-		case <-ctx.Done():
+		for i := 0; ; i++ {
+			select {
+			case sig := <-sigs:
+				log.Println("Signal intercepted:", sig)
+				if i == 0 {
+					sequence.Stop()
+					continue
+				}
+				cancel()
+			case <-ctx.Done():
+			}
+			return
 		}
 	}()
 
 	krakend.RegisterEncoders()
 
+	// --lint turns the config check strict, e.g. krakend check --lint -c krakend.json
+	strict := false
+	args := os.Args[:1]
+	for _, arg := range os.Args[1:] {
+		if arg == lintFlag {
+			strict = true
+			continue
+		}
+		args = append(args, arg)
+	}
+	os.Args = args
+
 	var cfg config.Parser
 	cfg = viper.New()
 	if os.Getenv(fcEnable) != "" {
@@ -53,5 +88,18 @@ func main() {
 		})
 	}
 
-	cmd.Execute(cfg, krakend.NewExecutor(ctx))
+	cfg = krakend.NewLintParser(cfg, strict)
+
+	eb := &krakend.ExecutorBuilder{Shutdown: sequence}
+	// the hot reload watches the config files and listens to SIGHUP, e.g. HOT_RELOAD_ENABLE=1
+	if os.Getenv(hotReloadEnable) != "" {
+		parser := krakend.NewReloadableParser(cfg, os.Getenv(fcPartials), os.Getenv(fcTemplates), os.Getenv(fcSettings))
+		eb.ConfigReloader = parser
+		cfg = parser
+	}
+
+	cmd.Execute(cfg, eb.NewCmdExecutor(ctx))
+
+	cancel()
+	os.Exit(sequence.ExitCode())
 }
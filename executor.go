@@ -8,6 +8,12 @@ import (
 	"os"
 
 	krakendbf "github.com/devopsfaith/bloomfilter/krakend"
+	cacheadmin "github.com/devopsfaith/krakend-ce/ext/cacheadmin"
+	"github.com/devopsfaith/krakend-ce/ext/certs"
+	"github.com/devopsfaith/krakend-ce/ext/health"
+	"github.com/devopsfaith/krakend-ce/ext/listener"
+	service "github.com/devopsfaith/krakend-ce/ext/service"
+	"github.com/devopsfaith/krakend-ce/ext/shutdown"
 	cel "github.com/devopsfaith/krakend-cel"
 	cmd "github.com/devopsfaith/krakend-cobra"
 	cors "github.com/devopsfaith/krakend-cors/gin"
@@ -91,6 +97,12 @@ type HandlerFactory interface {
 	NewHandlerFactory(logging.Logger, *metrics.Metrics, jose.RejecterFactory) router.HandlerFactory
 }
 
+// HandlerFactoryWithContext is a HandlerFactory receiving the context of the router. The background tasks
+// started by its middlewares are stopped when the context is cancelled, after a config reload included
+type HandlerFactoryWithContext interface {
+	NewHandlerFactoryWithContext(context.Context, logging.Logger, *metrics.Metrics, jose.RejecterFactory) router.HandlerFactory
+}
+
 // LoggerFactory returns a KrakenD Logger factory, ready to be passed to the KrakenD RouterFactory
 type LoggerFactory interface {
 	NewLogger(config.ServiceConfig) (logging.Logger, io.Writer, error)
@@ -104,6 +116,15 @@ type RunServerFactory interface {
 	NewRunServer(logging.Logger, router.RunServerFunc) RunServer
 }
 
+// ServerRunServerFactory is a RunServerFactory also wrapping the RunServer starting the listeners.
+// That one is built once and keeps serving across the config reloads, while the NewRunServer wraps
+// are built again with every router. The RunServerFactory implementations not offering it get the
+// one of the DefaultRunServerFactory.
+type ServerRunServerFactory interface {
+	RunServerFactory
+	NewServerRunServer(logging.Logger, router.RunServerFunc) RunServer
+}
+
 // ExecutorBuilder is a composable builder. Every injected property is used by the NewCmdExecutor method.
 type ExecutorBuilder struct {
 	LoggerFactory               LoggerFactory
@@ -117,13 +138,28 @@ type ExecutorBuilder struct {
 	HandlerFactory              HandlerFactory
 	RunServerFactory            RunServerFactory
 
+	// ConfigReloader enables the configuration hot reload when set. The router is built again
+	// on every change while the server keeps listening. The logging, plugins, metrics, traces,
+	// token revocation and service discovery setup is done once and requires a restart.
+	ConfigReloader ConfigReloader
+
+	// Shutdown enables the graceful shutdown sequence when set. Its exit code is the one the
+	// process should report once the executor returns.
+	Shutdown *shutdown.Sequence
+
+	// MiddlewareRegistry holds the middlewares placed in the router engine, handler, proxy and
+	// backend stacks as declared in the service config. The stacks replace every nil
+	// EngineFactory, HandlerFactory, ProxyFactory and BackendFactory.
+	MiddlewareRegistry *MiddlewareRegistry
+
 	Middlewares []gin.HandlerFunc
 }
 
 // NewCmdExecutor returns an executor for the cmd package. The executor initalizes the entire gateway by
 // delegating most of the tasks to the injected collaborators. They register the components and
 // compose a RouterFactory wrapping all the middlewares.
-// Every nil collaborator is replaced by the default one offered by this package.
+// Every nil collaborator is replaced by the default one offered by this package, the router
+// factories by the stacks of the middleware registry.
 func (e *ExecutorBuilder) NewCmdExecutor(ctx context.Context) cmd.Executor {
 	e.checkCollaborators()
 
@@ -133,8 +169,30 @@ func (e *ExecutorBuilder) NewCmdExecutor(ctx context.Context) cmd.Executor {
 			return
 		}
 
+		if !lintConfig(cfg, e.MiddlewareRegistry, logger) {
+			return
+		}
+
+		if _, err := e.MiddlewareRegistry.Stacks(cfg); err != nil {
+			logger.Critical("middlewares: invalid configuration, refusing to start:", err.Error())
+			return
+		}
+
+		// the components are released once the server is done when shutting down gracefully
+		ctx := ctx
+		runServer := e.serverRunServer(logger)
+		if e.Shutdown != nil {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithCancel(ctx)
+			defer e.Shutdown.Flush(logger, cancel, closeWriter(gelfWriter))
+
+			runServer = e.Shutdown.RunServer(logger, runServer)
+		}
+
 		logger.Info("Listening on port:", cfg.Port)
 
+		health.Serve(ctx, cfg, logger)
+
 		startReporter(ctx, logger, cfg)
 
 		if cfg.Plugin != nil {
@@ -153,22 +211,42 @@ func (e *ExecutorBuilder) NewCmdExecutor(ctx context.Context) cmd.Executor {
 			logger.Warning("bloomFilter:", err.Error())
 		}
 
+		if err := cacheadmin.Subscribe(ctx, cfg.ExtraConfig, logger); err != nil {
+			logger.Warning("cache invalidation:", err.Error())
+		}
+
 		// setup the krakend router
-		routerFactory := router.NewFactory(router.Config{
-			Engine: e.EngineFactory.NewEngine(cfg, logger, gelfWriter),
-			ProxyFactory: e.ProxyFactory.NewProxyFactory(
-				logger,
-				e.BackendFactory.NewBackendFactory(ctx, logger, metricCollector),
-				metricCollector,
-			),
-			Middlewares:    e.Middlewares,
-			Logger:         logger,
-			HandlerFactory: e.HandlerFactory.NewHandlerFactory(logger, metricCollector, tokenRejecterFactory),
-			RunServer:      router.RunServerFunc(e.RunServerFactory.NewRunServer(logger, krakendrouter.RunServer)),
-		})
+		buildRouter := func(ctx context.Context, cfg config.ServiceConfig, runServer router.RunServerFunc) {
+			stacks, err := e.MiddlewareRegistry.Stacks(cfg)
+			if err != nil {
+				logger.Error("middlewares:", err.Error())
+				return
+			}
+			engineFactory, handlerFactory, proxyFactory, backendFactory := e.routerFactories(stacks)
+
+			routerFactory := router.NewFactory(router.Config{
+				Engine: engineFactory.NewEngine(cfg, logger, gelfWriter),
+				ProxyFactory: proxyFactory.NewProxyFactory(
+					logger,
+					backendFactory.NewBackendFactory(ctx, logger, metricCollector),
+					metricCollector,
+				),
+				Middlewares:    e.Middlewares,
+				Logger:         logger,
+				HandlerFactory: newHandlerFactory(ctx, handlerFactory, logger, metricCollector, tokenRejecterFactory),
+				RunServer:      router.RunServerFunc(e.RunServerFactory.NewRunServer(logger, runServer)),
+			})
+
+			// start the engines
+			routerFactory.NewWithContext(ctx).Run(cfg)
+		}
 
-		// start the engines
-		routerFactory.NewWithContext(ctx).Run(cfg)
+		if e.ConfigReloader == nil {
+			buildRouter(ctx, cfg, runServer)
+			return
+		}
+
+		newHotReloader(logger, e.ConfigReloader, e.MiddlewareRegistry, buildRouter, runServer).Run(ctx, cfg)
 	}
 }
 
@@ -185,17 +263,8 @@ func (e *ExecutorBuilder) checkCollaborators() {
 	if e.MetricsAndTracesRegister == nil {
 		e.MetricsAndTracesRegister = new(MetricsAndTraces)
 	}
-	if e.EngineFactory == nil {
-		e.EngineFactory = new(engineFactory)
-	}
-	if e.ProxyFactory == nil {
-		e.ProxyFactory = new(proxyFactory)
-	}
-	if e.BackendFactory == nil {
-		e.BackendFactory = new(backendFactory)
-	}
-	if e.HandlerFactory == nil {
-		e.HandlerFactory = new(handlerFactory)
+	if e.MiddlewareRegistry == nil {
+		e.MiddlewareRegistry = DefaultMiddlewareRegistry()
 	}
 	if e.LoggerFactory == nil {
 		e.LoggerFactory = new(LoggerBuilder)
@@ -205,8 +274,50 @@ func (e *ExecutorBuilder) checkCollaborators() {
 	}
 }
 
+// routerFactories returns the injected router factories, the nil ones replaced by the middleware stacks
+func (e *ExecutorBuilder) routerFactories(s *MiddlewareStacks) (EngineFactory, HandlerFactory, ProxyFactory, BackendFactory) {
+	var (
+		engineFactory  EngineFactory  = s
+		handlerFactory HandlerFactory = s
+		proxyFactory   ProxyFactory   = s
+		backendFactory BackendFactory = s
+	)
+	if e.EngineFactory != nil {
+		engineFactory = e.EngineFactory
+	}
+	if e.HandlerFactory != nil {
+		handlerFactory = e.HandlerFactory
+	}
+	if e.ProxyFactory != nil {
+		proxyFactory = e.ProxyFactory
+	}
+	if e.BackendFactory != nil {
+		backendFactory = e.BackendFactory
+	}
+	return engineFactory, handlerFactory, proxyFactory, backendFactory
+}
+
+// serverRunServer returns the RunServer starting the listeners, the one of the DefaultRunServerFactory
+// when the injected factory does not offer it
+func (e *ExecutorBuilder) serverRunServer(l logging.Logger) router.RunServerFunc {
+	f, ok := e.RunServerFactory.(ServerRunServerFactory)
+	if !ok {
+		f = new(DefaultRunServerFactory)
+	}
+	return router.RunServerFunc(f.NewServerRunServer(l, krakendrouter.RunServer))
+}
+
+// newHandlerFactory passes the context to the handler factories implementing HandlerFactoryWithContext
+func newHandlerFactory(ctx context.Context, f HandlerFactory, l logging.Logger, m *metrics.Metrics, r jose.RejecterFactory) router.HandlerFactory {
+	if hf, ok := f.(HandlerFactoryWithContext); ok {
+		return hf.NewHandlerFactoryWithContext(ctx, l, m, r)
+	}
+	return f.NewHandlerFactory(l, m, r)
+}
+
 // DefaultRunServerFactory creates the default RunServer by wrapping the injected RunServer
-// with the plugin loader and the CORS module
+// with the plugin loader and the CORS module. Its server RunServer serves the health probes,
+// the extra listeners and the certificates selected by the SNI host, reloaded from disk.
 type DefaultRunServerFactory struct{}
 
 func (d *DefaultRunServerFactory) NewRunServer(l logging.Logger, next router.RunServerFunc) RunServer {
@@ -216,6 +327,11 @@ func (d *DefaultRunServerFactory) NewRunServer(l logging.Logger, next router.Run
 	))
 }
 
+// NewServerRunServer wraps the injected RunServer with the health, listeners and certs modules
+func (d *DefaultRunServerFactory) NewServerRunServer(l logging.Logger, next router.RunServerFunc) RunServer {
+	return RunServer(health.RunServer(l, listener.RunServer(l, certs.RunServer(l, next))))
+}
+
 // LoggerBuilder is the default BuilderFactory implementation.
 type LoggerBuilder struct{}
 
@@ -259,8 +375,12 @@ type BloomFilterJWT struct{}
 
 // NewTokenRejecter registers the bloomfilter component and links it to a token rejecter. Then it returns a chained
 // rejecter factory with the created token rejecter and other based on the CEL component.
+// A configured bloomfilter failing to load fails the readiness checks.
 func (t BloomFilterJWT) NewTokenRejecter(ctx context.Context, cfg config.ServiceConfig, l logging.Logger, reg func(n string, p int)) (jose.ChainedRejecterFactory, error) {
 	rejecter, err := krakendbf.Register(ctx, "krakend-bf", cfg, l, reg)
+	if _, ok := cfg.ExtraConfig[krakendbf.Namespace]; ok && err != nil {
+		health.RegisterCheck(ctx, "bloomfilter", func(context.Context) error { return err })
+	}
 
 	return jose.ChainedRejecterFactory([]jose.RejecterFactory{
 		jose.RejecterFactoryFunc(func(_ logging.Logger, _ *config.EndpointConfig) jose.Rejecter {
@@ -279,6 +399,8 @@ func (t BloomFilterJWT) NewTokenRejecter(ctx context.Context, cfg config.Service
 type MetricsAndTraces struct{}
 
 // Register registers the metrcis, influx and opencensus packages as required by the given configuration.
+// It also registers the ext service call views and starts publishing the counters of the ext module caches
+// and the expiry of the served certificates.
 func (MetricsAndTraces) Register(ctx context.Context, cfg config.ServiceConfig, l logging.Logger) *metrics.Metrics {
 	metricCollector := metrics.New(ctx, cfg.ExtraConfig, l)
 
@@ -286,10 +408,16 @@ func (MetricsAndTraces) Register(ctx context.Context, cfg config.ServiceConfig,
 		l.Warning(err.Error())
 	}
 
-	if err := opencensus.Register(ctx, cfg, append(opencensus.DefaultViews, pubsub.OpenCensusViews...)...); err != nil {
+	views := append(opencensus.DefaultViews, pubsub.OpenCensusViews...)
+	views = append(views, service.OpenCensusViews...)
+	views = append(views, CertificateOpenCensusViews...)
+	if err := opencensus.Register(ctx, cfg, append(views, CacheOpenCensusViews...)...); err != nil {
 		l.Warning("opencensus:", err.Error())
 	}
 
+	go publishCacheStats(ctx, metricCollector, cacheStatsInterval)
+	go publishCertificateExpiry(ctx, metricCollector, certificateStatsInterval)
+
 	return metricCollector
 }
 
@@ -325,3 +453,12 @@ func startReporter(ctx context.Context, logger logging.Logger, cfg config.Servic
 type gelfWriterWrapper struct {
 	io.Writer
 }
+
+// closeWriter returns a shutdown hook closing the writer, so the buffered log lines are sent
+func closeWriter(w io.Writer) func() {
+	return func() {
+		if c, ok := w.(io.Closer); ok {
+			c.Close()
+		}
+	}
+}
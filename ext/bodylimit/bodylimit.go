@@ -0,0 +1,61 @@
+package bodylimit
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/devopsfaith/krakend-ce/ext/reqctx"
+	"github.com/devopsfaith/krakend/config"
+	"github.com/devopsfaith/krakend/logging"
+	"github.com/gin-gonic/gin"
+)
+
+//Register add the body size limit middleware to the engine. The gateway wide max_body_size applies
+//to every endpoint not declaring its own. Accepted bodies are buffered once in the request context,
+//where the ext modules read them from, and larger ones are rejected with a 413
+func Register(cfg config.ServiceConfig, l logging.Logger, engine *gin.Engine) {
+	defaultSize, _ := maxBodySize(cfg.ExtraConfig)
+
+	limits := map[string]int64{}
+	for _, e := range cfg.Endpoints {
+		if size, ok := maxBodySize(e.ExtraConfig); ok {
+			limits[routeKey(e.Method, e.Endpoint)] = size
+		}
+	}
+
+	if defaultSize <= 0 && len(limits) == 0 {
+		return
+	}
+
+	l.Debug("[BodyLimit] Request body size limit enabled")
+	engine.Use(handler(defaultSize, limits))
+}
+
+func handler(defaultSize int64, limits map[string]int64) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		size, ok := limits[routeKey(c.Request.Method, c.FullPath())]
+		if !ok {
+			size = defaultSize
+		}
+		if size <= 0 {
+			return
+		}
+
+		reqctx.FromGin(c)
+
+		if _, err := reqctx.ReadBody(c.Request, size); err != nil {
+			status := http.StatusBadRequest
+			if err == reqctx.ErrBodyTooLarge {
+				status = http.StatusRequestEntityTooLarge
+			}
+			c.AbortWithStatusJSON(status, map[string]interface{}{"error": err.Error()})
+		}
+	}
+}
+
+func routeKey(method, path string) string {
+	if method == "" {
+		method = http.MethodGet
+	}
+	return strings.ToUpper(method) + " " + path
+}
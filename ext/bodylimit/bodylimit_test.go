@@ -0,0 +1,89 @@
+package bodylimit
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/devopsfaith/krakend-ce/ext/reqctx"
+	"github.com/devopsfaith/krakend/config"
+	"github.com/devopsfaith/krakend/logging"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func newEngine(cfg config.ServiceConfig) *gin.Engine {
+	logger, _ := logging.NewLogger("CRITICAL", ioutil.Discard, "")
+	engine := gin.New()
+	Register(cfg, logger, engine)
+
+	echo := func(c *gin.Context) {
+		raw, err := reqctx.Body(c.Request)
+		if err != nil {
+			c.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+		c.Data(http.StatusOK, "text/plain", raw)
+	}
+	engine.POST("/default", echo)
+	engine.POST("/upload/:id", echo)
+	engine.POST("/unlimited", echo)
+	return engine
+}
+
+func post(engine *gin.Engine, path, body string, chunked bool) *httptest.ResponseRecorder {
+	req, _ := http.NewRequest("POST", "http://localhost:8000"+path, bytes.NewReader([]byte(body)))
+	if chunked {
+		req.ContentLength = -1
+	}
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, req)
+	return w
+}
+
+func TestBodyLimit(t *testing.T) {
+	engine := newEngine(config.ServiceConfig{
+		ExtraConfig: config.ExtraConfig{
+			namespace: map[string]interface{}{"max_body_size": 10},
+		},
+		Endpoints: []*config.EndpointConfig{
+			{
+				Endpoint:    "/upload/:id",
+				Method:      "post",
+				ExtraConfig: config.ExtraConfig{namespace: map[string]interface{}{"max_body_size": "20"}},
+			},
+			{
+				Endpoint:    "/unlimited",
+				Method:      "POST",
+				ExtraConfig: config.ExtraConfig{namespace: map[string]interface{}{"max_body_size": 0}},
+			},
+		},
+	})
+
+	w := post(engine, "/default", "0123456789", false)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "0123456789", w.Body.String())
+
+	for _, chunked := range []bool{false, true} {
+		w = post(engine, "/default", "0123456789a", chunked)
+		assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+	}
+
+	w = post(engine, "/upload/1", strings.Repeat("a", 20), true)
+	assert.Equal(t, http.StatusOK, w.Code)
+	w = post(engine, "/upload/1", strings.Repeat("a", 21), true)
+	assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+
+	w = post(engine, "/unlimited", strings.Repeat("a", 100), false)
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestBodyLimitDisabled(t *testing.T) {
+	engine := newEngine(config.ServiceConfig{})
+
+	w := post(engine, "/default", strings.Repeat("a", 100), false)
+	assert.Equal(t, http.StatusOK, w.Code)
+}
@@ -0,0 +1,43 @@
+package bodylimit
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/devopsfaith/krakend-ce/ext/lint"
+	"github.com/devopsfaith/krakend/config"
+)
+
+const namespace = "github_com/sahalzain/krakend-bodylimit"
+
+//Linter strict validation of the config block, the service block sets the default limit
+var Linter = lint.Linter{
+	Namespace: namespace,
+	Scope:     lint.ScopeService | lint.ScopeEndpoint,
+	Fields: []lint.Field{
+		{Name: "max_body_size", Kind: lint.KindInt, Required: true, Check: lint.Min(0)},
+	},
+}
+
+//maxBodySize read the max_body_size in bytes from the extra config, 0 disables the limit
+func maxBodySize(cfg config.ExtraConfig) (int64, bool) {
+	v, ok := cfg[namespace]
+	if !ok {
+		return 0, false
+	}
+	tmp, ok := v.(map[string]interface{})
+	if !ok {
+		return 0, false
+	}
+
+	ms, ok := tmp["max_body_size"]
+	if !ok {
+		return 0, false
+	}
+	size, err := strconv.ParseInt(fmt.Sprintf("%v", ms), 10, 64)
+	if err != nil {
+		return 0, false
+	}
+
+	return size, true
+}
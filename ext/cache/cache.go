@@ -1,6 +1,8 @@
 package opa
 
 import (
+	"container/list"
+	"context"
 	"sync"
 	"time"
 
@@ -14,88 +16,359 @@ type Local interface {
 	Get(key [32]byte) (interface{}, bool)
 	Set(key [32]byte, val interface{})
 	Delete(key [32]byte)
+	//Invalidate removes every entry labeled with the tag
+	Invalidate(tag string)
+	//Flush removes every entry
+	Flush()
 }
 
+//TTLSetter cache supporting a per entry expiration
+type TTLSetter interface {
+	SetWithTTL(key [32]byte, val interface{}, ttl time.Duration)
+}
+
+//SetWithTTL store the value with its own expiration when supported by the cache and ttl is positive,
+//otherwise the cache default expiration applies
+func SetWithTTL(c Local, key [32]byte, val interface{}, ttl time.Duration) {
+	if ts, ok := c.(TTLSetter); ok && ttl > 0 {
+		ts.SetWithTTL(key, val, ttl)
+		return
+	}
+	c.Set(key, val)
+}
+
+const (
+	//defaultMaxEntries bound of the memory cache. The entries are counted, not their size, so the
+	//modules caching large values should set a lower one
+	defaultMaxEntries      = 100000
+	defaultCleanupInterval = time.Minute
+)
+
 type cacheValue struct {
 	value   interface{}
 	expired time.Time
+	elem    *list.Element
 }
 
 // MemoryCache is an implemtation of Cache that stores responses in an in-memory map.
+// When maxEntries is set the oldest entries are evicted to make room for new ones. The bound
+// counts the entries whatever the size of their values.
 type MemoryCache struct {
+	counters
 	mu          sync.RWMutex
-	items       map[[32]byte]cacheValue
+	items       map[[32]byte]*cacheValue
+	order       *list.List
+	tags        tagIndex
 	expDuration time.Duration
+	maxEntries  int
 }
 
 // Get returns the []byte representation of the response and true if present, false if not
 func (c *MemoryCache) Get(key [32]byte) (interface{}, bool) {
+	now := time.Now()
 	c.mu.RLock()
 	resp, ok := c.items[key]
+	var val interface{}
+	var expired time.Time
+	if ok {
+		val, expired = resp.value, resp.expired
+	}
 	c.mu.RUnlock()
 
-	if !resp.expired.IsZero() && time.Now().After(resp.expired) {
-		c.Delete(key)
+	if !ok {
+		c.miss()
+		return nil, false
+	}
+
+	if !expired.IsZero() && now.After(expired) {
+		c.deleteExpired(key, resp)
+		c.expire()
+		c.miss()
 		return nil, false
 	}
 
-	return resp.value, ok
+	c.hit()
+	return val, true
+}
+
+// deleteExpired removes the entry found expired unless it was stored again in the meantime
+func (c *MemoryCache) deleteExpired(key [32]byte, resp *cacheValue) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if v, ok := c.items[key]; ok && v == resp && !v.expired.IsZero() && time.Now().After(v.expired) {
+		c.remove(key)
+	}
 }
 
 // Set saves response resp to the cache with key
 func (c *MemoryCache) Set(key [32]byte, val interface{}) {
+	c.store(key, val, c.expDuration, nil)
+}
+
+// SetWithTTL saves response resp to the cache with key, expiring after ttl
+func (c *MemoryCache) SetWithTTL(key [32]byte, val interface{}, ttl time.Duration) {
+	c.store(key, val, ttl, nil)
+}
+
+// SetWithTags saves response resp to the cache with key, labeled with the tags
+func (c *MemoryCache) SetWithTags(key [32]byte, val interface{}, ttl time.Duration, tags []string) {
+	if ttl <= 0 {
+		ttl = c.expDuration
+	}
+	c.store(key, val, ttl, tags)
+}
+
+func (c *MemoryCache) store(key [32]byte, val interface{}, ttl time.Duration, tags []string) {
+	c.set()
 	c.mu.Lock()
-	v := cacheValue{value: val}
-	if c.expDuration > 0 {
-		v.expired = time.Now().Add(c.expDuration)
+	defer c.mu.Unlock()
+
+	var exp time.Time
+	if ttl > 0 {
+		exp = time.Now().Add(ttl)
+	}
+
+	defer c.tags.add(key, tags)
+
+	if v, ok := c.items[key]; ok {
+		v.value = val
+		v.expired = exp
+		c.order.MoveToBack(v.elem)
+		return
+	}
+
+	if c.maxEntries > 0 && len(c.items) >= c.maxEntries {
+		c.evictOldest()
+	}
+
+	c.items[key] = &cacheValue{
+		value:   val,
+		expired: exp,
+		elem:    c.order.PushBack(key),
 	}
-	c.items[key] = v
-	c.mu.Unlock()
 }
 
 // Delete removes key from the cache
 func (c *MemoryCache) Delete(key [32]byte) {
 	c.mu.Lock()
-	delete(c.items, key)
+	c.remove(key)
+	c.mu.Unlock()
+}
+
+// Invalidate removes every entry labeled with the tag
+func (c *MemoryCache) Invalidate(tag string) {
+	c.mu.Lock()
+	for _, k := range c.tags.take(tag) {
+		c.remove(k)
+	}
 	c.mu.Unlock()
 }
 
+// Flush removes every entry
+func (c *MemoryCache) Flush() {
+	c.mu.Lock()
+	c.items = map[[32]byte]*cacheValue{}
+	c.order.Init()
+	c.tags.reset()
+	c.mu.Unlock()
+}
+
+// DeleteExpired removes every expired entry and returns how many were removed
+func (c *MemoryCache) DeleteExpired() int {
+	now := time.Now()
+	n := 0
+	c.mu.Lock()
+	for k, v := range c.items {
+		if !v.expired.IsZero() && now.After(v.expired) {
+			c.remove(k)
+			c.expire()
+			n++
+		}
+	}
+	c.mu.Unlock()
+	return n
+}
+
+// Len returns the number of stored entries, including the expired ones not swept yet
+func (c *MemoryCache) Len() int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return len(c.items)
+}
+
+// Stats returns the cache counters
+func (c *MemoryCache) Stats() Stats {
+	return c.snapshot(c.Len())
+}
+
+func (c *MemoryCache) remove(key [32]byte) {
+	if v, ok := c.items[key]; ok {
+		c.order.Remove(v.elem)
+		delete(c.items, key)
+		c.tags.remove(key)
+	}
+}
+
+func (c *MemoryCache) evictOldest() {
+	if e := c.order.Front(); e != nil {
+		c.remove(e.Value.([32]byte))
+		c.evict()
+	}
+}
+
+func (c *MemoryCache) janitor(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			c.DeleteExpired()
+		}
+	}
+}
+
 // NewMemoryCache returns a new Cache that will store items in an in-memory map
 func NewMemoryCache(exp time.Duration) *MemoryCache {
 	c := &MemoryCache{
-		items:       map[[32]byte]cacheValue{},
+		items:       map[[32]byte]*cacheValue{},
+		order:       list.New(),
 		expDuration: exp,
 	}
 	return c
 }
 
+// NewMemoryCacheWithContext returns a bounded memory cache holding up to maxEntries items.
+// Expired entries are swept by a background janitor until the context is cancelled. A context never
+// cancelled, like context.Background, starts no janitor: the expired entries are then dropped when read
+// or evicted by the maxEntries bound
+func NewMemoryCacheWithContext(ctx context.Context, exp time.Duration, maxEntries int) *MemoryCache {
+	c := NewMemoryCache(exp)
+	c.maxEntries = maxEntries
+
+	if exp > 0 && ctx.Done() != nil {
+		interval := defaultCleanupInterval
+		if exp < interval {
+			interval = exp
+		}
+		go c.janitor(ctx, interval)
+	}
+	return c
+}
+
 //LRU lru cache
 type LRU struct {
-	cache *lru.Cache
+	counters
+	//mu serializes the stores with the removals of the entries found expired
+	mu          sync.Mutex
+	cache       *lru.Cache
+	tags        tagIndex
+	expDuration time.Duration
 }
 
 //NewLRU create lru cache instance
 func NewLRU(size int) (*LRU, error) {
-	c, err := lru.New(size)
+	return NewLRUWithTTL(size, 0)
+}
+
+//NewLRUWithTTL create lru cache instance where entries also expire after exp
+func NewLRUWithTTL(size int, exp time.Duration) (*LRU, error) {
+	l := &LRU{
+		expDuration: exp,
+	}
+	c, err := lru.NewWithEvict(size, func(key, _ interface{}) {
+		l.tags.remove(key.([32]byte))
+	})
 	if err != nil {
 		return nil, err
 	}
-	return &LRU{
-		cache: c,
-	}, nil
+	l.cache = c
+	return l, nil
 }
 
 //Get get value by key
 func (l *LRU) Get(key [32]byte) (interface{}, bool) {
-	return l.cache.Get(key)
+	v, ok := l.cache.Get(key)
+	if !ok {
+		l.miss()
+		return nil, false
+	}
+	cv, ok := v.(*cacheValue)
+	if !ok {
+		l.miss()
+		return nil, false
+	}
+	if !cv.expired.IsZero() && time.Now().After(cv.expired) {
+		l.deleteExpired(key, cv)
+		l.expire()
+		l.miss()
+		return nil, false
+	}
+	l.hit()
+	return cv.value, true
+}
+
+// deleteExpired removes the entry found expired unless it was stored again in the meantime
+func (l *LRU) deleteExpired(key [32]byte, cv *cacheValue) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if v, ok := l.cache.Peek(key); ok && v == cv {
+		l.cache.Remove(key)
+	}
 }
 
 //Set set value to cache
 func (l *LRU) Set(key [32]byte, val interface{}) {
-	l.cache.Add(key, val)
+	l.store(key, val, l.expDuration, nil)
+}
+
+//SetWithTTL set value to cache, expiring after ttl
+func (l *LRU) SetWithTTL(key [32]byte, val interface{}, ttl time.Duration) {
+	l.store(key, val, ttl, nil)
+}
+
+//SetWithTags set value to cache labeled with the tags
+func (l *LRU) SetWithTags(key [32]byte, val interface{}, ttl time.Duration, tags []string) {
+	if ttl <= 0 {
+		ttl = l.expDuration
+	}
+	l.store(key, val, ttl, tags)
+}
+
+func (l *LRU) store(key [32]byte, val interface{}, ttl time.Duration, tags []string) {
+	v := &cacheValue{value: val}
+	if ttl > 0 {
+		v.expired = time.Now().Add(ttl)
+	}
+	l.set()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.cache.Add(key, v) {
+		l.evict()
+	}
+	l.tags.add(key, tags)
 }
 
 //Delete delete value from cache
 func (l *LRU) Delete(key [32]byte) {
 	l.cache.Remove(key)
 }
+
+//Invalidate delete every value labeled with the tag
+func (l *LRU) Invalidate(tag string) {
+	for _, k := range l.tags.take(tag) {
+		l.cache.Remove(k)
+	}
+}
+
+//Flush delete every value
+func (l *LRU) Flush() {
+	l.cache.Purge()
+	l.tags.reset()
+}
+
+// Stats returns the cache counters
+func (l *LRU) Stats() Stats {
+	return l.snapshot(l.cache.Len())
+}
@@ -1,7 +1,9 @@
 package opa
 
 import (
+	"context"
 	"crypto/sha256"
+	"sync"
 	"testing"
 	"time"
 
@@ -46,6 +48,113 @@ func TestCacheExpiration(t *testing.T) {
 
 }
 
+func TestCacheInvalidation(t *testing.T) {
+	mc := NewMemoryCache(0)
+
+	SetWithTags(mc, hash("test1"), true, 0, "partner1")
+	SetWithTags(mc, hash("test2"), true, 0, "partner1", "partner2")
+	SetWithTags(mc, hash("test3"), true, 0, "partner2")
+	mc.Set(hash("test4"), true)
+
+	mc.Invalidate("partner1")
+	_, ok := mc.Get(hash("test1"))
+	assert.False(t, ok)
+	_, ok = mc.Get(hash("test2"))
+	assert.False(t, ok)
+	_, ok = mc.Get(hash("test3"))
+	assert.True(t, ok)
+
+	SetWithTags(mc, hash("test3"), true, 0, "partner3")
+	mc.Invalidate("partner2")
+	_, ok = mc.Get(hash("test3"))
+	assert.True(t, ok)
+
+	mc.Flush()
+	assert.Equal(t, 0, mc.Len())
+}
+
+func TestCacheMaxEntries(t *testing.T) {
+	mc := NewMemoryCacheWithContext(context.Background(), 0, 2)
+
+	mc.Set(hash("test1"), true)
+	mc.Set(hash("test2"), true)
+	mc.Set(hash("test1"), false)
+	assert.Equal(t, 2, mc.Len())
+
+	mc.Set(hash("test3"), true)
+	assert.Equal(t, 2, mc.Len())
+
+	_, ok := mc.Get(hash("test2"))
+	assert.False(t, ok, "Oldest entry should be evicted")
+
+	rsp, ok := mc.Get(hash("test1"))
+	assert.True(t, ok)
+	assert.False(t, rsp.(bool))
+
+	_, ok = mc.Get(hash("test3"))
+	assert.True(t, ok)
+}
+
+func TestCacheJanitor(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	mc := NewMemoryCacheWithContext(ctx, 10*time.Millisecond, 0)
+	mc.Set(hash("test1"), true)
+	mc.Set(hash("test2"), true)
+	assert.Equal(t, 2, mc.Len())
+
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, 0, mc.Len(), "Expired entries should be swept without being read")
+}
+
+func TestCacheDeleteExpired(t *testing.T) {
+	mc := NewMemoryCache(10 * time.Millisecond)
+	mc.Set(hash("test1"), true)
+
+	time.Sleep(20 * time.Millisecond)
+	mc.Set(hash("test2"), true)
+
+	assert.Equal(t, 1, mc.DeleteExpired())
+	assert.Equal(t, 1, mc.Len())
+}
+
+func TestCacheConcurrentGetSet(t *testing.T) {
+	mc := NewMemoryCache(time.Millisecond)
+	keys := [][32]byte{hash("test1"), hash("test2")}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(2)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 1000; j++ {
+				mc.SetWithTTL(keys[j%2], i*j, time.Duration(j%3)*time.Microsecond)
+			}
+		}(i)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 1000; j++ {
+				mc.Get(keys[j%2])
+			}
+		}()
+	}
+	wg.Wait()
+
+	// an entry stored again once found expired is kept
+	mc.SetWithTTL(keys[0], "old", time.Nanosecond)
+	time.Sleep(time.Millisecond)
+	mc.mu.RLock()
+	expired := mc.items[keys[0]]
+	mc.mu.RUnlock()
+	mc.SetWithTTL(keys[0], "new", time.Minute)
+	mc.deleteExpired(keys[0], expired)
+
+	v, ok := mc.Get(keys[0])
+	assert.True(t, ok)
+	assert.Equal(t, "new", v)
+}
+
 func hash(s string) [32]byte {
 	return sha256.Sum256([]byte(s))
 }
@@ -72,3 +181,64 @@ func TestLRUCache(t *testing.T) {
 	rsp, ok = mc.Get(hash("test1"))
 	assert.False(t, ok)
 }
+
+func TestLRUCacheExpiration(t *testing.T) {
+	mc, _ := NewLRUWithTTL(10, 10*time.Millisecond)
+
+	mc.Set(hash("test1"), true)
+	SetWithTTL(mc, hash("test2"), true, time.Minute)
+
+	rsp, ok := mc.Get(hash("test1"))
+	assert.True(t, ok)
+	assert.True(t, rsp.(bool))
+
+	time.Sleep(20 * time.Millisecond)
+	rsp, ok = mc.Get(hash("test1"))
+	assert.False(t, ok)
+	assert.Nil(t, rsp)
+
+	rsp, ok = mc.Get(hash("test2"))
+	assert.True(t, ok, "Entry TTL should override the default")
+	assert.True(t, rsp.(bool))
+}
+
+func TestLRUCacheDeleteExpired(t *testing.T) {
+	mc, _ := NewLRU(10)
+	key := hash("test1")
+
+	// an entry stored again once found expired is kept
+	mc.SetWithTTL(key, "old", time.Nanosecond)
+	time.Sleep(time.Millisecond)
+	expired, _ := mc.cache.Peek(key)
+	mc.SetWithTTL(key, "new", time.Minute)
+	mc.deleteExpired(key, expired.(*cacheValue))
+
+	v, ok := mc.Get(key)
+	assert.True(t, ok)
+	assert.Equal(t, "new", v)
+}
+
+func TestLRUCacheInvalidation(t *testing.T) {
+	mc, _ := NewLRU(2)
+
+	SetWithTags(mc, hash("test1"), true, 0, "partner1")
+	SetWithTags(mc, hash("test2"), true, 0, "partner2")
+	mc.Invalidate("partner1")
+
+	_, ok := mc.Get(hash("test1"))
+	assert.False(t, ok)
+	_, ok = mc.Get(hash("test2"))
+	assert.True(t, ok)
+
+	SetWithTags(mc, hash("test3"), true, 0, "partner1")
+	SetWithTags(mc, hash("test4"), true, 0, "partner1")
+	_, ok = mc.Get(hash("test2"))
+	assert.False(t, ok, "Entry should be evicted")
+	mc.Invalidate("partner2")
+	_, ok = mc.Get(hash("test3"))
+	assert.True(t, ok)
+
+	mc.Flush()
+	_, ok = mc.Get(hash("test4"))
+	assert.False(t, ok)
+}
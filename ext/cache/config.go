@@ -0,0 +1,166 @@
+package opa
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/devopsfaith/krakend-ce/ext/lint"
+)
+
+const (
+	//ModeLocal per process cache, the default
+	ModeLocal = "local"
+	//ModeRemote cache shared through a Redis protocol server
+	ModeRemote = "remote"
+	//ModeTiered local LRU in front of the shared cache
+	ModeTiered = "tiered"
+)
+
+//Config cache configuration shared by the ext modules
+type Config struct {
+	Context  context.Context
+	Mode     string
+	Duration time.Duration
+	//Size entries of the LRU local cache, replacing the memory one when set
+	Size int
+	//MaxEntries entries of the memory local cache, whatever their size, the oldest ones evicted to make
+	//room for the new ones. defaultMaxEntries when 0
+	MaxEntries int
+	Remote     RemoteConfig
+}
+
+//Fields lint description of the cache_* settings parsed by ParseConfig
+var Fields = []lint.Field{
+	{Name: "cache_mode", Kind: lint.KindString, Check: lint.OneOf(ModeLocal, ModeRemote, ModeTiered)},
+	{Name: "cache_address", Kind: lint.KindString},
+	{Name: "cache_password", Kind: lint.KindString},
+	{Name: "cache_prefix", Kind: lint.KindString, Description: "key prefix in the remote cache, one per endpoint by default"},
+	{Name: "cache_max_entries", Kind: lint.KindInt, Check: lint.Min(0), Description: fmt.Sprintf("number of entries of the local cache, whatever their size, the oldest ones evicted first. %d when 0", defaultMaxEntries)},
+	{Name: "cache_db", Kind: lint.KindInt, Check: lint.Min(0)},
+}
+
+//Check lint validation across the cache_* settings, the errors of Validate. The cache_max_entries
+//ignored by the remote mode or the LRU of the cache_size of the module are warned about
+func Check(tmp map[string]interface{}) []lint.Issue {
+	conf := ParseConfig(tmp)
+	if err := conf.Validate(); err != nil {
+		if e, ok := err.(configError); ok {
+			return []lint.Issue{{Field: e.field, Msg: e.msg}}
+		}
+		return []lint.Issue{{Msg: err.Error()}}
+	}
+
+	if _, ok := tmp["cache_max_entries"]; !ok {
+		return nil
+	}
+	if conf.Mode == ModeRemote {
+		return []lint.Issue{{Field: "cache_max_entries", Msg: "ignored by the remote cache_mode", Warning: true}}
+	}
+	if size, ok := tmp["cache_size"]; ok {
+		if n, err := strconv.Atoi(fmt.Sprintf("%v", size)); err == nil && n > 0 {
+			return []lint.Issue{{Field: "cache_max_entries", Msg: "ignored, the cache_size bounds the local cache", Warning: true}}
+		}
+	}
+	return nil
+}
+
+//ParseConfig parse the cache_* settings of an ext module config block.
+//Duration and size are parsed by each module since they have their own defaults
+func ParseConfig(tmp map[string]interface{}) Config {
+	conf := Config{
+		Mode: ModeLocal,
+	}
+
+	if m, ok := tmp["cache_mode"].(string); ok {
+		conf.Mode = m
+	}
+
+	if a, ok := tmp["cache_address"].(string); ok {
+		conf.Remote.Address = a
+	}
+
+	if p, ok := tmp["cache_password"].(string); ok {
+		conf.Remote.Password = p
+	}
+
+	if p, ok := tmp["cache_prefix"].(string); ok {
+		conf.Remote.Prefix = p
+	}
+
+	if me, ok := tmp["cache_max_entries"]; ok {
+		if mei, err := strconv.Atoi(fmt.Sprintf("%v", me)); err == nil {
+			conf.MaxEntries = mei
+		}
+	}
+
+	if db, ok := tmp["cache_db"]; ok {
+		if dbi, err := strconv.Atoi(fmt.Sprintf("%v", db)); err == nil {
+			conf.Remote.DB = dbi
+		}
+	}
+
+	return conf
+}
+
+//configError an invalid cache_* setting
+type configError struct {
+	field string
+	msg   string
+}
+
+func (e configError) Error() string {
+	return e.field + " " + e.msg
+}
+
+//Validate check the mode and the remote address of the config
+func (c Config) Validate() error {
+	switch c.Mode {
+	case "", ModeLocal:
+		return nil
+	case ModeRemote, ModeTiered:
+		if c.Remote.Address == "" {
+			return configError{"cache_address", fmt.Sprintf("required by the %s cache_mode", c.Mode)}
+		}
+		return nil
+	default:
+		return configError{"cache_mode", fmt.Sprintf("unknown value %q", c.Mode)}
+	}
+}
+
+//New create the cache instance described by the config. An invalid config is an error instead of
+//a silent fallback to the local cache
+func New(cfg Config) (Local, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	var local Local
+	if cfg.Size > 0 {
+		if l, err := NewLRUWithTTL(cfg.Size, cfg.Duration); err == nil {
+			local = l
+		}
+	}
+
+	if local == nil {
+		ctx := cfg.Context
+		if ctx == nil {
+			ctx = context.Background()
+		}
+		maxEntries := cfg.MaxEntries
+		if maxEntries == 0 {
+			maxEntries = defaultMaxEntries
+		}
+		local = NewMemoryCacheWithContext(ctx, cfg.Duration, maxEntries)
+	}
+
+	switch cfg.Mode {
+	case ModeRemote:
+		return NewRemote(cfg.Remote, cfg.Duration), nil
+	case ModeTiered:
+		return NewTiered(local, NewRemote(cfg.Remote, cfg.Duration)), nil
+	default:
+		return local, nil
+	}
+}
@@ -0,0 +1,74 @@
+package opa
+
+import (
+	"encoding/hex"
+	"errors"
+	"strings"
+)
+
+//Invalidation cache invalidation request. Module and Endpoint narrow the affected caches,
+//then the entries are removed by exact Key (hex encoded hash), by Tag, by cache tag Prefix or All of them
+type Invalidation struct {
+	Module   string `json:"module,omitempty"`
+	Endpoint string `json:"endpoint,omitempty"`
+	Key      string `json:"key,omitempty"`
+	Tag      string `json:"tag,omitempty"`
+	Prefix   string `json:"prefix,omitempty"`
+	All      bool   `json:"all,omitempty"`
+}
+
+//Invalidate apply the invalidation to the registered caches and returns how many were affected
+func Invalidate(inv Invalidation) (int, error) {
+	var key [32]byte
+	switch {
+	case inv.All, inv.Tag != "", inv.Prefix != "":
+	case inv.Key != "":
+		b, err := hex.DecodeString(inv.Key)
+		if err != nil || len(b) != len(key) {
+			return 0, errors.New("Invalid cache key")
+		}
+		copy(key[:], b)
+	default:
+		return 0, errors.New("Empty invalidation request")
+	}
+
+	n := 0
+	for _, e := range Registered() {
+		if inv.Module != "" && inv.Module != e.Module {
+			continue
+		}
+		if inv.Endpoint != "" && inv.Endpoint != e.Endpoint {
+			continue
+		}
+
+		switch {
+		case inv.All:
+			e.Cache.Flush()
+		case inv.Prefix != "":
+			if !e.hasTag(func(t string) bool { return strings.HasPrefix(t, inv.Prefix) }) {
+				continue
+			}
+			e.Cache.Flush()
+		case inv.Tag != "":
+			if e.hasTag(func(t string) bool { return t == inv.Tag }) {
+				e.Cache.Flush()
+			} else {
+				e.Cache.Invalidate(inv.Tag)
+			}
+		default:
+			e.Cache.Delete(key)
+		}
+		n++
+	}
+
+	return n, nil
+}
+
+func (e *Entry) hasTag(match func(string) bool) bool {
+	for _, t := range e.Tags {
+		if match(t) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,50 @@
+package opa
+
+import (
+	"context"
+	"encoding/hex"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInvalidate(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	keyauth := NewMemoryCache(0)
+	Register(ctx, "keyauth", "/invalidate-test", keyauth)
+	opa := NewMemoryCache(0)
+	Register(ctx, "opa", "/invalidate-test", opa, "test.allow", "test.allow/user")
+
+	SetWithTags(keyauth, hash("key1"), true, 0, "partner1")
+	SetWithTags(keyauth, hash("key2"), true, 0, "partner2")
+	opa.Set(hash("input1"), true)
+
+	n, err := Invalidate(Invalidation{Endpoint: "/invalidate-test", Tag: "partner1"})
+	assert.Nil(t, err)
+	assert.Equal(t, 2, n)
+	_, ok := keyauth.Get(hash("key1"))
+	assert.False(t, ok)
+	_, ok = keyauth.Get(hash("key2"))
+	assert.True(t, ok)
+	_, ok = opa.Get(hash("input1"))
+	assert.True(t, ok)
+
+	n, err = Invalidate(Invalidation{Module: "opa", Prefix: "test."})
+	assert.Nil(t, err)
+	assert.Equal(t, 1, n)
+	_, ok = opa.Get(hash("input1"))
+	assert.False(t, ok)
+
+	k := hash("key2")
+	_, err = Invalidate(Invalidation{Module: "keyauth", Endpoint: "/invalidate-test", Key: hex.EncodeToString(k[:])})
+	assert.Nil(t, err)
+	_, ok = keyauth.Get(hash("key2"))
+	assert.False(t, ok)
+
+	_, err = Invalidate(Invalidation{Key: "zz"})
+	assert.NotNil(t, err)
+	_, err = Invalidate(Invalidation{})
+	assert.NotNil(t, err)
+}
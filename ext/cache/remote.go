@@ -0,0 +1,497 @@
+package opa
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultRemoteTimeout  = 500 * time.Millisecond
+	defaultRemotePoolSize = 16
+)
+
+//RemoteConfig remote cache connection settings
+type RemoteConfig struct {
+	Address  string
+	Password string
+	DB       int
+	Prefix   string
+	Timeout  time.Duration
+	PoolSize int
+}
+
+//Remote cache backed by a Redis protocol server, shared by every gateway replica.
+//Values are stored as JSON, so they are read back as bool, float64, string,
+//[]interface{} or map[string]interface{}
+type Remote struct {
+	counters
+	client      *redisClient
+	prefix      string
+	expDuration time.Duration
+}
+
+//NewRemote create remote cache instance
+func NewRemote(cfg RemoteConfig, exp time.Duration) *Remote {
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = defaultRemoteTimeout
+	}
+	if cfg.PoolSize <= 0 {
+		cfg.PoolSize = defaultRemotePoolSize
+	}
+	return &Remote{
+		client: &redisClient{
+			address:  cfg.Address,
+			password: cfg.Password,
+			db:       cfg.DB,
+			timeout:  cfg.Timeout,
+			pool:     make(chan *redisConn, cfg.PoolSize),
+		},
+		prefix:      cfg.Prefix,
+		expDuration: exp,
+	}
+}
+
+//remoteEntry stored value, the tags are kept along so the tiers copying the entry keep them
+type remoteEntry struct {
+	Value interface{} `json:"v"`
+	Tags  []string    `json:"t,omitempty"`
+}
+
+//Get get value by key
+func (r *Remote) Get(key [32]byte) (interface{}, bool) {
+	e, ok := r.lookup(key)
+	return e.Value, ok
+}
+
+//getEntry get the value by key along with its remaining ttl, zero when it does not expire, and its tags
+func (r *Remote) getEntry(key [32]byte) (interface{}, time.Duration, []string, bool) {
+	e, ok := r.lookup(key)
+	if !ok {
+		return nil, 0, nil, false
+	}
+	var ttl time.Duration
+	if ms, ok := r.ttl(r.key(key)); ok && ms > 0 {
+		ttl = time.Duration(ms) * time.Millisecond
+	}
+	return e.Value, ttl, e.Tags, true
+}
+
+func (r *Remote) lookup(key [32]byte) (remoteEntry, bool) {
+	res, err := r.client.Do("GET", r.key(key))
+	if err != nil || res == nil {
+		r.miss()
+		return remoteEntry{}, false
+	}
+	raw, ok := res.(string)
+	if !ok {
+		r.miss()
+		return remoteEntry{}, false
+	}
+	var e remoteEntry
+	if err := json.Unmarshal([]byte(raw), &e); err != nil {
+		r.miss()
+		return remoteEntry{}, false
+	}
+	r.hit()
+	return e, true
+}
+
+//ttl the remaining milliseconds of the key, -1 when it does not expire
+func (r *Remote) ttl(k string) (int64, bool) {
+	res, err := r.client.Do("PTTL", k)
+	if err != nil {
+		return 0, false
+	}
+	ms, ok := res.(int64)
+	return ms, ok
+}
+
+//Set set value to cache
+func (r *Remote) Set(key [32]byte, val interface{}) {
+	r.SetWithTTL(key, val, r.expDuration)
+}
+
+//SetWithTTL set value to cache, expiring after ttl
+func (r *Remote) SetWithTTL(key [32]byte, val interface{}, ttl time.Duration) {
+	r.store(key, val, ttl, nil)
+}
+
+//SetWithTags set value to cache labeled with the tags. Tags are kept as sets on the server
+//so any replica can invalidate them
+func (r *Remote) SetWithTags(key [32]byte, val interface{}, ttl time.Duration, tags []string) {
+	if ttl <= 0 {
+		ttl = r.expDuration
+	}
+	r.store(key, val, ttl, tags)
+}
+
+func (r *Remote) store(key [32]byte, val interface{}, ttl time.Duration, tags []string) {
+	raw, err := json.Marshal(remoteEntry{Value: val, Tags: tags})
+	if err != nil {
+		return
+	}
+	r.set()
+
+	k := r.key(key)
+	if ttl > 0 {
+		if _, err := r.client.Do("SET", k, string(raw), "PX", milliseconds(ttl)); err != nil {
+			return
+		}
+	} else if _, err := r.client.Do("SET", k, string(raw)); err != nil {
+		return
+	}
+
+	for _, tag := range tags {
+		r.addTag(r.tagKey(tag), k, ttl)
+	}
+}
+
+//addTag add the key to the tag set, making the set outlive the entry. The TTL of the set is never
+//shortened, so the members stored with longer TTLs are still found by the invalidations
+func (r *Remote) addTag(tk, k string, ttl time.Duration) {
+	//-2 the set does not exist yet, -1 it does not expire
+	current, ok := r.ttl(tk)
+	if _, err := r.client.Do("SADD", tk, k); err != nil {
+		return
+	}
+	if ttl <= 0 {
+		r.client.Do("PERSIST", tk)
+		return
+	}
+	if ok && (current == -1 || current >= int64(ttl/time.Millisecond)) {
+		return
+	}
+	r.client.Do("PEXPIRE", tk, milliseconds(ttl))
+}
+
+//Delete delete value from cache
+func (r *Remote) Delete(key [32]byte) {
+	r.client.Do("DEL", r.key(key))
+}
+
+//Stats returns the cache counters, the size of the shared cache is not tracked
+func (r *Remote) Stats() Stats {
+	return r.snapshot(0)
+}
+
+//Invalidate delete every value labeled with the tag
+func (r *Remote) Invalidate(tag string) {
+	tk := r.tagKey(tag)
+	res, err := r.client.Do("SMEMBERS", tk)
+	if err != nil {
+		return
+	}
+	keys := []string{tk}
+	if members, ok := res.([]interface{}); ok {
+		for _, m := range members {
+			if k, ok := m.(string); ok {
+				keys = append(keys, k)
+			}
+		}
+	}
+	r.client.Do(append([]string{"DEL"}, keys...)...)
+}
+
+//Flush delete every value under the cache prefix
+func (r *Remote) Flush() {
+	cursor := "0"
+	for {
+		res, err := r.client.Do("SCAN", cursor, "MATCH", r.prefix+"*", "COUNT", "100")
+		if err != nil {
+			return
+		}
+		page, ok := res.([]interface{})
+		if !ok || len(page) != 2 {
+			return
+		}
+		if keys, ok := page[1].([]interface{}); ok && len(keys) > 0 {
+			args := make([]string, 0, len(keys))
+			for _, k := range keys {
+				if ks, ok := k.(string); ok {
+					args = append(args, ks)
+				}
+			}
+			r.client.Do(append([]string{"DEL"}, args...)...)
+		}
+		if cursor, ok = page[0].(string); !ok || cursor == "0" {
+			return
+		}
+	}
+}
+
+func (r *Remote) key(key [32]byte) string {
+	return r.prefix + hex.EncodeToString(key[:])
+}
+
+func (r *Remote) tagKey(tag string) string {
+	return r.prefix + "tag:" + tag
+}
+
+func milliseconds(d time.Duration) string {
+	ms := int64(d / time.Millisecond)
+	if ms < 1 {
+		ms = 1
+	}
+	return strconv.FormatInt(ms, 10)
+}
+
+//entryGetter cache returning the remaining ttl and the tags of its entries
+type entryGetter interface {
+	getEntry(key [32]byte) (interface{}, time.Duration, []string, bool)
+}
+
+//Tiered two tier cache, the local cache sits in front of the shared one
+type Tiered struct {
+	counters
+	local  Local
+	remote Local
+}
+
+//NewTiered create two tier cache instance
+func NewTiered(local, remote Local) *Tiered {
+	return &Tiered{
+		local:  local,
+		remote: remote,
+	}
+}
+
+//Get get value from the local cache, falling back to the shared one. The values found in the
+//shared cache are copied to the local one with their remaining ttl and tags, so the invalidations
+//received by the replica remove the copies too
+func (t *Tiered) Get(key [32]byte) (interface{}, bool) {
+	if val, ok := t.local.Get(key); ok {
+		t.hit()
+		return val, true
+	}
+
+	var (
+		val  interface{}
+		ttl  time.Duration
+		tags []string
+		ok   bool
+	)
+	if eg, isEntryGetter := t.remote.(entryGetter); isEntryGetter {
+		val, ttl, tags, ok = eg.getEntry(key)
+	} else {
+		val, ok = t.remote.Get(key)
+	}
+	if !ok {
+		t.miss()
+		return nil, false
+	}
+	t.hit()
+	SetWithTags(t.local, key, val, ttl, tags...)
+	return val, true
+}
+
+//Set set value to both caches
+func (t *Tiered) Set(key [32]byte, val interface{}) {
+	t.set()
+	t.local.Set(key, val)
+	t.remote.Set(key, val)
+}
+
+//SetWithTTL set value to both caches, expiring after ttl
+func (t *Tiered) SetWithTTL(key [32]byte, val interface{}, ttl time.Duration) {
+	t.set()
+	SetWithTTL(t.local, key, val, ttl)
+	SetWithTTL(t.remote, key, val, ttl)
+}
+
+//SetWithTags set value to both caches labeled with the tags
+func (t *Tiered) SetWithTags(key [32]byte, val interface{}, ttl time.Duration, tags []string) {
+	t.set()
+	SetWithTags(t.local, key, val, ttl, tags...)
+	SetWithTags(t.remote, key, val, ttl, tags...)
+}
+
+//Invalidate delete every value labeled with the tag from both caches
+func (t *Tiered) Invalidate(tag string) {
+	t.local.Invalidate(tag)
+	t.remote.Invalidate(tag)
+}
+
+//Flush delete every value from both caches
+func (t *Tiered) Flush() {
+	t.local.Flush()
+	t.remote.Flush()
+}
+
+//Stats returns the cache counters, evictions, expirations and size come from the local tier
+func (t *Tiered) Stats() Stats {
+	s := t.snapshot(0)
+	if ic, ok := t.local.(Instrumented); ok {
+		ls := ic.Stats()
+		s.Evictions = ls.Evictions
+		s.Expirations = ls.Expirations
+		s.Size = ls.Size
+	}
+	return s
+}
+
+//Delete delete value from both caches
+func (t *Tiered) Delete(key [32]byte) {
+	t.local.Delete(key)
+	t.remote.Delete(key)
+}
+
+type redisError string
+
+func (e redisError) Error() string {
+	return string(e)
+}
+
+type redisClient struct {
+	address  string
+	password string
+	db       int
+	timeout  time.Duration
+	pool     chan *redisConn
+}
+
+type redisConn struct {
+	conn net.Conn
+	rd   *bufio.Reader
+}
+
+//Do send a command and read its reply
+func (c *redisClient) Do(args ...string) (interface{}, error) {
+	rc, err := c.get()
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := rc.do(c.timeout, args...)
+	if err != nil {
+		if _, ok := err.(redisError); !ok {
+			rc.conn.Close()
+			return nil, err
+		}
+	}
+	c.put(rc)
+	return res, err
+}
+
+func (c *redisClient) get() (*redisConn, error) {
+	select {
+	case rc := <-c.pool:
+		return rc, nil
+	default:
+	}
+	return c.dial()
+}
+
+func (c *redisClient) put(rc *redisConn) {
+	select {
+	case c.pool <- rc:
+	default:
+		rc.conn.Close()
+	}
+}
+
+func (c *redisClient) dial() (*redisConn, error) {
+	conn, err := net.DialTimeout("tcp", c.address, c.timeout)
+	if err != nil {
+		return nil, err
+	}
+	rc := &redisConn{
+		conn: conn,
+		rd:   bufio.NewReader(conn),
+	}
+
+	if c.password != "" {
+		if _, err := rc.do(c.timeout, "AUTH", c.password); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+
+	if c.db > 0 {
+		if _, err := rc.do(c.timeout, "SELECT", strconv.Itoa(c.db)); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+
+	return rc, nil
+}
+
+func (rc *redisConn) do(timeout time.Duration, args ...string) (interface{}, error) {
+	if timeout > 0 {
+		rc.conn.SetDeadline(time.Now().Add(timeout))
+	}
+
+	if _, err := rc.conn.Write(encodeCommand(args...)); err != nil {
+		return nil, err
+	}
+
+	return readReply(rc.rd)
+}
+
+func encodeCommand(args ...string) []byte {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "*%d\r\n", len(args))
+	for _, a := range args {
+		fmt.Fprintf(&buf, "$%d\r\n%s\r\n", len(a), a)
+	}
+	return buf.Bytes()
+}
+
+func readReply(rd *bufio.Reader) (interface{}, error) {
+	line, err := rd.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	line = strings.TrimSuffix(line, "\r\n")
+	if line == "" {
+		return nil, errors.New("Empty redis reply")
+	}
+
+	switch line[0] {
+	case '+':
+		return line[1:], nil
+	case '-':
+		return nil, redisError(line[1:])
+	case ':':
+		return strconv.ParseInt(line[1:], 10, 64)
+	case '$':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, err
+		}
+		if n < 0 {
+			return nil, nil
+		}
+		b := make([]byte, n+2)
+		if _, err := io.ReadFull(rd, b); err != nil {
+			return nil, err
+		}
+		return string(b[:n]), nil
+	case '*':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, err
+		}
+		if n < 0 {
+			return nil, nil
+		}
+		res := make([]interface{}, n)
+		for i := range res {
+			if res[i], err = readReply(rd); err != nil {
+				return nil, err
+			}
+		}
+		return res, nil
+	default:
+		return nil, fmt.Errorf("Invalid redis reply %q", line)
+	}
+}
@@ -0,0 +1,337 @@
+package opa
+
+import (
+	"bufio"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/devopsfaith/krakend-ce/ext/lint"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeRedis struct {
+	mu    sync.Mutex
+	items map[string]string
+	sets  map[string]map[string]bool
+	ttls  map[string]int64
+	ln    net.Listener
+}
+
+func newFakeRedis(t *testing.T) *fakeRedis {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := &fakeRedis{items: map[string]string{}, sets: map[string]map[string]bool{}, ttls: map[string]int64{}, ln: ln}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go f.serve(conn)
+		}
+	}()
+	return f
+}
+
+func (f *fakeRedis) serve(conn net.Conn) {
+	defer conn.Close()
+	rd := bufio.NewReader(conn)
+	for {
+		req, err := readReply(rd)
+		if err != nil {
+			return
+		}
+		args, ok := req.([]interface{})
+		if !ok || len(args) == 0 {
+			return
+		}
+		conn.Write([]byte(f.handle(args)))
+	}
+}
+
+func (f *fakeRedis) handle(args []interface{}) string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	switch strings.ToUpper(args[0].(string)) {
+	case "GET":
+		v, ok := f.items[args[1].(string)]
+		if !ok {
+			return "$-1\r\n"
+		}
+		return string(encodeBulk(v))
+	case "SET":
+		f.items[args[1].(string)] = args[2].(string)
+		delete(f.ttls, args[1].(string))
+		if len(args) == 5 && strings.ToUpper(args[3].(string)) == "PX" {
+			ms, _ := strconv.ParseInt(args[4].(string), 10, 64)
+			f.ttls[args[1].(string)] = ms
+		}
+		return "+OK\r\n"
+	case "DEL":
+		for _, k := range args[1:] {
+			delete(f.items, k.(string))
+			delete(f.sets, k.(string))
+			delete(f.ttls, k.(string))
+		}
+		return ":" + strconv.Itoa(len(args)-1) + "\r\n"
+	case "SADD":
+		s, ok := f.sets[args[1].(string)]
+		if !ok {
+			s = map[string]bool{}
+			f.sets[args[1].(string)] = s
+		}
+		for _, m := range args[2:] {
+			s[m.(string)] = true
+		}
+		return ":1\r\n"
+	case "SMEMBERS":
+		members := []string{}
+		for m := range f.sets[args[1].(string)] {
+			members = append(members, m)
+		}
+		return string(encodeArray(members))
+	case "PEXPIRE":
+		ms, _ := strconv.ParseInt(args[2].(string), 10, 64)
+		f.ttls[args[1].(string)] = ms
+		return ":1\r\n"
+	case "PTTL":
+		_, isItem := f.items[args[1].(string)]
+		if _, ok := f.sets[args[1].(string)]; !ok && !isItem {
+			return ":-2\r\n"
+		}
+		ms, ok := f.ttls[args[1].(string)]
+		if !ok {
+			return ":-1\r\n"
+		}
+		return ":" + strconv.FormatInt(ms, 10) + "\r\n"
+	case "PERSIST":
+		delete(f.ttls, args[1].(string))
+		return ":1\r\n"
+	case "SCAN":
+		prefix := strings.TrimSuffix(args[3].(string), "*")
+		keys := []string{}
+		for k := range f.items {
+			if strings.HasPrefix(k, prefix) {
+				keys = append(keys, k)
+			}
+		}
+		for k := range f.sets {
+			if strings.HasPrefix(k, prefix) {
+				keys = append(keys, k)
+			}
+		}
+		return "*2\r\n" + string(encodeBulk("0")) + string(encodeArray(keys))
+	default:
+		return "-ERR unknown command\r\n"
+	}
+}
+
+func encodeBulk(v string) []byte {
+	return []byte("$" + strconv.Itoa(len(v)) + "\r\n" + v + "\r\n")
+}
+
+func encodeArray(vs []string) []byte {
+	res := []byte("*" + strconv.Itoa(len(vs)) + "\r\n")
+	for _, v := range vs {
+		res = append(res, encodeBulk(v)...)
+	}
+	return res
+}
+
+func TestRemoteCache(t *testing.T) {
+	srv := newFakeRedis(t)
+	defer srv.ln.Close()
+
+	rc := NewRemote(RemoteConfig{Address: srv.ln.Addr().String(), Prefix: "test:"}, time.Minute)
+
+	rc.Set(hash("test1"), true)
+	rsp, ok := rc.Get(hash("test1"))
+	assert.True(t, ok)
+	assert.True(t, rsp.(bool))
+
+	rc.Set(hash("test2"), map[string]interface{}{"id": "partner1"})
+	rsp, ok = rc.Get(hash("test2"))
+	assert.True(t, ok)
+	assert.Equal(t, map[string]interface{}{"id": "partner1"}, rsp)
+
+	rsp, ok = rc.Get(hash("test3"))
+	assert.False(t, ok)
+	assert.Nil(t, rsp)
+
+	rc.Delete(hash("test1"))
+	_, ok = rc.Get(hash("test1"))
+	assert.False(t, ok)
+}
+
+func TestRemoteCacheInvalidation(t *testing.T) {
+	srv := newFakeRedis(t)
+	defer srv.ln.Close()
+
+	rc := NewRemote(RemoteConfig{Address: srv.ln.Addr().String(), Prefix: "test:"}, time.Minute)
+	other := NewRemote(RemoteConfig{Address: srv.ln.Addr().String(), Prefix: "other:"}, time.Minute)
+
+	rc.SetWithTags(hash("test1"), true, 0, []string{"partner1"})
+	rc.SetWithTags(hash("test2"), true, 0, []string{"partner1", "partner2"})
+	rc.Set(hash("test3"), true)
+	other.Set(hash("test1"), true)
+
+	rc.Invalidate("partner1")
+	_, ok := rc.Get(hash("test1"))
+	assert.False(t, ok)
+	_, ok = rc.Get(hash("test2"))
+	assert.False(t, ok)
+	_, ok = rc.Get(hash("test3"))
+	assert.True(t, ok)
+
+	rc.Flush()
+	_, ok = rc.Get(hash("test3"))
+	assert.False(t, ok)
+	_, ok = other.Get(hash("test1"))
+	assert.True(t, ok)
+}
+
+func TestRemoteCacheTagTTL(t *testing.T) {
+	srv := newFakeRedis(t)
+	defer srv.ln.Close()
+
+	rc := NewRemote(RemoteConfig{Address: srv.ln.Addr().String(), Prefix: "test:"}, time.Minute)
+	ttl := func() (int64, bool) {
+		srv.mu.Lock()
+		defer srv.mu.Unlock()
+		ms, ok := srv.ttls["test:tag:partner1"]
+		return ms, ok
+	}
+
+	rc.SetWithTags(hash("test1"), true, time.Hour, []string{"partner1"})
+	ms, _ := ttl()
+	assert.Equal(t, int64(time.Hour/time.Millisecond), ms)
+
+	// a shorter entry does not shorten the set
+	rc.SetWithTags(hash("test2"), true, time.Second, []string{"partner1"})
+	ms, _ = ttl()
+	assert.Equal(t, int64(time.Hour/time.Millisecond), ms)
+
+	rc.SetWithTags(hash("test3"), true, 2*time.Hour, []string{"partner1"})
+	ms, _ = ttl()
+	assert.Equal(t, int64(2*time.Hour/time.Millisecond), ms)
+
+	// an entry without expiration makes the set persistent
+	NewRemote(RemoteConfig{Address: srv.ln.Addr().String(), Prefix: "test:"}, 0).SetWithTags(hash("test4"), true, 0, []string{"partner1"})
+	_, ok := ttl()
+	assert.False(t, ok)
+	rc.SetWithTags(hash("test5"), true, time.Second, []string{"partner1"})
+	_, ok = ttl()
+	assert.False(t, ok)
+}
+
+func TestRemoteCacheUnavailable(t *testing.T) {
+	rc := NewRemote(RemoteConfig{Address: "127.0.0.1:1", Timeout: 10 * time.Millisecond}, 0)
+
+	rc.Set(hash("test1"), true)
+	rsp, ok := rc.Get(hash("test1"))
+	assert.False(t, ok)
+	assert.Nil(t, rsp)
+}
+
+func TestTieredCache(t *testing.T) {
+	srv := newFakeRedis(t)
+	defer srv.ln.Close()
+
+	remote := NewRemote(RemoteConfig{Address: srv.ln.Addr().String()}, 0)
+	first := NewTiered(NewMemoryCache(0), remote)
+	second := NewTiered(NewMemoryCache(0), remote)
+
+	first.Set(hash("test1"), true)
+	rsp, ok := second.Get(hash("test1"))
+	assert.True(t, ok)
+	assert.True(t, rsp.(bool))
+
+	first.Delete(hash("test1"))
+	_, ok = first.Get(hash("test1"))
+	assert.False(t, ok)
+}
+
+func TestTieredCacheInvalidation(t *testing.T) {
+	srv := newFakeRedis(t)
+	defer srv.ln.Close()
+
+	remote := NewRemote(RemoteConfig{Address: srv.ln.Addr().String(), Prefix: "test:"}, 0)
+	first := NewTiered(NewMemoryCache(0), remote)
+	local := NewMemoryCache(0)
+	second := NewTiered(local, remote)
+
+	first.SetWithTags(hash("test1"), true, 0, []string{"partner1"})
+	first.SetWithTags(hash("test2"), true, 50*time.Millisecond, nil)
+	_, ok := second.Get(hash("test1"))
+	assert.True(t, ok)
+	_, ok = second.Get(hash("test2"))
+	assert.True(t, ok)
+
+	// the replica receiving the invalidation removes the copy of the shared entry
+	first.Invalidate("partner1")
+	second.Invalidate("partner1")
+	_, ok = local.Get(hash("test1"))
+	assert.False(t, ok, "The copied entry should keep its tags")
+
+	time.Sleep(60 * time.Millisecond)
+	_, ok = local.Get(hash("test2"))
+	assert.False(t, ok, "The copied entry should keep its remaining ttl")
+}
+
+func TestNewCache(t *testing.T) {
+	for _, tc := range []struct {
+		cfg  Config
+		want Local
+	}{
+		{cfg: Config{}, want: &MemoryCache{}},
+		{cfg: Config{Size: 10}, want: &LRU{}},
+		{cfg: Config{Mode: ModeRemote, Remote: RemoteConfig{Address: "localhost:6379"}}, want: &Remote{}},
+		{cfg: Config{Mode: ModeTiered, Size: 10, Remote: RemoteConfig{Address: "localhost:6379"}}, want: &Tiered{}},
+	} {
+		c, err := New(tc.cfg)
+		assert.NoError(t, err)
+		assert.IsType(t, tc.want, c)
+	}
+
+	_, err := New(Config{Mode: ModeRemote})
+	assert.EqualError(t, err, "cache_address required by the remote cache_mode")
+	_, err = New(Config{Mode: ModeTiered})
+	assert.EqualError(t, err, "cache_address required by the tiered cache_mode")
+	_, err = New(Config{Mode: "shared", Remote: RemoteConfig{Address: "localhost:6379"}})
+	assert.EqualError(t, err, `cache_mode unknown value "shared"`)
+}
+
+func TestCheck(t *testing.T) {
+	assert.Nil(t, Check(map[string]interface{}{}))
+	assert.Nil(t, Check(map[string]interface{}{"cache_mode": "remote", "cache_address": "localhost:6379"}))
+	assert.Equal(t, []lint.Issue{{Field: "cache_address", Msg: "required by the tiered cache_mode"}}, Check(map[string]interface{}{"cache_mode": "tiered"}))
+	assert.Equal(t, []lint.Issue{{Field: "cache_mode", Msg: `unknown value "shared"`}}, Check(map[string]interface{}{"cache_mode": "shared", "cache_address": "localhost:6379"}))
+
+	assert.Nil(t, Check(map[string]interface{}{"cache_mode": "tiered", "cache_address": "localhost:6379", "cache_max_entries": 500}))
+	assert.Equal(t, []lint.Issue{{Field: "cache_max_entries", Msg: "ignored by the remote cache_mode", Warning: true}}, Check(map[string]interface{}{"cache_mode": "remote", "cache_address": "localhost:6379", "cache_max_entries": 500}))
+	assert.Equal(t, []lint.Issue{{Field: "cache_max_entries", Msg: "ignored, the cache_size bounds the local cache", Warning: true}}, Check(map[string]interface{}{"cache_size": 100, "cache_max_entries": 500}))
+}
+
+func TestParseConfig(t *testing.T) {
+	cfg := ParseConfig(map[string]interface{}{
+		"cache_mode":        "tiered",
+		"cache_address":     "localhost:6379",
+		"cache_db":          2,
+		"cache_prefix":      "opa:",
+		"cache_max_entries": 500,
+	})
+
+	assert.Equal(t, ModeTiered, cfg.Mode)
+	assert.Equal(t, "localhost:6379", cfg.Remote.Address)
+	assert.Equal(t, 2, cfg.Remote.DB)
+	assert.Equal(t, "opa:", cfg.Remote.Prefix)
+	assert.Equal(t, 500, cfg.MaxEntries)
+	assert.Equal(t, ModeLocal, ParseConfig(map[string]interface{}{}).Mode)
+}
@@ -0,0 +1,110 @@
+package opa
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+)
+
+//Stats cache counters snapshot
+type Stats struct {
+	Hits        uint64
+	Misses      uint64
+	Sets        uint64
+	Evictions   uint64
+	Expirations uint64
+	Size        int
+}
+
+//Instrumented cache exposing its counters
+type Instrumented interface {
+	Stats() Stats
+}
+
+type counters struct {
+	hits        uint64
+	misses      uint64
+	sets        uint64
+	evictions   uint64
+	expirations uint64
+}
+
+func (c *counters) hit()    { atomic.AddUint64(&c.hits, 1) }
+func (c *counters) miss()   { atomic.AddUint64(&c.misses, 1) }
+func (c *counters) set()    { atomic.AddUint64(&c.sets, 1) }
+func (c *counters) evict()  { atomic.AddUint64(&c.evictions, 1) }
+func (c *counters) expire() { atomic.AddUint64(&c.expirations, 1) }
+
+func (c *counters) snapshot(size int) Stats {
+	return Stats{
+		Hits:        atomic.LoadUint64(&c.hits),
+		Misses:      atomic.LoadUint64(&c.misses),
+		Sets:        atomic.LoadUint64(&c.sets),
+		Evictions:   atomic.LoadUint64(&c.evictions),
+		Expirations: atomic.LoadUint64(&c.expirations),
+		Size:        size,
+	}
+}
+
+//Entry registered cache, labeled by owning module and endpoint. Tags label the whole cache,
+//e.g. the policy package it holds decisions for
+type Entry struct {
+	Module   string
+	Endpoint string
+	Tags     []string
+	Cache    Local
+}
+
+//Stats returns the counters of the cache, if instrumented
+func (e *Entry) Stats() (Stats, bool) {
+	ic, ok := e.Cache.(Instrumented)
+	if !ok {
+		return Stats{}, false
+	}
+	return ic.Stats(), true
+}
+
+var (
+	registryMu sync.RWMutex
+	registry   = map[*Entry]struct{}{}
+)
+
+//Register make the cache visible to the metric collectors and the invalidation API until
+//the context is cancelled. A context never cancelled, like context.Background, keeps it registered
+//without waiting on it
+func Register(ctx context.Context, module, endpoint string, c Local, tags ...string) {
+	if c == nil {
+		return
+	}
+	e := &Entry{
+		Module:   module,
+		Endpoint: endpoint,
+		Tags:     tags,
+		Cache:    c,
+	}
+
+	registryMu.Lock()
+	registry[e] = struct{}{}
+	registryMu.Unlock()
+
+	if ctx.Done() == nil {
+		return
+	}
+	go func() {
+		<-ctx.Done()
+		registryMu.Lock()
+		delete(registry, e)
+		registryMu.Unlock()
+	}()
+}
+
+//Registered returns the registered caches
+func Registered() []*Entry {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	res := make([]*Entry, 0, len(registry))
+	for e := range registry {
+		res = append(res, e)
+	}
+	return res
+}
@@ -0,0 +1,72 @@
+package opa
+
+import (
+	"context"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemoryCacheStats(t *testing.T) {
+	mc := NewMemoryCacheWithContext(context.Background(), 0, 1)
+
+	mc.Set(hash("test1"), true)
+	mc.Get(hash("test1"))
+	mc.Get(hash("test2"))
+	mc.Set(hash("test2"), true)
+	SetWithTTL(mc, hash("test3"), true, time.Millisecond)
+	time.Sleep(5 * time.Millisecond)
+	mc.Get(hash("test3"))
+
+	assert.Equal(t, Stats{Hits: 1, Misses: 2, Sets: 3, Evictions: 2, Expirations: 1, Size: 0}, mc.Stats())
+}
+
+func TestLRUStats(t *testing.T) {
+	mc, _ := NewLRUWithTTL(1, 0)
+
+	mc.Set(hash("test1"), true)
+	mc.Set(hash("test2"), true)
+	mc.Get(hash("test1"))
+	mc.Get(hash("test2"))
+
+	assert.Equal(t, Stats{Hits: 1, Misses: 1, Sets: 2, Evictions: 1, Size: 1}, mc.Stats())
+}
+
+func TestRegister(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	mc := NewMemoryCache(0)
+	Register(ctx, "opa", "/test", mc)
+
+	found := false
+	for _, e := range Registered() {
+		if e.Cache == mc {
+			found = true
+			assert.Equal(t, "opa", e.Module)
+			assert.Equal(t, "/test", e.Endpoint)
+		}
+	}
+	assert.True(t, found)
+
+	cancel()
+	time.Sleep(10 * time.Millisecond)
+	for _, e := range Registered() {
+		assert.False(t, e.Cache == mc, "Cache should be unregistered")
+	}
+}
+
+func TestRegister_background(t *testing.T) {
+	before := runtime.NumGoroutine()
+
+	mc := NewMemoryCacheWithContext(context.Background(), time.Minute, 0)
+	Register(context.Background(), "opa", "/background", mc)
+
+	assert.False(t, runtime.NumGoroutine() > before, "A context never cancelled should not start goroutines")
+	found := false
+	for _, e := range Registered() {
+		found = found || e.Cache == mc
+	}
+	assert.True(t, found)
+}
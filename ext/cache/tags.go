@@ -0,0 +1,89 @@
+package opa
+
+import (
+	"sync"
+	"time"
+)
+
+//Tagger cache supporting entries labeled with tags, so they can be invalidated together
+type Tagger interface {
+	SetWithTags(key [32]byte, val interface{}, ttl time.Duration, tags []string)
+}
+
+//SetWithTags store the value labeled with the tags when supported by the cache. A non positive
+//ttl means the cache default expiration
+func SetWithTags(c Local, key [32]byte, val interface{}, ttl time.Duration, tags ...string) {
+	if tg, ok := c.(Tagger); ok && len(tags) > 0 {
+		tg.SetWithTags(key, val, ttl, tags)
+		return
+	}
+	SetWithTTL(c, key, val, ttl)
+}
+
+type tagIndex struct {
+	mu   sync.Mutex
+	tags map[string]map[[32]byte]struct{}
+	keys map[[32]byte][]string
+}
+
+func (t *tagIndex) add(key [32]byte, tags []string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.unlink(key)
+	if len(tags) == 0 {
+		return
+	}
+	if t.tags == nil {
+		t.tags = map[string]map[[32]byte]struct{}{}
+		t.keys = map[[32]byte][]string{}
+	}
+	for _, tag := range tags {
+		ks, ok := t.tags[tag]
+		if !ok {
+			ks = map[[32]byte]struct{}{}
+			t.tags[tag] = ks
+		}
+		ks[key] = struct{}{}
+	}
+	t.keys[key] = tags
+}
+
+func (t *tagIndex) remove(key [32]byte) {
+	t.mu.Lock()
+	t.unlink(key)
+	t.mu.Unlock()
+}
+
+//take removes the tag from the index and returns the keys labeled with it
+func (t *tagIndex) take(tag string) [][32]byte {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	ks := t.tags[tag]
+	res := make([][32]byte, 0, len(ks))
+	for k := range ks {
+		res = append(res, k)
+	}
+	for _, k := range res {
+		t.unlink(k)
+	}
+	return res
+}
+
+func (t *tagIndex) reset() {
+	t.mu.Lock()
+	t.tags = nil
+	t.keys = nil
+	t.mu.Unlock()
+}
+
+func (t *tagIndex) unlink(key [32]byte) {
+	for _, tag := range t.keys[key] {
+		if ks, ok := t.tags[tag]; ok {
+			delete(ks, key)
+			if len(ks) == 0 {
+				delete(t.tags, tag)
+			}
+		}
+	}
+	delete(t.keys, key)
+}
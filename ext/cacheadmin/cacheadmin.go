@@ -0,0 +1,120 @@
+package cacheadmin
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+
+	cache "github.com/devopsfaith/krakend-ce/ext/cache"
+	"github.com/devopsfaith/krakend/config"
+	"github.com/devopsfaith/krakend/logging"
+	"github.com/gin-gonic/gin"
+	"gocloud.dev/pubsub"
+)
+
+//Register add the cache invalidation endpoint to the engine. When a topic is configured the
+//invalidation is also published, so every replica subscribed to it applies it. The endpoint is
+//not served without an admin token
+func Register(cfg config.ExtraConfig, l logging.Logger, engine *gin.Engine) {
+	conf := configGetter(cfg)
+	if conf == nil || conf.AdminPath == "" {
+		return
+	}
+	if conf.AdminToken == "" {
+		l.Warning("[CacheAdmin] No admin_token, the cache invalidation endpoint is disabled")
+		return
+	}
+
+	l.Debug("[CacheAdmin] Cache invalidation endpoint enabled at ", conf.AdminPath)
+	engine.POST(conf.AdminPath, conf.handler(l))
+}
+
+//Subscribe apply the invalidations received from the configured subscription until the context is cancelled.
+//The pubsub drivers are the ones registered by krakend-pubsub
+func Subscribe(ctx context.Context, cfg config.ExtraConfig, l logging.Logger) error {
+	conf := configGetter(cfg)
+	if conf == nil || conf.SubscriptionURL == "" {
+		return nil
+	}
+
+	sub, err := pubsub.OpenSubscription(ctx, conf.SubscriptionURL)
+	if err != nil {
+		return err
+	}
+
+	l.Debug("[CacheAdmin] Listening cache invalidations from ", conf.SubscriptionURL)
+
+	go func() {
+		defer sub.Shutdown(context.Background())
+		for {
+			msg, err := sub.Receive(ctx)
+			if err != nil {
+				if ctx.Err() == nil {
+					l.Error("[CacheAdmin] Error receiving cache invalidation ", err)
+				}
+				return
+			}
+
+			var inv cache.Invalidation
+			if err := json.Unmarshal(msg.Body, &inv); err != nil {
+				l.Warning("[CacheAdmin] Invalid cache invalidation message ", err)
+				msg.Ack()
+				continue
+			}
+
+			if _, err := cache.Invalidate(inv); err != nil {
+				l.Warning("[CacheAdmin] Error invalidating cache ", err)
+			}
+			msg.Ack()
+		}
+	}()
+
+	return nil
+}
+
+func (x *xtraConfig) handler(l logging.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if subtle.ConstantTimeCompare([]byte(c.GetHeader(tokenHeader)), []byte(x.AdminToken)) != 1 {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, map[string]interface{}{"error": "Invalid admin token"})
+			return
+		}
+
+		var inv cache.Invalidation
+		if err := json.NewDecoder(c.Request.Body).Decode(&inv); err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, map[string]interface{}{"error": err.Error()})
+			return
+		}
+
+		n, err := cache.Invalidate(inv)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, map[string]interface{}{"error": err.Error()})
+			return
+		}
+
+		if x.TopicURL != "" {
+			if err := x.publish(c.Request.Context(), inv); err != nil {
+				l.Error("[CacheAdmin] Error publishing cache invalidation ", err)
+				c.AbortWithStatusJSON(http.StatusBadGateway, map[string]interface{}{"error": err.Error(), "caches": n})
+				return
+			}
+		}
+
+		c.JSON(http.StatusOK, map[string]interface{}{"caches": n})
+	}
+}
+
+func (x *xtraConfig) publish(ctx context.Context, inv cache.Invalidation) error {
+	body, err := json.Marshal(inv)
+	if err != nil {
+		return err
+	}
+
+	topic, err := pubsub.OpenTopic(ctx, x.TopicURL)
+	if err != nil {
+		return err
+	}
+	defer topic.Shutdown(context.Background())
+
+	return topic.Send(ctx, &pubsub.Message{Body: body})
+}
@@ -0,0 +1,142 @@
+package cacheadmin
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	cache "github.com/devopsfaith/krakend-ce/ext/cache"
+	"github.com/devopsfaith/krakend-ce/ext/lint"
+	"github.com/devopsfaith/krakend/config"
+	"github.com/devopsfaith/krakend/logging"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"gocloud.dev/pubsub"
+	_ "gocloud.dev/pubsub/mempubsub"
+)
+
+func TestConfig(t *testing.T) {
+	assert.Nil(t, configGetter(config.ExtraConfig{}))
+
+	cfg := configGetter(config.ExtraConfig{
+		namespace: map[string]interface{}{
+			"subscription_url": "mem://cache",
+		},
+	})
+	assert.NotNil(t, cfg)
+	assert.Equal(t, defaultAdminPath, cfg.AdminPath)
+	assert.Equal(t, "mem://cache", cfg.SubscriptionURL)
+}
+
+func TestAdminEndpoint(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	mc := cache.NewMemoryCache(0)
+	cache.Register(ctx, "keyauth", "/admin-test", mc)
+	key := [32]byte{1}
+	cache.SetWithTags(mc, key, true, 0, "partner1")
+
+	logger, _ := logging.NewLogger("ERROR", os.Stdout, "")
+	engine := gin.New()
+	Register(config.ExtraConfig{
+		namespace: map[string]interface{}{
+			"admin_token": "secret",
+		},
+	}, logger, engine)
+
+	body, _ := json.Marshal(cache.Invalidation{Endpoint: "/admin-test", Tag: "partner1"})
+
+	req, _ := http.NewRequest("POST", defaultAdminPath, bytes.NewReader(body))
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	req, _ = http.NewRequest("POST", defaultAdminPath, bytes.NewReader(body))
+	req.Header.Set(tokenHeader, "secret")
+	w = httptest.NewRecorder()
+	engine.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	_, ok := mc.Get(key)
+	assert.False(t, ok)
+
+	req, _ = http.NewRequest("POST", defaultAdminPath, bytes.NewReader([]byte(`{}`)))
+	req.Header.Set(tokenHeader, "secret")
+	w = httptest.NewRecorder()
+	engine.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestAdminEndpoint_noToken(t *testing.T) {
+	logger, _ := logging.NewLogger("ERROR", os.Stdout, "")
+	engine := gin.New()
+	Register(config.ExtraConfig{
+		namespace: map[string]interface{}{
+			"subscription_url": "mem://cache",
+		},
+	}, logger, engine)
+
+	req, _ := http.NewRequest("POST", defaultAdminPath, bytes.NewReader([]byte(`{"all":true}`)))
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestLinter(t *testing.T) {
+	errs := lint.Lint(config.ServiceConfig{ExtraConfig: config.ExtraConfig{
+		namespace: map[string]interface{}{"admin_path": "/__flush"},
+	}}, Linter)
+	assert.Len(t, errs, 1)
+	assert.True(t, errs[0].Security)
+	assert.Equal(t, "service: github_com/sahalzain/krakend-cache.admin_token: required to serve the invalidation endpoint at /__flush", errs[0].Error())
+
+	errs = lint.Lint(config.ServiceConfig{ExtraConfig: config.ExtraConfig{
+		namespace: map[string]interface{}{"subscription_url": "mem://cache"},
+	}}, Linter)
+	assert.Len(t, errs, 1)
+	assert.False(t, errs[0].Security)
+
+	errs = lint.Lint(config.ServiceConfig{ExtraConfig: config.ExtraConfig{
+		namespace: map[string]interface{}{"admin_token": "secret", "topic_url": "mem://cache"},
+	}}, Linter)
+	assert.Empty(t, errs)
+}
+
+func TestSubscribe(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	topic, err := pubsub.OpenTopic(ctx, "mem://cache-invalidation")
+	assert.Nil(t, err)
+	defer topic.Shutdown(ctx)
+
+	mc := cache.NewMemoryCache(0)
+	cache.Register(ctx, "opa", "/subscribe-test", mc, "opa.test")
+	key := [32]byte{2}
+	mc.Set(key, true)
+
+	logger, _ := logging.NewLogger("ERROR", os.Stdout, "")
+	err = Subscribe(ctx, config.ExtraConfig{
+		namespace: map[string]interface{}{
+			"subscription_url": "mem://cache-invalidation",
+		},
+	}, logger)
+	assert.Nil(t, err)
+
+	body, _ := json.Marshal(cache.Invalidation{Module: "opa", Prefix: "opa."})
+	assert.Nil(t, topic.Send(ctx, &pubsub.Message{Body: body}))
+
+	for i := 0; i < 50; i++ {
+		if _, ok := mc.Get(key); !ok {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Error("Cache should be flushed by the invalidation message")
+}
@@ -0,0 +1,77 @@
+package cacheadmin
+
+import (
+	"github.com/devopsfaith/krakend-ce/ext/lint"
+	"github.com/devopsfaith/krakend/config"
+)
+
+const (
+	namespace        = "github_com/sahalzain/krakend-cache"
+	defaultAdminPath = "/__cache/invalidate"
+	tokenHeader      = "X-Admin-Token"
+)
+
+//Linter strict validation of the config block. The invalidation endpoint flushes the caches of the
+//auth modules, so it is never served without a token
+var Linter = lint.Linter{
+	Namespace: namespace,
+	Scope:     lint.ScopeService,
+	Security:  true,
+	Fields: []lint.Field{
+		{Name: "admin_path", Kind: lint.KindString},
+		{Name: "admin_token", Kind: lint.KindString, Description: "required to serve the invalidation endpoint"},
+		{Name: "topic_url", Kind: lint.KindString},
+		{Name: "subscription_url", Kind: lint.KindString},
+	},
+	Check: func(tmp map[string]interface{}) []lint.Issue {
+		if token, _ := tmp["admin_token"].(string); token != "" {
+			return nil
+		}
+		if path, ok := tmp["admin_path"].(string); ok && path != "" {
+			return []lint.Issue{{Field: "admin_token", Msg: "required to serve the invalidation endpoint at " + path}}
+		}
+		if _, ok := tmp["topic_url"]; ok {
+			return []lint.Issue{{Field: "admin_token", Msg: "required to serve the invalidation endpoint publishing to the topic"}}
+		}
+		return []lint.Issue{{Field: "admin_token", Msg: "missing, the invalidation endpoint is disabled", Warning: true}}
+	},
+}
+
+type xtraConfig struct {
+	AdminPath       string
+	AdminToken      string
+	TopicURL        string
+	SubscriptionURL string
+}
+
+func configGetter(cfg config.ExtraConfig) *xtraConfig {
+	v, ok := cfg[namespace]
+	if !ok {
+		return nil
+	}
+	tmp, ok := v.(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	conf := xtraConfig{
+		AdminPath: defaultAdminPath,
+	}
+
+	if ap, ok := tmp["admin_path"].(string); ok {
+		conf.AdminPath = ap
+	}
+
+	if at, ok := tmp["admin_token"].(string); ok {
+		conf.AdminToken = at
+	}
+
+	if tu, ok := tmp["topic_url"].(string); ok {
+		conf.TopicURL = tu
+	}
+
+	if su, ok := tmp["subscription_url"].(string); ok {
+		conf.SubscriptionURL = su
+	}
+
+	return &conf
+}
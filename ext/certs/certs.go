@@ -0,0 +1,172 @@
+//Package certs serves the main listener over TLS with several certificates, selected by the SNI
+//host of every handshake. The certificate files are watched and reloaded without a restart and
+//every certificate can require the clients to present their own, verified against its CAs. The
+//TLS servers of the other listeners hand the same certificates through ConfigForClient.
+package certs
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/devopsfaith/krakend/config"
+	"github.com/devopsfaith/krakend/logging"
+	router "github.com/devopsfaith/krakend/router/gin"
+)
+
+var (
+	activeMu sync.RWMutex
+	active   *store
+)
+
+//Expiry the expiry of the certificates served, by name. Empty when the listener is not serving them
+func Expiry() map[string]time.Time {
+	s := current()
+	if s == nil {
+		return map[string]time.Time{}
+	}
+	return s.expiry()
+}
+
+//ConfigForClient the TLS config of the certificate served to the SNI host of the handshake, for
+//the TLS servers of the other listeners. It is nil when the main listener is not serving the
+//certificates, so the handshake keeps the config of the server
+func ConfigForClient(hello *tls.ClientHelloInfo) (*tls.Config, error) {
+	s := current()
+	if s == nil {
+		return nil, nil
+	}
+	return s.configForClient(hello)
+}
+
+//Handler reject the misdirected requests, as the main listener does, for the TLS servers of the
+//other listeners. The requests pass when the main listener is not serving the certificates
+func Handler(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s := current(); s != nil {
+			misdirected(s, h).ServeHTTP(w, r)
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+//current the store of the certificates served, nil when there is none
+func current() *store {
+	activeMu.RLock()
+	defer activeMu.RUnlock()
+	return active
+}
+
+//RunServer wrap the RunServer so the main listener serves the certificates declared in the service
+//extra config, replacing the static TLS of the service config. Without the block, next is run
+func RunServer(l logging.Logger, next router.RunServerFunc) router.RunServerFunc {
+	return func(ctx context.Context, cfg config.ServiceConfig, h http.Handler) error {
+		conf := configGetter(cfg.ExtraConfig)
+		if conf == nil {
+			return next(ctx, cfg, h)
+		}
+		if cfg.TLS != nil && !cfg.TLS.IsDisabled {
+			l.Warning("certs: the tls section of the service config is ignored, the certificates of", namespace, "are served")
+		}
+
+		s, err := newStore(*conf)
+		if err != nil {
+			return fmt.Errorf("certs: %s", err)
+		}
+		for name, notAfter := range s.expiry() {
+			l.Info(fmt.Sprintf("certs: serving %s, valid until %s", name, notAfter.Format(time.RFC3339)))
+		}
+
+		activeMu.Lock()
+		active = s
+		activeMu.Unlock()
+		defer func() {
+			activeMu.Lock()
+			if active == s {
+				active = nil
+			}
+			activeMu.Unlock()
+		}()
+
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		if conf.ReloadInterval > 0 {
+			go s.watch(ctx, l, conf.ReloadInterval)
+		}
+
+		return s.serve(ctx, cfg, misdirected(s, h))
+	}
+}
+
+//watch reload the certificates with changed files until the context is done
+func (s *store) watch(ctx context.Context, l logging.Logger, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		reloaded, err := s.reload()
+		for _, name := range reloaded {
+			l.Info("certs: certificate reloaded:", name)
+		}
+		if err != nil {
+			l.Error("certs: keeping the previous certificates:", err.Error())
+		}
+	}
+}
+
+//serve run the TLS server on the service port, with the timeouts of the service config, until the
+//context is done
+func (s *store) serve(ctx context.Context, cfg config.ServiceConfig, h http.Handler) error {
+	srv := &http.Server{
+		Addr:              fmt.Sprintf(":%d", cfg.Port),
+		Handler:           h,
+		TLSConfig:         s.tlsConfig(),
+		ReadTimeout:       cfg.ReadTimeout,
+		WriteTimeout:      cfg.WriteTimeout,
+		ReadHeaderTimeout: cfg.ReadHeaderTimeout,
+		IdleTimeout:       cfg.IdleTimeout,
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- srv.ListenAndServeTLS("", "")
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return srv.Shutdown(context.Background())
+	}
+}
+
+//misdirected reject the requests for a host with a different certificate than the one negotiated.
+//The HTTP/2 clients reuse the connections across the hosts of a certificate, so a request could
+//reach a host requiring client certificates through a handshake that did not ask for them
+func misdirected(s *store, h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.TLS != nil && s.lookup(r.TLS.ServerName) != s.lookup(hostname(r.Host)) {
+			http.Error(w, http.StatusText(http.StatusMisdirectedRequest), http.StatusMisdirectedRequest)
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+func hostname(hostport string) string {
+	if host, _, err := net.SplitHostPort(hostport); err == nil {
+		return host
+	}
+	return strings.Trim(hostport, "[]")
+}
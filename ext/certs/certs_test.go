@@ -0,0 +1,341 @@
+package certs
+
+import (
+	"bufio"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/devopsfaith/krakend/config"
+	"github.com/devopsfaith/krakend/logging"
+	"github.com/stretchr/testify/assert"
+)
+
+var logger, _ = logging.NewLogger("CRITICAL", ioutil.Discard, "")
+
+func TestStore_lookup(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	s, err := newStore(xtraConfig{Certificates: []certConfig{
+		writeCert(t, dir, "default", nil, nil, nil),
+		writeCert(t, dir, "api", []string{"api.example.com"}, nil, nil),
+		writeCert(t, dir, "wildcard", []string{"*.example.com"}, nil, nil),
+	}})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	for host, name := range map[string]string{
+		"api.example.com":   "api",
+		"API.example.com.":  "api",
+		"www.example.com":   "wildcard",
+		"a.b.example.com":   "default",
+		"example.com":       "default",
+		"":                  "default",
+		"default":           "default",
+		"unknown.localhost": "default",
+	} {
+		assert.Equal(t, name, s.lookup(host).name, host)
+	}
+}
+
+func TestStore_reload(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	c := writeCert(t, dir, "api", []string{"api.example.com"}, nil, nil)
+	s, err := newStore(xtraConfig{Certificates: []certConfig{c}})
+	if !assert.NoError(t, err) {
+		return
+	}
+	first := s.expiry()["api"]
+
+	reloaded, err := s.reload()
+	assert.NoError(t, err)
+	assert.Empty(t, reloaded)
+
+	// a broken file keeps the previous certificate
+	time.Sleep(10 * time.Millisecond)
+	if err := ioutil.WriteFile(c.PublicKey, []byte("broken"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	reloaded, err = s.reload()
+	assert.Error(t, err)
+	assert.Empty(t, reloaded)
+	assert.Equal(t, first, s.expiry()["api"])
+
+	time.Sleep(10 * time.Millisecond)
+	writeCertFiles(t, c, "api", []string{"api.example.com"}, 48*time.Hour, nil)
+	reloaded, err = s.reload()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"api"}, reloaded)
+	assert.True(t, s.expiry()["api"].After(first))
+}
+
+func TestStore_clientCA(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	a := writeCert(t, dir, "a", nil, nil, nil)
+	a.ClientAuth = tls.RequireAndVerifyClientCert
+	_, err := newStore(xtraConfig{Certificates: []certConfig{a}})
+	assert.EqualError(t, err, "certificate "+a.PublicKey+": client_ca is required to verify the client certificates")
+
+	ca := writeCert(t, dir, "ca", nil, nil, nil)
+	c := writeCert(t, dir, "api", []string{"api.example.com"}, []string{ca.PublicKey}, nil)
+	c.ClientAuth = tls.RequireAndVerifyClientCert
+	s, err := newStore(xtraConfig{Certificates: []certConfig{writeCert(t, dir, "default", nil, nil, nil), c}})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	conf, _ := s.configForClient(&tls.ClientHelloInfo{ServerName: "api.example.com"})
+	assert.Equal(t, tls.RequireAndVerifyClientCert, conf.ClientAuth)
+	assert.NotNil(t, conf.ClientCAs)
+
+	conf, _ = s.configForClient(&tls.ClientHelloInfo{ServerName: "www.example.com"})
+	assert.Equal(t, tls.NoClientCert, conf.ClientAuth)
+}
+
+func TestRunServer(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	ca := writeCert(t, dir, "ca", nil, nil, nil)
+	client := writeCert(t, dir, "client", nil, nil, &ca)
+	public := writeCert(t, dir, "public", []string{"localhost"}, nil, nil)
+	private := writeCert(t, dir, "private", []string{"private.localhost"}, []string{ca.PublicKey}, nil)
+
+	port := freePort(t)
+	cfg := config.ServiceConfig{
+		Port: port,
+		ExtraConfig: config.ExtraConfig{
+			namespace: map[string]interface{}{
+				"reload_interval": "0s",
+				"certificates": []interface{}{
+					map[string]interface{}{"public_key": public.PublicKey, "private_key": public.PrivateKey},
+					map[string]interface{}{"public_key": private.PublicKey, "private_key": private.PrivateKey, "client_ca": []interface{}{ca.PublicKey}},
+				},
+			},
+		},
+	}
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	next := func(context.Context, config.ServiceConfig, http.Handler) error {
+		t.Error("the certificates should be served instead of the core tls")
+		return nil
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- RunServer(logger, next)(ctx, cfg, h) }()
+
+	pool := x509.NewCertPool()
+	for _, c := range []certConfig{public, private} {
+		b, _ := ioutil.ReadFile(c.PublicKey)
+		pool.AppendCertsFromPEM(b)
+	}
+	clientCert, _ := tls.LoadX509KeyPair(client.PublicKey, client.PrivateKey)
+	address := net.JoinHostPort("127.0.0.1", strconv.Itoa(port))
+	get := func(host string, certs ...tls.Certificate) (int, error) {
+		conn, err := tls.Dial("tcp", address, &tls.Config{ServerName: host, RootCAs: pool, Certificates: certs})
+		if err != nil {
+			return 0, err
+		}
+		defer conn.Close()
+		if _, err := conn.Write([]byte("GET / HTTP/1.1\r\nHost: " + host + "\r\nConnection: close\r\n\r\n")); err != nil {
+			return 0, err
+		}
+		resp, err := http.ReadResponse(bufio.NewReader(conn), nil)
+		if err != nil {
+			return 0, err
+		}
+		resp.Body.Close()
+		return resp.StatusCode, nil
+	}
+
+	var status int
+	var err error
+	for i := 0; i < 50; i++ {
+		if status, err = get("localhost"); err == nil {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, status)
+	assert.False(t, Expiry()["public"].IsZero())
+
+	// the private host requires a client certificate signed by its CA
+	_, err = get("private.localhost")
+	assert.Error(t, err)
+	status, err = get("private.localhost", clientCert)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, status)
+
+	cancel()
+	select {
+	case err := <-done:
+		assert.NoError(t, err)
+	case <-time.After(time.Second):
+		t.Error("the server should stop with the context")
+	}
+	assert.Empty(t, Expiry())
+}
+
+func TestMisdirected(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	s, err := newStore(xtraConfig{Certificates: []certConfig{
+		writeCert(t, dir, "public", []string{"localhost"}, nil, nil),
+		writeCert(t, dir, "private", []string{"private.localhost"}, nil, nil),
+	}})
+	if !assert.NoError(t, err) {
+		return
+	}
+	h := misdirected(s, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	for _, tc := range []struct {
+		sni    string
+		host   string
+		status int
+	}{
+		{"localhost", "localhost:8080", http.StatusOK},
+		{"private.localhost", "private.localhost", http.StatusOK},
+		{"localhost", "private.localhost", http.StatusMisdirectedRequest},
+		{"", "127.0.0.1:8080", http.StatusOK},
+	} {
+		req, _ := http.NewRequest("GET", "https://"+tc.host+"/", nil)
+		req.TLS = &tls.ConnectionState{ServerName: tc.sni}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		assert.Equal(t, tc.status, w.Code, tc.sni+" "+tc.host)
+	}
+}
+
+func TestConfigForClient(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	s, err := newStore(xtraConfig{Certificates: []certConfig{
+		writeCert(t, dir, "public", []string{"localhost"}, nil, nil),
+		writeCert(t, dir, "private", []string{"private.localhost"}, nil, nil),
+	}})
+	if !assert.NoError(t, err) {
+		return
+	}
+	h := Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	req, _ := http.NewRequest("GET", "https://private.localhost/", nil)
+	req.TLS = &tls.ConnectionState{ServerName: "localhost"}
+
+	// the other listeners keep their own config while the certificates are not served
+	c, err := ConfigForClient(&tls.ClientHelloInfo{ServerName: "private.localhost"})
+	assert.NoError(t, err)
+	assert.Nil(t, c)
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	activeMu.Lock()
+	active = s
+	activeMu.Unlock()
+	defer func() {
+		activeMu.Lock()
+		active = nil
+		activeMu.Unlock()
+	}()
+
+	c, err = ConfigForClient(&tls.ClientHelloInfo{ServerName: "private.localhost"})
+	assert.NoError(t, err)
+	assert.Equal(t, s.lookup("private.localhost").config, c)
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusMisdirectedRequest, w.Code)
+}
+
+func tempDir(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "certs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+func freePort(t *testing.T) int {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	return l.Addr().(*net.TCPAddr).Port
+}
+
+//writeCert write a certificate valid for a day, self signed unless a parent is given
+func writeCert(t *testing.T, dir, name string, hosts, clientCA []string, parent *certConfig) certConfig {
+	c := certConfig{
+		PublicKey:  filepath.Join(dir, name+".pem"),
+		PrivateKey: filepath.Join(dir, name+".key"),
+		ClientCA:   clientCA,
+	}
+	writeCertFiles(t, c, name, hosts, 24*time.Hour, parent)
+	return c
+}
+
+func writeCertFiles(t *testing.T, c certConfig, name string, hosts []string, validity time.Duration, parent *certConfig) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: name},
+		DNSNames:              hosts,
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(validity),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  parent == nil,
+	}
+
+	signer, signerKey := tmpl, interface{}(key)
+	if parent != nil {
+		pair, err := tls.LoadX509KeyPair(parent.PublicKey, parent.PrivateKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		signer, _ = x509.ParseCertificate(pair.Certificate[0])
+		signerKey = pair.PrivateKey
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, signer, &key.PublicKey, signerKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ioutil.WriteFile(c.PublicKey, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(c.PrivateKey, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600); err != nil {
+		t.Fatal(err)
+	}
+}
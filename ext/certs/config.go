@@ -0,0 +1,193 @@
+package certs
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/devopsfaith/krakend-ce/ext/lint"
+	"github.com/devopsfaith/krakend/config"
+)
+
+const (
+	namespace = "github_com/sahalzain/krakend-tls"
+
+	defaultReloadInterval = 30 * time.Second
+)
+
+var (
+	tlsVersions = []string{"SSL3.0", "TLS10", "TLS11", "TLS12", "TLS13"}
+
+	clientAuthTypes = map[string]tls.ClientAuthType{
+		"none":            tls.NoClientCert,
+		"request":         tls.RequestClientCert,
+		"require_any":     tls.RequireAnyClientCert,
+		"verify_if_given": tls.VerifyClientCertIfGiven,
+		"require":         tls.RequireAndVerifyClientCert,
+	}
+)
+
+//Linter strict validation of the config block. An invalid client certificate setup leaves the
+//hosts without their client verification, so the block is validated as a security one
+var Linter = lint.Linter{
+	Namespace: namespace,
+	Scope:     lint.ScopeService,
+	Security:  true,
+	Fields: []lint.Field{
+		{Name: "certificates", Kind: lint.KindArray, Required: true, Check: lint.All(lint.NotEmpty, lint.Items(certificateField)), Description: "the first certificate is served when no other matches the SNI host"},
+		{Name: "min_version", Kind: lint.KindString, Check: lint.OneOf(tlsVersions...)},
+		{Name: "max_version", Kind: lint.KindString, Check: lint.OneOf(tlsVersions...)},
+		{Name: "reload_interval", Kind: lint.KindDuration, Check: lint.Func(nonNegative), Description: "period checking the certificate files for changes, 0 disables the reloads"},
+	},
+	Check: func(tmp map[string]interface{}) []lint.Issue {
+		var issues []lint.Issue
+		seen := map[string]bool{}
+		for i, v := range tmp["certificates"].([]interface{}) {
+			for _, h := range parseStrings(v.(map[string]interface{})["hosts"]) {
+				h = strings.ToLower(h)
+				if seen[h] {
+					issues = append(issues, lint.Issue{Field: fmt.Sprintf("certificates.%d.hosts", i), Msg: fmt.Sprintf("host %q served by several certificates", h)})
+				}
+				seen[h] = true
+			}
+		}
+		return issues
+	},
+}
+
+var (
+	//nonEmpty check of the hosts and CA files
+	nonEmpty = lint.NewCheck(func(v interface{}) error {
+		if s, ok := v.(string); !ok || s == "" {
+			return fmt.Errorf("must be a non empty string")
+		}
+		return nil
+	}, map[string]interface{}{"type": "string", "minLength": 1})
+
+	certificateField = lint.Field{Kind: lint.KindObject, Check: lint.Func(verifiedClients), Fields: []lint.Field{
+		{Name: "public_key", Kind: lint.KindString, Required: true, Check: lint.NotEmpty},
+		{Name: "private_key", Kind: lint.KindString, Required: true, Check: lint.NotEmpty},
+		{Name: "hosts", Kind: lint.KindArray, Check: lint.Values(nonEmpty), Description: "SNI hosts served, *.example.com matches a single label. The names of the certificate when empty"},
+		{Name: "client_ca", Kind: lint.KindArray, Check: lint.Values(nonEmpty), Description: "CA files verifying the client certificates"},
+		{Name: "client_auth", Kind: lint.KindString, Check: lint.OneOf("none", "request", "require_any", "verify_if_given", "require"), Description: "require when client_ca is set, none otherwise"},
+	}}
+)
+
+//xtraConfig the certificates served by the main listener
+type xtraConfig struct {
+	Certificates   []certConfig
+	MinVersion     uint16
+	MaxVersion     uint16
+	ReloadInterval time.Duration
+}
+
+//certConfig a certificate along with the client verification of its hosts
+type certConfig struct {
+	PublicKey  string
+	PrivateKey string
+	Hosts      []string
+	ClientCA   []string
+	ClientAuth tls.ClientAuthType
+}
+
+func configGetter(cfg config.ExtraConfig) *xtraConfig {
+	v, ok := cfg[namespace]
+	if !ok {
+		return nil
+	}
+	tmp, ok := v.(map[string]interface{})
+	if !ok {
+		return nil
+	}
+
+	conf := xtraConfig{
+		MinVersion:     parseTLSVersion(tmp["min_version"], tls.VersionTLS12),
+		MaxVersion:     parseTLSVersion(tmp["max_version"], tls.VersionTLS13),
+		ReloadInterval: defaultReloadInterval,
+	}
+	if s, ok := tmp["reload_interval"].(string); ok {
+		if d, err := time.ParseDuration(s); err == nil && d >= 0 {
+			conf.ReloadInterval = d
+		}
+	}
+
+	arr, _ := tmp["certificates"].([]interface{})
+	for _, v := range arr {
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		c := certConfig{
+			Hosts:    parseStrings(m["hosts"]),
+			ClientCA: parseStrings(m["client_ca"]),
+		}
+		c.PublicKey, _ = m["public_key"].(string)
+		c.PrivateKey, _ = m["private_key"].(string)
+		if c.PublicKey == "" || c.PrivateKey == "" {
+			continue
+		}
+		if s, ok := m["client_auth"].(string); ok {
+			c.ClientAuth = clientAuthTypes[s]
+		} else if len(c.ClientCA) > 0 {
+			//the CAs are declared to verify the clients
+			c.ClientAuth = tls.RequireAndVerifyClientCert
+		}
+		conf.Certificates = append(conf.Certificates, c)
+	}
+
+	if len(conf.Certificates) == 0 {
+		return nil
+	}
+	return &conf
+}
+
+func parseStrings(v interface{}) []string {
+	arr, ok := v.([]interface{})
+	if !ok {
+		return nil
+	}
+	res := []string{}
+	for _, e := range arr {
+		if s, ok := e.(string); ok && s != "" {
+			res = append(res, s)
+		}
+	}
+	return res
+}
+
+func parseTLSVersion(v interface{}, def uint16) uint16 {
+	switch v {
+	case "SSL3.0":
+		return tls.VersionSSL30
+	case "TLS10":
+		return tls.VersionTLS10
+	case "TLS11":
+		return tls.VersionTLS11
+	case "TLS12":
+		return tls.VersionTLS12
+	case "TLS13":
+		return tls.VersionTLS13
+	}
+	return def
+}
+
+//verifiedClients the client certificates are verified against the declared CAs, never the system ones
+func verifiedClients(v interface{}) error {
+	m := v.(map[string]interface{})
+	switch clientAuthTypes[fmt.Sprint(m["client_auth"])] {
+	case tls.VerifyClientCertIfGiven, tls.RequireAndVerifyClientCert:
+		if len(parseStrings(m["client_ca"])) == 0 {
+			return errors.New("client_ca is required to verify the client certificates")
+		}
+	}
+	return nil
+}
+
+func nonNegative(v interface{}) error {
+	if d, _ := time.ParseDuration(v.(string)); d < 0 {
+		return errors.New("must not be negative")
+	}
+	return nil
+}
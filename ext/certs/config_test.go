@@ -0,0 +1,86 @@
+package certs
+
+import (
+	"crypto/tls"
+	"testing"
+	"time"
+
+	"github.com/devopsfaith/krakend-ce/ext/lint"
+	"github.com/devopsfaith/krakend/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConfigGetter(t *testing.T) {
+	conf := configGetter(config.ExtraConfig{
+		namespace: map[string]interface{}{
+			"min_version":     "TLS13",
+			"reload_interval": "1m",
+			"certificates": []interface{}{
+				map[string]interface{}{"public_key": "a.pem", "private_key": "a.key", "hosts": []interface{}{"a.example.com", 1}},
+				map[string]interface{}{"public_key": "b.pem", "private_key": "b.key", "client_ca": []interface{}{"ca.pem"}},
+				map[string]interface{}{"public_key": "c.pem", "private_key": "c.key", "client_ca": []interface{}{"ca.pem"}, "client_auth": "verify_if_given"},
+				map[string]interface{}{"public_key": "d.pem"},
+				"wrong",
+			},
+		},
+	})
+
+	assert.Equal(t, &xtraConfig{
+		Certificates: []certConfig{
+			{PublicKey: "a.pem", PrivateKey: "a.key", Hosts: []string{"a.example.com"}},
+			{PublicKey: "b.pem", PrivateKey: "b.key", ClientCA: []string{"ca.pem"}, ClientAuth: tls.RequireAndVerifyClientCert},
+			{PublicKey: "c.pem", PrivateKey: "c.key", ClientCA: []string{"ca.pem"}, ClientAuth: tls.VerifyClientCertIfGiven},
+		},
+		MinVersion:     tls.VersionTLS13,
+		MaxVersion:     tls.VersionTLS13,
+		ReloadInterval: time.Minute,
+	}, conf)
+
+	assert.Nil(t, configGetter(config.ExtraConfig{}))
+	assert.Nil(t, configGetter(config.ExtraConfig{namespace: map[string]interface{}{"certificates": []interface{}{}}}))
+}
+
+func TestLinter(t *testing.T) {
+	errs := lint.Lint(config.ServiceConfig{
+		ExtraConfig: config.ExtraConfig{
+			namespace: map[string]interface{}{
+				"reload_interval": "-1s",
+				"certificates": []interface{}{
+					map[string]interface{}{"public_key": "a.pem", "private_key": "a.key", "hosts": []interface{}{"a.example.com"}},
+					map[string]interface{}{"public_key": "b.pem", "private_key": "b.key", "hosts": []interface{}{"A.example.com"}},
+				},
+			},
+		},
+	}, Linter)
+
+	assert.Len(t, errs, 1)
+	assert.Equal(t, "reload_interval", errs[0].Field)
+
+	errs = lint.Lint(config.ServiceConfig{
+		ExtraConfig: config.ExtraConfig{
+			namespace: map[string]interface{}{
+				"certificates": []interface{}{
+					map[string]interface{}{"public_key": "a.pem", "private_key": "a.key", "hosts": []interface{}{"a.example.com"}},
+					map[string]interface{}{"public_key": "b.pem", "private_key": "b.key", "hosts": []interface{}{"A.example.com"}},
+				},
+			},
+		},
+	}, Linter)
+
+	assert.Len(t, errs, 1)
+	assert.Equal(t, "service: github_com/sahalzain/krakend-tls.certificates.1.hosts: host \"a.example.com\" served by several certificates", errs[0].Error())
+
+	errs = lint.Lint(config.ServiceConfig{
+		ExtraConfig: config.ExtraConfig{
+			namespace: map[string]interface{}{
+				"certificates": []interface{}{
+					map[string]interface{}{"public_key": "a.pem", "private_key": "a.key", "client_auth": "require"},
+				},
+			},
+		},
+	}, Linter)
+
+	assert.Len(t, errs, 1)
+	assert.Equal(t, "item 0: client_ca is required to verify the client certificates", errs[0].Msg)
+	assert.True(t, errs[0].Security)
+}
@@ -0,0 +1,212 @@
+package certs
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+//store the certificates of the listener, selected by the SNI host of every handshake and reloaded
+//from disk when their files change
+type store struct {
+	cfg xtraConfig
+
+	mu sync.RWMutex
+	//certs the loaded certificates, in the config order. The first one is the default
+	certs []*certificate
+}
+
+//certificate a loaded certificate and the TLS config of its hosts
+type certificate struct {
+	name  string
+	hosts []string
+	//notAfter expiry of the leaf
+	notAfter time.Time
+
+	config *tls.Config
+	//stamp modification of the files the certificate was loaded from
+	stamp string
+}
+
+//newStore load every certificate of the config
+func newStore(cfg xtraConfig) (*store, error) {
+	if len(cfg.Certificates) == 0 {
+		return nil, errors.New("no certificates")
+	}
+	s := &store{cfg: cfg, certs: make([]*certificate, len(cfg.Certificates))}
+	for i, c := range cfg.Certificates {
+		cert, err := s.load(c)
+		if err != nil {
+			return nil, err
+		}
+		s.certs[i] = cert
+	}
+	return s, nil
+}
+
+//tlsConfig the config of the server, handing the certificate and client verification of the SNI host
+func (s *store) tlsConfig() *tls.Config {
+	return &tls.Config{
+		MinVersion:         s.cfg.MinVersion,
+		MaxVersion:         s.cfg.MaxVersion,
+		NextProtos:         []string{"h2", "http/1.1"},
+		GetConfigForClient: s.configForClient,
+	}
+}
+
+func (s *store) configForClient(hello *tls.ClientHelloInfo) (*tls.Config, error) {
+	return s.lookup(hello.ServerName).config, nil
+}
+
+//lookup the certificate of the host: an exact match, then a wildcard one and the default otherwise
+func (s *store) lookup(host string) *certificate {
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if host != "" {
+		for _, c := range s.certs {
+			for _, h := range c.hosts {
+				if h == host {
+					return c
+				}
+			}
+		}
+		if i := strings.Index(host, "."); i > 0 {
+			wildcard := "*" + host[i:]
+			for _, c := range s.certs {
+				for _, h := range c.hosts {
+					if h == wildcard {
+						return c
+					}
+				}
+			}
+		}
+	}
+	return s.certs[0]
+}
+
+//reload load again the certificates with changed files. A certificate failing to load keeps the
+//previous version, so a half written file does not break the listener
+func (s *store) reload() ([]string, error) {
+	s.mu.RLock()
+	current := make([]*certificate, len(s.certs))
+	copy(current, s.certs)
+	s.mu.RUnlock()
+
+	var reloaded, errs []string
+	for i, c := range s.cfg.Certificates {
+		if stamp(c) == current[i].stamp {
+			continue
+		}
+		cert, err := s.load(c)
+		if err != nil {
+			errs = append(errs, err.Error())
+			continue
+		}
+		current[i] = cert
+		reloaded = append(reloaded, cert.name)
+	}
+
+	if len(reloaded) > 0 {
+		s.mu.Lock()
+		s.certs = current
+		s.mu.Unlock()
+	}
+	if len(errs) > 0 {
+		return reloaded, errors.New(strings.Join(errs, "; "))
+	}
+	return reloaded, nil
+}
+
+//expiry the expiry of every certificate, by name
+func (s *store) expiry() map[string]time.Time {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	res := make(map[string]time.Time, len(s.certs))
+	for _, c := range s.certs {
+		res[c.name] = c.notAfter
+	}
+	return res
+}
+
+func (s *store) load(c certConfig) (*certificate, error) {
+	//the stamp is taken first, so a file changing while it is read is loaded again
+	st := stamp(c)
+
+	pair, err := tls.LoadX509KeyPair(c.PublicKey, c.PrivateKey)
+	if err != nil {
+		return nil, fmt.Errorf("certificate %s: %s", c.PublicKey, err)
+	}
+	leaf, err := x509.ParseCertificate(pair.Certificate[0])
+	if err != nil {
+		return nil, fmt.Errorf("certificate %s: %s", c.PublicKey, err)
+	}
+	pair.Leaf = leaf
+
+	cert := &certificate{
+		name:     leaf.Subject.CommonName,
+		hosts:    append([]string{}, c.Hosts...),
+		notAfter: leaf.NotAfter,
+		stamp:    st,
+		config: &tls.Config{
+			Certificates: []tls.Certificate{pair},
+			MinVersion:   s.cfg.MinVersion,
+			MaxVersion:   s.cfg.MaxVersion,
+			NextProtos:   []string{"h2", "http/1.1"},
+			ClientAuth:   c.ClientAuth,
+		},
+	}
+	if len(cert.hosts) == 0 {
+		cert.hosts = append(cert.hosts, leaf.DNSNames...)
+	}
+	if len(cert.hosts) == 0 && leaf.Subject.CommonName != "" {
+		cert.hosts = []string{leaf.Subject.CommonName}
+	}
+	for i, h := range cert.hosts {
+		cert.hosts[i] = strings.ToLower(h)
+	}
+	if cert.name == "" && len(cert.hosts) > 0 {
+		cert.name = cert.hosts[0]
+	}
+	if cert.name == "" {
+		cert.name = c.PublicKey
+	}
+
+	if len(c.ClientCA) > 0 {
+		pool := x509.NewCertPool()
+		for _, f := range c.ClientCA {
+			b, err := ioutil.ReadFile(f)
+			if err != nil {
+				return nil, fmt.Errorf("certificate %s: client CA: %s", c.PublicKey, err)
+			}
+			if !pool.AppendCertsFromPEM(b) {
+				return nil, fmt.Errorf("certificate %s: client CA %s: no certificates found", c.PublicKey, f)
+			}
+		}
+		cert.config.ClientCAs = pool
+	} else if c.ClientAuth == tls.VerifyClientCertIfGiven || c.ClientAuth == tls.RequireAndVerifyClientCert {
+		return nil, fmt.Errorf("certificate %s: client_ca is required to verify the client certificates", c.PublicKey)
+	}
+	return cert, nil
+}
+
+//stamp the modification time and size of the files of the certificate
+func stamp(c certConfig) string {
+	files := append([]string{c.PublicKey, c.PrivateKey}, c.ClientCA...)
+	parts := make([]string, len(files))
+	for i, f := range files {
+		if fi, err := os.Stat(f); err == nil {
+			parts[i] = fmt.Sprintf("%d-%d", fi.ModTime().UnixNano(), fi.Size())
+		}
+	}
+	return strings.Join(parts, ",")
+}
@@ -0,0 +1,151 @@
+//Package chain places the middlewares of the gateway stacks. Every stack has a default order, the
+//one of its registered middlewares, and the service config can reorder, disable or insert them by
+//name without changing the code assembling the stacks.
+package chain
+
+import (
+	"fmt"
+	"sort"
+)
+
+//Entry a middleware registered in a stack
+type Entry struct {
+	Name string
+	//Optional the middleware is only part of the stack when the config places it
+	Optional bool
+	//Declared the config declares the middleware settings at a level where it runs, so removing it
+	//from the stack has to be allowed explicitly
+	Declared bool
+}
+
+//Order the names of the middlewares of the stack placed as configured, outermost first
+func (c Config) Order(stack string, entries []Entry) ([]string, error) {
+	names, err := Order(entries, c[stack])
+	if err != nil {
+		return nil, fmt.Errorf("%s stack: %s", stack, err)
+	}
+	return names, nil
+}
+
+//Order the names of the middlewares placed as configured, outermost first. The explicit order
+//replaces the default one, then the disabled middlewares are removed and the after and before
+//placements are applied, in that order. Removing a declared middleware is an error unless the
+//config allows it
+func Order(entries []Entry, conf StackConfig) ([]string, error) {
+	known := map[string]bool{}
+	names := []string{}
+	for _, e := range entries {
+		known[e.Name] = true
+		if !e.Optional {
+			names = append(names, e.Name)
+		}
+	}
+	check := func(names ...string) error {
+		for _, n := range names {
+			if !known[n] {
+				return fmt.Errorf("unknown middleware %q", n)
+			}
+		}
+		return nil
+	}
+
+	if len(conf.Order) > 0 {
+		if err := check(conf.Order...); err != nil {
+			return nil, err
+		}
+		seen := map[string]bool{}
+		for _, n := range conf.Order {
+			if seen[n] {
+				return nil, fmt.Errorf("middleware %q listed twice", n)
+			}
+			seen[n] = true
+		}
+		names = append([]string{}, conf.Order...)
+	}
+
+	if err := check(conf.Disable...); err != nil {
+		return nil, err
+	}
+	disabled := map[string]bool{}
+	for _, n := range conf.Disable {
+		disabled[n] = true
+		names = remove(names, n)
+	}
+
+	for _, n := range sortedKeys(conf.After) {
+		if _, ok := conf.Before[n]; ok {
+			return nil, fmt.Errorf("middleware %q placed both before and after", n)
+		}
+	}
+
+	placements := []struct {
+		targets map[string]string
+		offset  int
+	}{
+		{targets: conf.After, offset: 1},
+		{targets: conf.Before, offset: 0},
+	}
+	for _, p := range placements {
+		for _, n := range sortedKeys(p.targets) {
+			target := p.targets[n]
+			if err := check(n, target); err != nil {
+				return nil, err
+			}
+			if disabled[n] {
+				return nil, fmt.Errorf("middleware %q is disabled", n)
+			}
+			if n == target {
+				return nil, fmt.Errorf("middleware %q placed next to itself", n)
+			}
+
+			names = remove(names, n)
+			i := index(names, target)
+			if i < 0 {
+				return nil, fmt.Errorf("middleware %q placed next to %q, which is not in the stack", n, target)
+			}
+			i += p.offset
+			names = append(names[:i], append([]string{n}, names[i:]...)...)
+		}
+	}
+
+	if err := check(conf.AllowRemoval...); err != nil {
+		return nil, err
+	}
+	allowed := map[string]bool{}
+	for _, n := range conf.AllowRemoval {
+		allowed[n] = true
+	}
+	for _, e := range entries {
+		if e.Optional || !e.Declared || allowed[e.Name] || index(names, e.Name) >= 0 {
+			continue
+		}
+		return nil, fmt.Errorf("middleware %q removed while its config is declared, add it to allow_removal to run without it", e.Name)
+	}
+
+	return names, nil
+}
+
+func index(names []string, name string) int {
+	for i, n := range names {
+		if n == name {
+			return i
+		}
+	}
+	return -1
+}
+
+func remove(names []string, name string) []string {
+	if i := index(names, name); i >= 0 {
+		return append(names[:i], names[i+1:]...)
+	}
+	return names
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
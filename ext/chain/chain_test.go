@@ -0,0 +1,167 @@
+package chain
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var testEntries = []Entry{
+	{Name: "botdetector"},
+	{Name: "keyauth"},
+	{Name: "jose"},
+	{Name: "audit", Optional: true},
+	{Name: "opa"},
+}
+
+func TestOrder(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		conf StackConfig
+		want []string
+	}{
+		{
+			name: "default",
+			want: []string{"botdetector", "keyauth", "jose", "opa"},
+		},
+		{
+			name: "explicit order",
+			conf: StackConfig{Order: []string{"jose", "audit", "keyauth"}},
+			want: []string{"jose", "audit", "keyauth"},
+		},
+		{
+			name: "disable",
+			conf: StackConfig{Disable: []string{"botdetector", "opa"}},
+			want: []string{"keyauth", "jose"},
+		},
+		{
+			name: "move after",
+			conf: StackConfig{After: map[string]string{"keyauth": "jose"}},
+			want: []string{"botdetector", "jose", "keyauth", "opa"},
+		},
+		{
+			name: "move before",
+			conf: StackConfig{Before: map[string]string{"opa": "botdetector"}},
+			want: []string{"opa", "botdetector", "keyauth", "jose"},
+		},
+		{
+			name: "insert optional",
+			conf: StackConfig{
+				After:  map[string]string{"audit": "opa"},
+				Before: map[string]string{"keyauth": "audit"},
+			},
+			want: []string{"botdetector", "jose", "opa", "keyauth", "audit"},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			names, err := Order(testEntries, tc.conf)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.want, names)
+		})
+	}
+}
+
+func TestOrder_errors(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		conf StackConfig
+		err  string
+	}{
+		{
+			name: "unknown",
+			conf: StackConfig{Disable: []string{"cors"}},
+			err:  `unknown middleware "cors"`,
+		},
+		{
+			name: "unknown target",
+			conf: StackConfig{After: map[string]string{"jose": "cors"}},
+			err:  `unknown middleware "cors"`,
+		},
+		{
+			name: "duplicated",
+			conf: StackConfig{Order: []string{"jose", "opa", "jose"}},
+			err:  `middleware "jose" listed twice`,
+		},
+		{
+			name: "disabled target",
+			conf: StackConfig{Disable: []string{"jose"}, After: map[string]string{"keyauth": "jose"}},
+			err:  `middleware "keyauth" placed next to "jose", which is not in the stack`,
+		},
+		{
+			name: "disabled and placed",
+			conf: StackConfig{Disable: []string{"jose"}, After: map[string]string{"jose": "opa"}},
+			err:  `middleware "jose" is disabled`,
+		},
+		{
+			name: "before and after",
+			conf: StackConfig{After: map[string]string{"jose": "opa"}, Before: map[string]string{"jose": "keyauth"}},
+			err:  `middleware "jose" placed both before and after`,
+		},
+		{
+			name: "itself",
+			conf: StackConfig{Before: map[string]string{"jose": "jose"}},
+			err:  `middleware "jose" placed next to itself`,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := Order(testEntries, tc.conf)
+			assert.EqualError(t, err, tc.err)
+		})
+	}
+}
+
+func TestOrder_declared(t *testing.T) {
+	entries := []Entry{
+		{Name: "botdetector"},
+		{Name: "keyauth", Declared: true},
+		{Name: "jose"},
+		{Name: "audit", Optional: true, Declared: true},
+		{Name: "opa", Declared: true},
+	}
+
+	for _, tc := range []struct {
+		name string
+		conf StackConfig
+		err  string
+	}{
+		{
+			name: "disabled",
+			conf: StackConfig{Disable: []string{"jose", "opa"}},
+			err:  `middleware "opa" removed while its config is declared, add it to allow_removal to run without it`,
+		},
+		{
+			name: "left out of the order",
+			conf: StackConfig{Order: []string{"botdetector", "opa"}},
+			err:  `middleware "keyauth" removed while its config is declared, add it to allow_removal to run without it`,
+		},
+		{
+			name: "unknown allowed",
+			conf: StackConfig{Disable: []string{"opa"}, AllowRemoval: []string{"cors"}},
+			err:  `unknown middleware "cors"`,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := Order(entries, tc.conf)
+			assert.EqualError(t, err, tc.err)
+		})
+	}
+
+	names, err := Order(entries, StackConfig{Disable: []string{"jose"}})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"botdetector", "keyauth", "opa"}, names)
+
+	names, err = Order(entries, StackConfig{Order: []string{"opa"}, AllowRemoval: []string{"keyauth"}})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"opa"}, names)
+}
+
+func TestConfig_Order(t *testing.T) {
+	conf := Config{StackHandler: {Disable: []string{"cors"}}}
+
+	_, err := conf.Order(StackHandler, testEntries)
+	assert.EqualError(t, err, `handler stack: unknown middleware "cors"`)
+
+	names, err := conf.Order(StackProxy, []Entry{{Name: "proxy"}})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"proxy"}, names)
+}
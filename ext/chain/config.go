@@ -0,0 +1,138 @@
+package chain
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/devopsfaith/krakend-ce/ext/lint"
+	"github.com/devopsfaith/krakend/config"
+)
+
+const namespace = "github_com/sahalzain/krakend-middlewares"
+
+//Names of the middleware stacks assembled by the gateway
+const (
+	//StackEngine the hooks registering the router engine middlewares
+	StackEngine = "engine"
+	//StackHandler the endpoint handler middlewares
+	StackHandler = "handler"
+	//StackProxy the endpoint proxy middlewares
+	StackProxy = "proxy"
+	//StackBackend the backend proxy middlewares
+	StackBackend = "backend"
+)
+
+//Stacks the names of the middleware stacks, in the config block order
+var Stacks = []string{StackEngine, StackHandler, StackProxy, StackBackend}
+
+//Linter strict validation of the config block. A misplaced middleware can run the endpoints
+//without their auth checks, so the block is validated as a security one
+var Linter = lint.Linter{
+	Namespace: namespace,
+	Scope:     lint.ScopeService,
+	Security:  true,
+	Fields: []lint.Field{
+		stackField(StackEngine),
+		stackField(StackHandler),
+		stackField(StackProxy),
+		stackField(StackBackend),
+	},
+}
+
+//name check of the middleware names
+var name = lint.NewCheck(func(v interface{}) error {
+	if s, ok := v.(string); !ok || s == "" {
+		return errors.New("must be a middleware name")
+	}
+	return nil
+}, map[string]interface{}{"type": "string", "minLength": 1})
+
+func stackField(stack string) lint.Field {
+	return lint.Field{
+		Name:        stack,
+		Kind:        lint.KindObject,
+		Description: fmt.Sprintf("placement of the %s middlewares, outermost first", stack),
+		Fields: []lint.Field{
+			{Name: "order", Kind: lint.KindArray, Check: lint.Values(name), Description: "the complete stack, the middlewares not listed are disabled"},
+			{Name: "disable", Kind: lint.KindArray, Check: lint.Values(name)},
+			{Name: "allow_removal", Kind: lint.KindArray, Check: lint.Values(name), Description: "the middlewares the order and disable settings may remove while their config is declared"},
+			{Name: "before", Kind: lint.KindObject, Check: lint.All(lint.Keys(name), lint.Values(name)), Description: "middlewares placed right outside the given one, so they run first"},
+			{Name: "after", Kind: lint.KindObject, Check: lint.All(lint.Keys(name), lint.Values(name)), Description: "middlewares placed right inside the given one, so they run next"},
+		},
+	}
+}
+
+//Config the placement of the middlewares of every stack
+type Config map[string]StackConfig
+
+//StackConfig the placement of the middlewares of a stack
+type StackConfig struct {
+	//Order the complete stack, outermost first. The default one is used when empty
+	Order []string
+	//Disable the middlewares removed from the stack
+	Disable []string
+	//AllowRemoval the middlewares removed from the stack even if their config is declared, e.g. the
+	//auth checks enforced by a sidecar
+	AllowRemoval []string
+	//Before the middlewares moved, or inserted, right outside the given one
+	Before map[string]string
+	//After the middlewares moved, or inserted, right inside the given one
+	After map[string]string
+}
+
+//ConfigGetter the placement of the middlewares declared in the service extra config, nil when
+//the default stacks are used
+func ConfigGetter(cfg config.ExtraConfig) Config {
+	v, ok := cfg[namespace]
+	if !ok {
+		return nil
+	}
+	tmp, ok := v.(map[string]interface{})
+	if !ok {
+		return nil
+	}
+
+	conf := Config{}
+	for _, stack := range Stacks {
+		s, ok := tmp[stack].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		conf[stack] = StackConfig{
+			Order:        parseNames(s["order"]),
+			Disable:      parseNames(s["disable"]),
+			AllowRemoval: parseNames(s["allow_removal"]),
+			Before:       parsePlacement(s["before"]),
+			After:        parsePlacement(s["after"]),
+		}
+	}
+	return conf
+}
+
+func parseNames(v interface{}) []string {
+	arr, ok := v.([]interface{})
+	if !ok {
+		return nil
+	}
+	res := []string{}
+	for _, e := range arr {
+		if s, ok := e.(string); ok && s != "" {
+			res = append(res, s)
+		}
+	}
+	return res
+}
+
+func parsePlacement(v interface{}) map[string]string {
+	m, ok := v.(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	res := map[string]string{}
+	for k, v := range m {
+		if s, ok := v.(string); ok && s != "" && k != "" {
+			res[k] = s
+		}
+	}
+	return res
+}
@@ -0,0 +1,50 @@
+package chain
+
+import (
+	"testing"
+
+	"github.com/devopsfaith/krakend-ce/ext/lint"
+	"github.com/devopsfaith/krakend/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConfigGetter(t *testing.T) {
+	conf := ConfigGetter(config.ExtraConfig{
+		namespace: map[string]interface{}{
+			"handler": map[string]interface{}{
+				"disable":       []interface{}{"botdetector", 1, ""},
+				"allow_removal": []interface{}{"botdetector"},
+				"after":         map[string]interface{}{"keyauth": "jose", "opa": 2},
+			},
+			"proxy":   map[string]interface{}{"order": []interface{}{"metrics", "proxy"}},
+			"unknown": map[string]interface{}{"order": []interface{}{"foo"}},
+			"backend": "wrong",
+		},
+	})
+
+	assert.Equal(t, Config{
+		StackHandler: {Disable: []string{"botdetector"}, AllowRemoval: []string{"botdetector"}, After: map[string]string{"keyauth": "jose"}},
+		StackProxy:   {Order: []string{"metrics", "proxy"}},
+	}, conf)
+
+	assert.Nil(t, ConfigGetter(config.ExtraConfig{}))
+}
+
+func TestLinter(t *testing.T) {
+	errs := lint.Lint(config.ServiceConfig{
+		ExtraConfig: config.ExtraConfig{
+			namespace: map[string]interface{}{
+				"handler": map[string]interface{}{
+					"disable": []interface{}{"botdetector", 1},
+					"after":   map[string]interface{}{"keyauth": "jose"},
+				},
+				"proxy": []interface{}{"metrics"},
+			},
+		},
+	}, Linter)
+
+	assert.Len(t, errs, 2)
+	assert.Len(t, errs.Security(), 2)
+	assert.Equal(t, "service: github_com/sahalzain/krakend-middlewares.handler.disable: item 1: must be a middleware name", errs[0].Error())
+	assert.Equal(t, "proxy", errs[1].Field)
+}
@@ -0,0 +1,127 @@
+package extauthz
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/devopsfaith/krakend-ce/ext/lint"
+	"github.com/devopsfaith/krakend-ce/ext/service"
+	"github.com/devopsfaith/krakend/config"
+)
+
+const (
+	namespace            = "github_com/sahalzain/krakend-extauthz"
+	defaultStatusOnError = http.StatusForbidden
+)
+
+type xtraConfig struct {
+	ServiceAddress         string
+	PathPrefix             string
+	AllowedHeaders         []string
+	AllowedUpstreamHeaders []string
+	AllowedClientHeaders   []string
+	MaxRequestBytes        int
+	StatusOnError          int
+	FailOpen               bool
+	Client                 service.ClientConfig
+	Breaker                *service.BreakerConfig
+	Service                service.Authorizer
+}
+
+func configGetter(cfg config.ExtraConfig) *xtraConfig {
+	v, ok := cfg[namespace]
+	if !ok {
+		return nil
+	}
+	tmp, ok := v.(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	conf := xtraConfig{
+		StatusOnError: defaultStatusOnError,
+	}
+
+	if sa, ok := tmp["service_address"].(string); ok {
+		conf.ServiceAddress = sa
+	} else {
+		return nil
+	}
+
+	if pp, ok := tmp["path_prefix"].(string); ok {
+		conf.PathPrefix = pp
+	}
+
+	conf.AllowedHeaders = stringList(tmp["allowed_headers"])
+	conf.AllowedUpstreamHeaders = stringList(tmp["allowed_upstream_headers"])
+	conf.AllowedClientHeaders = stringList(tmp["allowed_client_headers"])
+
+	if mb, ok := tmp["max_request_bytes"]; ok {
+		if mbi, err := strconv.Atoi(fmt.Sprintf("%v", mb)); err == nil {
+			conf.MaxRequestBytes = mbi
+		}
+	}
+
+	if se, ok := tmp["status_on_error"]; ok {
+		if sei, err := strconv.Atoi(fmt.Sprintf("%v", se)); err == nil {
+			conf.StatusOnError = sei
+		}
+	}
+
+	if fo, ok := tmp["fail_open"].(bool); ok {
+		conf.FailOpen = fo
+	}
+
+	conf.Breaker = service.ParseBreakerConfig(tmp)
+	conf.Client = service.ParseClientConfig(tmp)
+	client, err := service.NewClient(conf.Client)
+	if err != nil {
+		conf.Service = &service.DummyExtAuthz{Error: err}
+		return &conf
+	}
+
+	conf.Service = service.NewHTTPExtAuthz(service.ExtAuthzConfig{
+		Address:         conf.ServiceAddress,
+		PathPrefix:      conf.PathPrefix,
+		AllowedHeaders:  conf.AllowedHeaders,
+		MaxRequestBytes: conf.MaxRequestBytes,
+		Client:          client,
+		Breaker:         conf.Breaker,
+	})
+
+	return &conf
+}
+
+func stringList(v interface{}) []string {
+	l, ok := v.([]interface{})
+	if !ok {
+		return nil
+	}
+	res := make([]string, 0, len(l))
+	for _, s := range l {
+		if str, ok := s.(string); ok {
+			res = append(res, http.CanonicalHeaderKey(str))
+		}
+	}
+	return res
+}
+
+//Linter strict validation of the config block. The endpoint rejects every request when
+//service_address is missing
+var Linter = lint.Linter{
+	Namespace: namespace,
+	Scope:     lint.ScopeEndpoint,
+	Security:  true,
+	Fields: []lint.Field{
+		{Name: "service_address", Kind: lint.KindString, Required: true, Check: lint.NotEmpty},
+		{Name: "path_prefix", Kind: lint.KindString},
+		{Name: "allowed_headers", Kind: lint.KindArray},
+		{Name: "allowed_upstream_headers", Kind: lint.KindArray},
+		{Name: "allowed_client_headers", Kind: lint.KindArray},
+		{Name: "max_request_bytes", Kind: lint.KindInt, Check: lint.Min(0)},
+		{Name: "status_on_error", Kind: lint.KindInt, Check: lint.Range(100, 599)},
+		{Name: "fail_open", Kind: lint.KindBool},
+		service.ClientField,
+		service.BreakerField,
+	},
+}
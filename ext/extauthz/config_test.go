@@ -0,0 +1,39 @@
+package extauthz
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/devopsfaith/krakend/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConfigInvalidParse(t *testing.T) {
+	assert.Nil(t, configGetter(config.ExtraConfig{
+		namespace: "extauthz",
+	}), "Should nil")
+
+	assert.Nil(t, configGetter(config.ExtraConfig{
+		namespace: map[string]interface{}{
+			"path_prefix": "/check",
+		},
+	}), "Should nil")
+}
+
+func TestConfigParse(t *testing.T) {
+	cfg := configGetter(config.ExtraConfig{
+		namespace: map[string]interface{}{
+			"service_address":          "http://localhost:8080",
+			"allowed_headers":          []interface{}{"x-api-key"},
+			"allowed_upstream_headers": []interface{}{"x-user-id"},
+			"max_request_bytes":        1024,
+		},
+	})
+
+	assert.NotNil(t, cfg, "Should not nil")
+	assert.NotNil(t, cfg.Service)
+	assert.Equal(t, []string{"X-Api-Key"}, cfg.AllowedHeaders)
+	assert.Equal(t, []string{"X-User-Id"}, cfg.AllowedUpstreamHeaders)
+	assert.Equal(t, 1024, cfg.MaxRequestBytes)
+	assert.Equal(t, http.StatusForbidden, cfg.StatusOnError, "Should be default")
+}
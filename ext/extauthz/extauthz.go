@@ -0,0 +1,115 @@
+package extauthz
+
+import (
+	"net/http"
+
+	"github.com/devopsfaith/krakend-ce/ext/reqctx"
+	"github.com/devopsfaith/krakend-ce/ext/service"
+	"github.com/devopsfaith/krakend/config"
+	"github.com/devopsfaith/krakend/logging"
+	"github.com/devopsfaith/krakend/proxy"
+	krakendgin "github.com/devopsfaith/krakend/router/gin"
+	"github.com/gin-gonic/gin"
+)
+
+//HandlerFactory external authorization handler factory. Every request is checked against a service
+//implementing the Envoy ext_authz HTTP contract before reaching the next handler
+func HandlerFactory(l logging.Logger, next krakendgin.HandlerFactory) krakendgin.HandlerFactory {
+	return func(remote *config.EndpointConfig, p proxy.Proxy) gin.HandlerFunc {
+		handlerFunc := next(remote, p)
+
+		conf := configGetter(remote.ExtraConfig)
+
+		if conf == nil {
+			if _, ok := remote.ExtraConfig[namespace]; ok {
+				l.Error("[ExtAuthz] Invalid config for endpoint ", remote.Endpoint, ", rejecting every request")
+				return func(c *gin.Context) {
+					c.AbortWithStatus(http.StatusInternalServerError)
+				}
+			}
+			return func(c *gin.Context) {
+				handlerFunc(c)
+			}
+		}
+
+		l.Debug("[ExtAuthz] External authorization is enabled for endpoint ", remote.Endpoint)
+
+		return func(c *gin.Context) {
+			reqctx.FromGin(c)
+
+			ctx := service.WithRequestID(c.Request.Context(), c.Request.Header.Get(conf.Client.RequestIDHeader))
+			res, err := conf.Service.Check(ctx, c.Request)
+			if err != nil && conf.FailOpen && service.IsUnavailable(err) {
+				l.Warning("[ExtAuthz] Authorization service unavailable, failing open ", err)
+				conf.clearUpstreamHeaders(c.Request)
+				handlerFunc(c)
+				return
+			}
+
+			if err != nil {
+				l.Error("[ExtAuthz] Error checking authorization ", err)
+				c.AbortWithStatus(conf.StatusOnError)
+				return
+			}
+
+			bag := reqctx.FromRequest(c.Request)
+			bag.Set(reqctx.ExtAuthzStatus, res.StatusCode)
+
+			if !res.Allowed {
+				l.Error("[ExtAuthz] Request denied with status ", res.StatusCode)
+				conf.deny(c, res)
+				return
+			}
+
+			conf.clearUpstreamHeaders(c.Request)
+			added := map[string]interface{}{}
+			for _, k := range conf.AllowedUpstreamHeaders {
+				if vs, ok := res.Header[k]; ok && len(vs) > 0 {
+					c.Request.Header[k] = vs
+					added[k] = vs[0]
+				}
+			}
+			bag.Set(reqctx.ExtAuthzHeaders, added)
+
+			handlerFunc(c)
+		}
+	}
+}
+
+//clearUpstreamHeaders remove the upstream headers sent by the client, so only the ones set by the
+//authorization service reach the backends
+func (x *xtraConfig) clearUpstreamHeaders(r *http.Request) {
+	for _, k := range x.AllowedUpstreamHeaders {
+		r.Header.Del(k)
+	}
+}
+
+//deny returns the authorization service response to the client
+func (x *xtraConfig) deny(c *gin.Context, res *service.AuthzResponse) {
+	header := c.Writer.Header()
+	for k, vs := range res.Header {
+		if !x.allowedClientHeader(k) {
+			continue
+		}
+		header[k] = vs
+	}
+	c.Writer.WriteHeader(res.StatusCode)
+	c.Writer.Write(res.Body)
+	c.Abort()
+}
+
+func (x *xtraConfig) allowedClientHeader(k string) bool {
+	switch k {
+	case "Host", "Content-Length", "Transfer-Encoding", "Connection":
+		return false
+	}
+	if len(x.AllowedClientHeaders) == 0 {
+		return true
+	}
+	for _, h := range x.AllowedClientHeaders {
+		if h == k {
+			return true
+		}
+	}
+	return false
+}
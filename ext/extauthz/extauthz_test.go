@@ -0,0 +1,104 @@
+package extauthz
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/devopsfaith/krakend-ce/ext/reqctx"
+	"github.com/devopsfaith/krakend/config"
+	"github.com/devopsfaith/krakend/logging"
+	"github.com/devopsfaith/krakend/proxy"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func newHandler(extra map[string]interface{}, next gin.HandlerFunc) gin.HandlerFunc {
+	logger, _ := logging.NewLogger("CRITICAL", ioutil.Discard, "")
+	return HandlerFactory(logger, func(_ *config.EndpointConfig, _ proxy.Proxy) gin.HandlerFunc {
+		return next
+	})(&config.EndpointConfig{
+		Endpoint:    "/echo",
+		ExtraConfig: config.ExtraConfig{namespace: extra},
+	}, nil)
+}
+
+func TestHandler(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/check/echo", r.URL.Path)
+		assert.Equal(t, "", r.Header.Get("X-Ignored"))
+		if r.Header.Get("Authorization") != "Bearer valid" {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"error":"invalid token"}`))
+			return
+		}
+		w.Header().Set("X-User-Id", "user1")
+		w.Header().Set("X-Internal", "secret")
+	}))
+	defer srv.Close()
+
+	handler := newHandler(map[string]interface{}{
+		"service_address":          srv.URL,
+		"path_prefix":              "/check",
+		"allowed_upstream_headers": []interface{}{"X-User-Id"},
+	}, func(c *gin.Context) {
+		assert.Equal(t, "user1", c.Request.Header.Get("X-User-Id"))
+		assert.Equal(t, "", c.Request.Header.Get("X-Internal"))
+		headers, _ := reqctx.FromRequest(c.Request).Get(reqctx.ExtAuthzHeaders)
+		assert.Equal(t, map[string]interface{}{"X-User-Id": "user1"}, headers)
+		c.Status(http.StatusOK)
+	})
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request, _ = http.NewRequest("GET", "http://localhost:8000/echo", nil)
+	c.Request.Header.Set("Authorization", "Bearer valid")
+	c.Request.Header.Set("X-Ignored", "value")
+	c.Request.Header.Set("X-User-Id", "admin")
+	handler(c)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	w = httptest.NewRecorder()
+	c, _ = gin.CreateTestContext(w)
+	c.Request, _ = http.NewRequest("GET", "http://localhost:8000/echo", nil)
+	c.Request.Header.Set("Authorization", "Bearer invalid")
+	handler(c)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Equal(t, "Bearer", w.Header().Get("WWW-Authenticate"))
+	assert.Equal(t, `{"error":"invalid token"}`, w.Body.String())
+}
+
+func TestHandlerInvalidConfig(t *testing.T) {
+	handler := newHandler(map[string]interface{}{"path_prefix": "/check"}, func(c *gin.Context) {
+		t.Error("The request should not reach the next handler")
+	})
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request, _ = http.NewRequest("GET", "http://localhost:8000/echo", nil)
+	handler(c)
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+}
+
+func TestHandlerUnavailable(t *testing.T) {
+	for failOpen, status := range map[bool]int{true: http.StatusOK, false: http.StatusServiceUnavailable} {
+		handler := newHandler(map[string]interface{}{
+			"service_address":          "http://127.0.0.1:1",
+			"status_on_error":          http.StatusServiceUnavailable,
+			"fail_open":                failOpen,
+			"allowed_upstream_headers": []interface{}{"X-User-Id"},
+		}, func(c *gin.Context) {
+			assert.Equal(t, "", c.Request.Header.Get("X-User-Id"), "The identity sent by the client must not reach the backend")
+			c.Status(http.StatusOK)
+		})
+
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request, _ = http.NewRequest("GET", "http://localhost:8000/echo", nil)
+		c.Request.Header.Set("X-User-Id", "admin")
+		handler(c)
+		assert.Equal(t, status, w.Code)
+	}
+}
@@ -0,0 +1,142 @@
+package health
+
+import (
+	"context"
+	"sort"
+	"sync"
+)
+
+//Check reports whether a gateway component is able to serve, nil when it is
+type Check func(context.Context) error
+
+//Result outcome of the component checks, keyed by check name. Failed checks hold the error message
+type Result map[string]string
+
+const (
+	statusOK     = "ok"
+	statusFailed = "failed"
+)
+
+//statuses the outcome without the error messages, the failed checks reported as failed
+func (r Result) statuses() Result {
+	res := make(Result, len(r))
+	for name, v := range r {
+		res[name] = statusOK
+		if v != statusOK {
+			res[name] = statusFailed
+		}
+	}
+	return res
+}
+
+//failed report whether any check failed, the optional ones included
+func (r Result) failed() bool {
+	for _, v := range r {
+		if v != statusOK {
+			return true
+		}
+	}
+	return false
+}
+
+type checkEntry struct {
+	check Check
+	//optional a failure is reported without failing the readiness
+	optional bool
+}
+
+var (
+	checksMu sync.RWMutex
+	checks   = map[string]*checkEntry{}
+)
+
+//RegisterCheck add the check to the readiness until the context is cancelled. Registering a name
+//again replaces the previous check, so the components built on every config reload keep a single entry.
+//A context never cancelled, like context.Background, keeps the check without waiting on it
+func RegisterCheck(ctx context.Context, name string, c Check) {
+	register(ctx, name, &checkEntry{check: c})
+}
+
+//RegisterOptionalCheck add a check reported by the health endpoint without failing the readiness,
+//for the components able to serve while it fails, like the ones failing open
+func RegisterOptionalCheck(ctx context.Context, name string, c Check) {
+	register(ctx, name, &checkEntry{check: c, optional: true})
+}
+
+func register(ctx context.Context, name string, e *checkEntry) {
+	if e.check == nil {
+		return
+	}
+
+	checksMu.Lock()
+	checks[name] = e
+	checksMu.Unlock()
+
+	if ctx.Done() == nil {
+		return
+	}
+	go func() {
+		<-ctx.Done()
+		checksMu.Lock()
+		if checks[name] == e {
+			delete(checks, name)
+		}
+		checksMu.Unlock()
+	}()
+}
+
+//Checks the names of the registered checks
+func Checks() []string {
+	checksMu.RLock()
+	defer checksMu.RUnlock()
+	names := make([]string, 0, len(checks))
+	for name := range checks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+//Run the registered checks concurrently. It reports whether all of them but the optional ones
+//passed before the context is done, the pending ones are reported as failed
+func Run(ctx context.Context) (Result, bool) {
+	checksMu.RLock()
+	pending := make(map[string]*checkEntry, len(checks))
+	for name, e := range checks {
+		pending[name] = e
+	}
+	checksMu.RUnlock()
+
+	type outcome struct {
+		name string
+		err  error
+	}
+	out := make(chan outcome, len(pending))
+	for name, e := range pending {
+		go func(name string, c Check) {
+			out <- outcome{name, c(ctx)}
+		}(name, e.check)
+	}
+
+	res := Result{}
+	ok := true
+	for n := len(pending); n > 0; n-- {
+		select {
+		case o := <-out:
+			res[o.name] = statusOK
+			if o.err != nil {
+				res[o.name] = o.err.Error()
+				ok = ok && pending[o.name].optional
+			}
+			delete(pending, o.name)
+		case <-ctx.Done():
+			for name, e := range pending {
+				res[name] = ctx.Err().Error()
+				ok = ok && e.optional
+			}
+			return res, ok
+		}
+	}
+
+	return res, ok
+}
@@ -0,0 +1,88 @@
+package health
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/devopsfaith/krakend-ce/ext/lint"
+	"github.com/devopsfaith/krakend/config"
+)
+
+const (
+	namespace = "github_com/sahalzain/krakend-health"
+
+	defaultHealthPath   = "/__health"
+	defaultLivePath     = "/__live"
+	defaultCheckTimeout = 2 * time.Second
+)
+
+//Linter strict validation of the config block
+var Linter = lint.Linter{
+	Namespace: namespace,
+	Scope:     lint.ScopeService,
+	Fields: []lint.Field{
+		{Name: "health_path", Kind: lint.KindString, Check: lint.NotEmpty},
+		{Name: "live_path", Kind: lint.KindString, Check: lint.NotEmpty},
+		{Name: "ready_path", Kind: lint.KindString, Check: lint.NotEmpty},
+		{Name: "port", Kind: lint.KindInt, Check: lint.Range(1, 65535)},
+		{Name: "check_timeout", Kind: lint.KindDuration},
+		{Name: "disable", Kind: lint.KindBool, Description: "do not serve the probes, the readiness is still tracked for the graceful shutdown"},
+	},
+}
+
+type xtraConfig struct {
+	HealthPath string
+	LivePath   string
+	ReadyPath  string
+	//Port serve the probes on their own listener instead of the gateway one when set
+	Port int
+	//CheckTimeout maximum time the readiness waits for every component check
+	CheckTimeout time.Duration
+	//Disable do not serve the probes
+	Disable bool
+}
+
+func configGetter(cfg config.ExtraConfig) xtraConfig {
+	conf := xtraConfig{
+		HealthPath:   defaultHealthPath,
+		LivePath:     defaultLivePath,
+		ReadyPath:    ReadyPath,
+		CheckTimeout: defaultCheckTimeout,
+	}
+
+	v, ok := cfg[namespace]
+	if !ok {
+		return conf
+	}
+	tmp, ok := v.(map[string]interface{})
+	if !ok {
+		return conf
+	}
+
+	if p, ok := tmp["health_path"].(string); ok && p != "" {
+		conf.HealthPath = p
+	}
+	if p, ok := tmp["live_path"].(string); ok && p != "" {
+		conf.LivePath = p
+	}
+	if p, ok := tmp["ready_path"].(string); ok && p != "" {
+		conf.ReadyPath = p
+	}
+
+	conf.Disable, _ = tmp["disable"].(bool)
+
+	if p, ok := tmp["port"]; ok {
+		if port, err := strconv.Atoi(fmt.Sprintf("%v", p)); err == nil && port > 0 {
+			conf.Port = port
+		}
+	}
+
+	if s, ok := tmp["check_timeout"].(string); ok {
+		if d, err := time.ParseDuration(s); err == nil && d > 0 {
+			conf.CheckTimeout = d
+		}
+	}
+
+	return conf
+}
@@ -0,0 +1,200 @@
+package health
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/devopsfaith/krakend/config"
+	"github.com/devopsfaith/krakend/logging"
+	router "github.com/devopsfaith/krakend/router/gin"
+	"github.com/gin-gonic/gin"
+)
+
+//ReadyPath the default readiness endpoint, answering 503 until the service port accepts connections and
+//again once the shutdown begins, so the load balancers stop sending traffic before the listener is closed
+const ReadyPath = "/__ready"
+
+//listenPollInterval time between the connection attempts to the service port on startup
+const listenPollInterval = 10 * time.Millisecond
+
+//states of the readiness
+const (
+	notReady int32 = iota
+	isReady
+	//starting the server is waiting for its listener, any SetReady call takes precedence
+	starting
+)
+
+var ready int32
+
+//SetReady mark the gateway as ready or not ready to receive traffic
+func SetReady(v bool) {
+	i := notReady
+	if v {
+		i = isReady
+	}
+	atomic.StoreInt32(&ready, i)
+}
+
+//Ready report whether the gateway is ready to receive traffic
+func Ready() bool {
+	return atomic.LoadInt32(&ready) == isReady
+}
+
+//RunServer wrap the RunServer so the gateway is marked as ready once the service port accepts
+//connections, and as not ready when the server is done. Marking it as not ready while the port
+//is not accepting yet, like a shutdown does, keeps it that way
+func RunServer(l logging.Logger, next router.RunServerFunc) router.RunServerFunc {
+	return func(ctx context.Context, cfg config.ServiceConfig, h http.Handler) error {
+		atomic.StoreInt32(&ready, starting)
+		ctx, cancel := context.WithCancel(ctx)
+		polled := make(chan struct{})
+		go func() {
+			defer close(polled)
+			if waitListening(ctx, fmt.Sprintf("localhost:%d", cfg.Port)) != nil {
+				return
+			}
+			if atomic.CompareAndSwapInt32(&ready, starting, isReady) {
+				l.Debug("[Health] Marked as ready, the service port is accepting connections")
+			}
+		}()
+
+		err := next(ctx, cfg, h)
+		cancel()
+		<-polled
+		SetReady(false)
+		return err
+	}
+}
+
+//waitListening try to connect to the address until it succeeds or the context is done
+func waitListening(ctx context.Context, addr string) error {
+	ticker := time.NewTicker(listenPollInterval)
+	defer ticker.Stop()
+	for {
+		var d net.Dialer
+		if conn, err := d.DialContext(ctx, "tcp", addr); err == nil {
+			conn.Close()
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+//Register add the health, liveness and readiness endpoints to the engine, unless they are disabled or
+//served on their own port. The probes are answered before the routing, so they take precedence over the
+//gateway endpoints sharing the paths. The error messages of the failed checks are logged, the
+//responses only report them as failed
+func Register(cfg config.ServiceConfig, l logging.Logger, engine *gin.Engine) {
+	conf := configGetter(cfg.ExtraConfig)
+	if conf.Disable || conf.Port > 0 {
+		return
+	}
+
+	l.Debug("[Health] Probes enabled at ", conf.HealthPath, conf.LivePath, conf.ReadyPath)
+	engine.Use(probes{xtraConfig: conf, l: l}.middleware())
+}
+
+//Serve the health, liveness and readiness endpoints on their own port until the context is
+//cancelled, when configured. The responses include the error messages of the failed checks
+func Serve(ctx context.Context, cfg config.ServiceConfig, l logging.Logger) {
+	conf := configGetter(cfg.ExtraConfig)
+	if conf.Disable || conf.Port == 0 {
+		return
+	}
+
+	engine := gin.New()
+	engine.Use(probes{xtraConfig: conf, l: l, details: true}.middleware())
+
+	s := &http.Server{
+		Addr:    fmt.Sprintf(":%d", conf.Port),
+		Handler: engine,
+	}
+
+	go func() {
+		<-ctx.Done()
+		s.Close()
+	}()
+
+	go func() {
+		l.Info("[Health] Probes listening on port:", conf.Port)
+		if err := s.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			l.Error("[Health] Probes server:", err.Error())
+		}
+	}()
+}
+
+//probes the endpoints of the config. The error messages of the checks are answered only with
+//details, since they can include internal addresses
+type probes struct {
+	xtraConfig
+	l       logging.Logger
+	details bool
+}
+
+func (x probes) middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.Request.Method != http.MethodGet && c.Request.Method != http.MethodHead {
+			return
+		}
+
+		switch c.Request.URL.Path {
+		case x.LivePath:
+			c.AbortWithStatusJSON(http.StatusOK, map[string]interface{}{"status": "alive"})
+		case x.ReadyPath:
+			x.ready(c)
+		case x.HealthPath:
+			x.health(c)
+		}
+	}
+}
+
+//ready answers 200 when the gateway is serving and every component check passes
+func (x probes) ready(c *gin.Context) {
+	if !Ready() {
+		c.AbortWithStatusJSON(http.StatusServiceUnavailable, map[string]interface{}{"status": "not ready"})
+		return
+	}
+
+	res, ok := x.run(c.Request.Context())
+	if !ok {
+		c.AbortWithStatusJSON(http.StatusServiceUnavailable, map[string]interface{}{"status": "not ready", "checks": res})
+		return
+	}
+	c.AbortWithStatusJSON(http.StatusOK, map[string]interface{}{"status": "ready", "checks": res})
+}
+
+//health reports the state of the gateway and its components. It always answers 200 while the
+//process serves requests, the probes relying on the state should use the readiness endpoint
+func (x probes) health(c *gin.Context) {
+	res, _ := x.run(c.Request.Context())
+	status := statusOK
+	if res.failed() || !Ready() {
+		status = "degraded"
+	}
+	c.AbortWithStatusJSON(http.StatusOK, map[string]interface{}{"status": status, "ready": Ready(), "checks": res})
+}
+
+//run the checks, logging the failed ones
+func (x probes) run(ctx context.Context) (Result, bool) {
+	ctx, cancel := context.WithTimeout(ctx, x.CheckTimeout)
+	defer cancel()
+	res, ok := Run(ctx)
+	for name, v := range res {
+		if v != statusOK {
+			x.l.Warning("[Health] Check", name, "failed:", v)
+		}
+	}
+	if !x.details {
+		res = res.statuses()
+	}
+	return res, ok
+}
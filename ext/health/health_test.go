@@ -0,0 +1,223 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/devopsfaith/krakend/config"
+	"github.com/devopsfaith/krakend/logging"
+	router "github.com/devopsfaith/krakend/router/gin"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+var logger, _ = logging.NewLogger("CRITICAL", ioutil.Discard, "")
+
+func newEngine(extra config.ExtraConfig) *gin.Engine {
+	engine := gin.New()
+	Register(config.ServiceConfig{ExtraConfig: extra}, logger, engine)
+	engine.GET("/__health", func(c *gin.Context) { c.Status(http.StatusTeapot) })
+	return engine
+}
+
+func get(engine http.Handler, path string) *httptest.ResponseRecorder {
+	req, _ := http.NewRequest("GET", "http://localhost:8000"+path, nil)
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, req)
+	return w
+}
+
+func TestReady(t *testing.T) {
+	engine := newEngine(nil)
+
+	SetReady(false)
+	assert.False(t, Ready())
+	assert.Equal(t, http.StatusServiceUnavailable, get(engine, ReadyPath).Code)
+	assert.Equal(t, http.StatusOK, get(engine, "/__live").Code, "The liveness should not depend on the readiness")
+
+	SetReady(true)
+	assert.True(t, Ready())
+	w := get(engine, ReadyPath)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"status":"ready"`)
+
+	SetReady(false)
+	assert.Equal(t, http.StatusServiceUnavailable, get(engine, ReadyPath).Code)
+}
+
+func TestReadyChecks(t *testing.T) {
+	engine := newEngine(config.ExtraConfig{
+		namespace: map[string]interface{}{"check_timeout": "50ms"},
+	})
+	SetReady(true)
+	defer SetReady(false)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	RegisterCheck(ctx, "ok", func(context.Context) error { return nil })
+	assert.Equal(t, http.StatusOK, get(engine, ReadyPath).Code)
+
+	RegisterCheck(ctx, "sd", func(context.Context) error { return errors.New("no hosts") })
+	w := get(engine, ReadyPath)
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Contains(t, w.Body.String(), `"sd":"failed"`)
+	assert.False(t, strings.Contains(w.Body.String(), "no hosts"), "The gateway probes should not answer the error messages")
+
+	w = get(engine, "/__health")
+	assert.Equal(t, http.StatusOK, w.Code, "The probes should take precedence over the routes")
+	assert.Contains(t, w.Body.String(), `"status":"degraded"`)
+	assert.False(t, strings.Contains(w.Body.String(), "no hosts"))
+
+	details := gin.New()
+	details.Use(probes{xtraConfig: configGetter(nil), l: logger, details: true}.middleware())
+	assert.Contains(t, get(details, "/__health").Body.String(), `"sd":"no hosts"`, "The probes on their own port should answer the error messages")
+
+	RegisterCheck(ctx, "sd", func(context.Context) error { return nil })
+	assert.Equal(t, http.StatusOK, get(engine, ReadyPath).Code, "Registering a name again should replace the check")
+
+	RegisterCheck(ctx, "slow", func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	assert.Equal(t, http.StatusServiceUnavailable, get(engine, ReadyPath).Code)
+
+	cancel()
+	time.Sleep(10 * time.Millisecond)
+	assert.Equal(t, 0, len(Checks()), "The checks should be removed once the context is cancelled")
+	assert.Equal(t, http.StatusOK, get(engine, ReadyPath).Code)
+}
+
+func TestRun(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	RegisterCheck(ctx, "a", func(context.Context) error { return nil })
+	RegisterCheck(ctx, "b", func(context.Context) error { return errors.New("unreachable") })
+
+	res, ok := Run(context.Background())
+	assert.False(t, ok)
+	assert.Equal(t, Result{"a": "ok", "b": "unreachable"}, res)
+	assert.Equal(t, Result{"a": "ok", "b": "failed"}, res.statuses())
+	assert.Equal(t, []string{"a", "b"}, Checks())
+}
+
+func TestRun_optional(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	RegisterCheck(ctx, "a", func(context.Context) error { return nil })
+	RegisterOptionalCheck(ctx, "b", func(context.Context) error { return errors.New("unreachable") })
+
+	res, ok := Run(context.Background())
+	assert.True(t, ok, "The optional checks should not fail the readiness")
+	assert.Equal(t, Result{"a": "ok", "b": "unreachable"}, res)
+
+	engine := newEngine(nil)
+	SetReady(true)
+	defer SetReady(false)
+	assert.Equal(t, http.StatusOK, get(engine, ReadyPath).Code)
+	assert.Contains(t, get(engine, "/__health").Body.String(), `"status":"degraded"`)
+}
+
+//listenLater fake server listening on the port once listen is closed, until its context is done
+func listenLater(listen <-chan struct{}) router.RunServerFunc {
+	return func(ctx context.Context, cfg config.ServiceConfig, _ http.Handler) error {
+		select {
+		case <-listen:
+		case <-ctx.Done():
+			return nil
+		}
+		ln, err := net.Listen("tcp", fmt.Sprintf("localhost:%d", cfg.Port))
+		if err != nil {
+			return err
+		}
+		defer ln.Close()
+		<-ctx.Done()
+		return nil
+	}
+}
+
+func freePort(t *testing.T) int {
+	ln, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	return ln.Addr().(*net.TCPAddr).Port
+}
+
+func TestRunServer(t *testing.T) {
+	SetReady(false)
+	ctx, cancel := context.WithCancel(context.Background())
+	listen := make(chan struct{})
+	done := make(chan error, 1)
+	go func() {
+		done <- RunServer(logger, listenLater(listen))(ctx, config.ServiceConfig{Port: freePort(t)}, nil)
+	}()
+
+	time.Sleep(30 * time.Millisecond)
+	assert.False(t, Ready(), "The gateway should not be ready before the port accepts connections")
+
+	close(listen)
+	time.Sleep(50 * time.Millisecond)
+	assert.True(t, Ready())
+
+	cancel()
+	assert.Nil(t, <-done)
+	assert.False(t, Ready())
+}
+
+func TestRunServer_notReadyBeforeListening(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	listen := make(chan struct{})
+	go RunServer(logger, listenLater(listen))(ctx, config.ServiceConfig{Port: freePort(t)}, nil)
+
+	time.Sleep(20 * time.Millisecond)
+	SetReady(false)
+	close(listen)
+	time.Sleep(50 * time.Millisecond)
+	assert.False(t, Ready(), "Marking the gateway as not ready while starting should take precedence")
+}
+
+func TestRunServer_error(t *testing.T) {
+	SetReady(true)
+	errListen := errors.New("address already in use")
+	err := RunServer(logger, func(context.Context, config.ServiceConfig, http.Handler) error {
+		return errListen
+	})(context.Background(), config.ServiceConfig{Port: freePort(t)}, nil)
+
+	assert.Equal(t, errListen, err)
+	assert.False(t, Ready())
+}
+
+func TestConfig(t *testing.T) {
+	conf := configGetter(config.ExtraConfig{
+		namespace: map[string]interface{}{
+			"health_path":   "/status",
+			"ready_path":    "/readyz",
+			"port":          8091,
+			"check_timeout": "wrong",
+		},
+	})
+	assert.Equal(t, "/status", conf.HealthPath)
+	assert.Equal(t, defaultLivePath, conf.LivePath)
+	assert.Equal(t, "/readyz", conf.ReadyPath)
+	assert.Equal(t, 8091, conf.Port)
+	assert.Equal(t, defaultCheckTimeout, conf.CheckTimeout)
+
+	engine := gin.New()
+	Register(config.ServiceConfig{ExtraConfig: config.ExtraConfig{namespace: map[string]interface{}{"port": 8091}}}, logger, engine)
+	assert.Equal(t, http.StatusNotFound, get(engine, "/__live").Code, "The probes should not be served by the gateway when they have their own port")
+
+	engine = gin.New()
+	Register(config.ServiceConfig{ExtraConfig: config.ExtraConfig{namespace: map[string]interface{}{"disable": true}}}, logger, engine)
+	assert.Equal(t, http.StatusNotFound, get(engine, "/__live").Code, "The disabled probes should not be served")
+}
@@ -0,0 +1,53 @@
+package introspect
+
+import (
+	"github.com/devopsfaith/krakend-ce/ext/lint"
+	"github.com/devopsfaith/krakend/config"
+)
+
+const (
+	namespace        = "github_com/sahalzain/krakend-introspect"
+	defaultAdminPath = "/__debug/middlewares"
+	tokenHeader      = "X-Admin-Token"
+)
+
+//Linter strict validation of the config block. The report exposes the topology of the gateway, so
+//it is never served without a token
+var Linter = lint.Linter{
+	Namespace: namespace,
+	Scope:     lint.ScopeService,
+	Security:  true,
+	Fields: []lint.Field{
+		{Name: "admin_path", Kind: lint.KindString, Check: lint.NotEmpty},
+		{Name: "admin_token", Kind: lint.KindString, Required: true, Check: lint.NotEmpty},
+	},
+}
+
+type xtraConfig struct {
+	AdminPath  string
+	AdminToken string
+}
+
+func configGetter(cfg config.ExtraConfig) *xtraConfig {
+	v, ok := cfg[namespace]
+	if !ok {
+		return nil
+	}
+	tmp, ok := v.(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	conf := xtraConfig{
+		AdminPath: defaultAdminPath,
+	}
+
+	if ap, ok := tmp["admin_path"].(string); ok && ap != "" {
+		conf.AdminPath = ap
+	}
+
+	if at, ok := tmp["admin_token"].(string); ok {
+		conf.AdminToken = at
+	}
+
+	return &conf
+}
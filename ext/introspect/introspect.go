@@ -0,0 +1,42 @@
+//Package introspect exposes an admin endpoint listing the middlewares enabled for every endpoint and
+//backend, the order they wrap the requests and their config, along with the effective timeouts
+package introspect
+
+import (
+	"crypto/subtle"
+	"net/http"
+
+	"github.com/devopsfaith/krakend/config"
+	"github.com/devopsfaith/krakend/logging"
+	"github.com/gin-gonic/gin"
+)
+
+//Register add the introspection endpoint to the engine when configured. The report is built with the
+//config the engine is created for, so it follows the hot reloads. The endpoint is not served without
+//an admin token
+func Register(cfg config.ServiceConfig, chains Chains, l logging.Logger, engine *gin.Engine) {
+	conf := configGetter(cfg.ExtraConfig)
+	if conf == nil {
+		return
+	}
+	if conf.AdminToken == "" {
+		l.Warning("[Introspect] No admin_token, the middleware introspection endpoint is disabled")
+		return
+	}
+
+	report := NewReport(cfg, chains)
+
+	l.Debug("[Introspect] Middleware introspection endpoint enabled at ", conf.AdminPath)
+	engine.GET(conf.AdminPath, conf.handler(report))
+}
+
+func (x *xtraConfig) handler(report Report) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if subtle.ConstantTimeCompare([]byte(c.GetHeader(tokenHeader)), []byte(x.AdminToken)) != 1 {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, map[string]interface{}{"error": "Invalid admin token"})
+			return
+		}
+
+		c.JSON(http.StatusOK, report)
+	}
+}
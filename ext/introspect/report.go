@@ -0,0 +1,250 @@
+package introspect
+
+import (
+	"net/url"
+	"strings"
+
+	"github.com/devopsfaith/krakend-ce/ext/lint"
+	"github.com/devopsfaith/krakend/config"
+)
+
+const redacted = "REDACTED"
+
+//secretKeys fragments of the config keys and header names holding credentials
+var secretKeys = []string{"password", "secret", "token", "private_key", "api_key", "api-key", "apikey", "credentials", "authorization", "cookie"}
+
+//headerNameKeys keys naming the header set by a modifier, e.g. the martian header.Modifier
+var headerNameKeys = []string{"name", "header"}
+
+//Middleware a layer of a middleware chain. It is enabled when any of its namespaces is declared in
+//the extra config of the Scope levels, the level wrapped by the chain when no Scope is set.
+//Middlewares without namespaces are always enabled
+type Middleware struct {
+	Name       string
+	Namespaces []string
+	Scope      lint.Scope
+}
+
+//Stack a middleware chain, the outermost middleware first
+type Stack []Middleware
+
+//Chains the middleware chains assembled by the gateway
+type Chains struct {
+	//Engine the router engine middlewares, enabled by the service extra config
+	Engine Stack
+	//Handler the endpoint handler middlewares
+	Handler Stack
+	//Proxy the endpoint proxy middlewares
+	Proxy Stack
+	//Backend the backend proxy middlewares
+	Backend Stack
+}
+
+//Layer an enabled middleware along with the config of its declared namespaces, secrets redacted
+type Layer struct {
+	Name   string                 `json:"name"`
+	Config map[string]interface{} `json:"config,omitempty"`
+}
+
+//Report the middlewares enabled for the service, every endpoint and backend
+type Report struct {
+	Name      string     `json:"name,omitempty"`
+	Timeout   string     `json:"timeout"`
+	CacheTTL  string     `json:"cache_ttl"`
+	Engine    []Layer    `json:"engine"`
+	Endpoints []Endpoint `json:"endpoints"`
+}
+
+//Endpoint the middlewares enabled for an endpoint along with its effective timeout and cache TTL
+type Endpoint struct {
+	Endpoint        string    `json:"endpoint"`
+	Method          string    `json:"method"`
+	Timeout         string    `json:"timeout"`
+	CacheTTL        string    `json:"cache_ttl"`
+	ConcurrentCalls int       `json:"concurrent_calls"`
+	Handler         []Layer   `json:"handler"`
+	Proxy           []Layer   `json:"proxy"`
+	Backends        []Backend `json:"backends"`
+}
+
+//Backend the middlewares enabled for a backend along with its effective timeout
+type Backend struct {
+	URLPattern  string   `json:"url_pattern"`
+	Method      string   `json:"method"`
+	Host        []string `json:"host"`
+	Encoding    string   `json:"encoding,omitempty"`
+	Timeout     string   `json:"timeout"`
+	Middlewares []Layer  `json:"middlewares"`
+}
+
+//NewReport list the middlewares of the chains enabled by the config
+func NewReport(cfg config.ServiceConfig, chains Chains) Report {
+	r := Report{
+		Name:      cfg.Name,
+		Timeout:   cfg.Timeout.String(),
+		CacheTTL:  cfg.CacheTTL.String(),
+		Engine:    levels{service: cfg.ExtraConfig}.layers(chains.Engine, lint.ScopeService),
+		Endpoints: []Endpoint{},
+	}
+
+	for _, e := range cfg.Endpoints {
+		l := levels{service: cfg.ExtraConfig, endpoint: e.ExtraConfig}
+		for _, b := range e.Backend {
+			l.backends = append(l.backends, b.ExtraConfig)
+		}
+
+		//the endpoints without cache TTL inherit the service one
+		cacheTTL := e.CacheTTL
+		if cacheTTL == 0 {
+			cacheTTL = cfg.CacheTTL
+		}
+
+		endpoint := Endpoint{
+			Endpoint:        e.Endpoint,
+			Method:          strings.ToUpper(e.Method),
+			Timeout:         e.Timeout.String(),
+			CacheTTL:        cacheTTL.String(),
+			ConcurrentCalls: e.ConcurrentCalls,
+			Handler:         l.layers(chains.Handler, lint.ScopeEndpoint),
+			Proxy:           l.layers(chains.Proxy, lint.ScopeEndpoint),
+			Backends:        []Backend{},
+		}
+		if endpoint.Method == "" {
+			endpoint.Method = "GET"
+		}
+
+		for _, b := range e.Backend {
+			bl := levels{service: cfg.ExtraConfig, endpoint: e.ExtraConfig, backends: []config.ExtraConfig{b.ExtraConfig}}
+
+			//the backends without timeout use the endpoint one
+			timeout := b.Timeout
+			if timeout == 0 {
+				timeout = e.Timeout
+			}
+
+			endpoint.Backends = append(endpoint.Backends, Backend{
+				URLPattern:  b.URLPattern,
+				Method:      strings.ToUpper(b.Method),
+				Host:        b.Host,
+				Encoding:    b.Encoding,
+				Timeout:     timeout.String(),
+				Middlewares: bl.layers(chains.Backend, lint.ScopeBackend),
+			})
+		}
+
+		r.Endpoints = append(r.Endpoints, endpoint)
+	}
+
+	return r
+}
+
+//levels the extra config of the levels a chain is assembled for
+type levels struct {
+	service  config.ExtraConfig
+	endpoint config.ExtraConfig
+	backends []config.ExtraConfig
+}
+
+func (l levels) layers(s Stack, scope lint.Scope) []Layer {
+	res := []Layer{}
+	for _, m := range s {
+		if len(m.Namespaces) == 0 {
+			res = append(res, Layer{Name: m.Name})
+			continue
+		}
+
+		from := scope
+		if m.Scope != 0 {
+			from = m.Scope
+		}
+		conf := map[string]interface{}{}
+		for _, ns := range m.Namespaces {
+			if v, ok := l.lookup(ns, from); ok {
+				conf[ns] = Redact(v)
+			}
+		}
+		if len(conf) > 0 {
+			res = append(res, Layer{Name: m.Name, Config: conf})
+		}
+	}
+	return res
+}
+
+func (l levels) lookup(namespace string, scope lint.Scope) (interface{}, bool) {
+	if scope&lint.ScopeBackend != 0 {
+		for _, b := range l.backends {
+			if v, ok := b[namespace]; ok {
+				return v, true
+			}
+		}
+	}
+	if scope&lint.ScopeEndpoint != 0 {
+		if v, ok := l.endpoint[namespace]; ok {
+			return v, true
+		}
+	}
+	if scope&lint.ScopeService != 0 {
+		if v, ok := l.service[namespace]; ok {
+			return v, true
+		}
+	}
+	return nil, false
+}
+
+//Redact copy the config replacing the credentials: the values of the secret keys, the values set to
+//the credential headers and the URL passwords
+func Redact(v interface{}) interface{} {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		secretHeader := false
+		for _, k := range headerNameKeys {
+			if name, ok := t[k].(string); ok && isSecret(name) {
+				secretHeader = true
+			}
+		}
+		res := make(map[string]interface{}, len(t))
+		for k, v := range t {
+			if isSecret(k) || secretHeader && (k == "value" || k == "values") {
+				res[k] = redacted
+				continue
+			}
+			res[k] = Redact(v)
+		}
+		return res
+	case []interface{}:
+		res := make([]interface{}, len(t))
+		for i, v := range t {
+			res[i] = Redact(v)
+		}
+		return res
+	case string:
+		return redactURL(t)
+	default:
+		return v
+	}
+}
+
+func isSecret(key string) bool {
+	key = strings.ToLower(key)
+	for _, s := range secretKeys {
+		if strings.Contains(key, s) {
+			return true
+		}
+	}
+	return false
+}
+
+func redactURL(s string) string {
+	if !strings.Contains(s, "://") {
+		return s
+	}
+	u, err := url.Parse(s)
+	if err != nil || u.User == nil {
+		return s
+	}
+	if _, ok := u.User.Password(); !ok {
+		return s
+	}
+	u.User = url.UserPassword(u.User.Username(), redacted)
+	return u.String()
+}
@@ -4,6 +4,9 @@ import (
 	"fmt"
 	"strings"
 
+	"github.com/devopsfaith/krakend-ce/ext/lint"
+	"github.com/devopsfaith/krakend-ce/ext/reqctx"
+	"github.com/devopsfaith/krakend-ce/ext/selector"
 	"github.com/devopsfaith/krakend/config"
 )
 
@@ -51,3 +54,24 @@ func configGetter(cfg config.ExtraConfig) *xtraConfig {
 
 	return &conf
 }
+
+//Linter strict validation of the config block, the invalid mappings are skipped at runtime
+var Linter = lint.Linter{
+	Namespace: namespace,
+	Scope:     lint.ScopeEndpoint,
+	Fields: []lint.Field{
+		{Name: "jwt_map", Kind: lint.KindObject, Required: true, Check: lint.All(lint.NotEmpty, lint.Keys(lint.WritableSelector), lint.Values(lint.Func(claim)))},
+	},
+}
+
+//claim claims are mapped as payload.<path> or header.<path>, context values with their full path
+func claim(v interface{}) error {
+	s, ok := v.(string)
+	if !ok {
+		return lint.Selector.Validate(v)
+	}
+	if !strings.HasPrefix(strings.ToLower(s), reqctx.Prefix+".") {
+		s = selector.SourceJWT + "." + s
+	}
+	return lint.Selector.Validate(s)
+}
@@ -1,10 +1,14 @@
 package keyauth
 
 import (
+	"context"
 	"fmt"
 	"strconv"
 	"strings"
+	"time"
 
+	cache "github.com/devopsfaith/krakend-ce/ext/cache"
+	"github.com/devopsfaith/krakend-ce/ext/lint"
 	"github.com/devopsfaith/krakend-ce/ext/service"
 	"github.com/devopsfaith/krakend/config"
 )
@@ -22,12 +26,23 @@ type xtraConfig struct {
 	BasePath       string
 	CacheDuration  int
 	CacheSize      int
+	CacheTTLPath   string
+	CacheTagPath   string
+	CacheKeyFields []string
+	Cache          cache.Config
+	CacheStore     cache.Local
+	Client         service.ClientConfig
+	Breaker        *service.BreakerConfig
+	FailOpen       bool
+	Transport      string
 	Service        service.KeyAuth
 	RequestMap     map[string]string
 	ResponseMap    map[string]string
 }
 
-func configGetter(cfg config.ExtraConfig) *xtraConfig {
+//configGetter parse the config block of the endpoint. The remote cache entries are kept per endpoint
+//unless a cache_prefix is set
+func configGetter(ctx context.Context, endpoint string, cfg config.ExtraConfig) *xtraConfig {
 	v, ok := cfg[namespace]
 	if !ok {
 		return nil
@@ -39,6 +54,8 @@ func configGetter(cfg config.ExtraConfig) *xtraConfig {
 	conf := xtraConfig{
 		CacheDuration: defaultCacheDuration,
 		CacheSize:     0,
+		Transport:     service.TransportHTTP,
+		CacheTagPath:  defaultResponsePath,
 		BasePath:      basePath,
 		RequestMap:    make(map[string]string),
 		ResponseMap: map[string]string{
@@ -79,6 +96,22 @@ func configGetter(cfg config.ExtraConfig) *xtraConfig {
 		}
 	}
 
+	if tp, ok := tmp["cache_ttl_path"].(string); ok {
+		conf.CacheTTLPath = tp
+	}
+
+	if tp, ok := tmp["cache_tag_path"].(string); ok {
+		conf.CacheTagPath = tp
+	}
+
+	if kf, ok := tmp["cache_key_fields"].([]interface{}); ok {
+		for _, f := range kf {
+			if fs, ok := f.(string); ok {
+				conf.CacheKeyFields = append(conf.CacheKeyFields, fs)
+			}
+		}
+	}
+
 	if bp, ok := tmp["base_path"].(string); ok {
 		conf.BasePath = bp
 	}
@@ -95,7 +128,87 @@ func configGetter(cfg config.ExtraConfig) *xtraConfig {
 		}
 	}
 
-	conf.Service = service.NewHTTPKeyAuth(conf.ServiceAddress, conf.BasePath, conf.CacheDuration, conf.CacheSize)
+	conf.Cache = cache.ParseConfig(tmp)
+	conf.Cache.Context = ctx
+	conf.Cache.Duration = time.Duration(conf.CacheDuration) * time.Second
+	conf.Cache.Size = conf.CacheSize
+	if conf.Cache.Remote.Prefix == "" {
+		conf.Cache.Remote.Prefix = "keyauth:" + endpoint + ":"
+	}
+
+	if fo, ok := tmp["fail_open"].(bool); ok {
+		conf.FailOpen = fo
+	}
+
+	if tr, ok := tmp["transport"].(string); ok {
+		conf.Transport = tr
+	}
+
+	store, err := cache.New(conf.Cache)
+	if err != nil {
+		return nil
+	}
+	conf.CacheStore = store
+	conf.Breaker = service.ParseBreakerConfig(tmp)
+	conf.Client = service.ParseClientConfig(tmp)
+	svc, err := conf.newService(ctx)
+	if err != nil {
+		svc = &service.DummyKeyAuth{Error: err}
+	}
+	conf.Service = svc
 
 	return &conf
 }
+
+func (x *xtraConfig) newService(ctx context.Context) (service.KeyAuth, error) {
+	if x.Transport == service.TransportGRPC {
+		return service.NewGRPCKeyAuth(service.GRPCConfig{
+			Context: ctx,
+			Address: x.ServiceAddress,
+			Cache:   x.CacheStore,
+			Client:  x.Client,
+			Breaker: x.Breaker,
+			TTLPath: x.CacheTTLPath,
+			TagPath: x.CacheTagPath,
+		})
+	}
+
+	client, err := service.NewClient(x.Client)
+	if err != nil {
+		return nil, err
+	}
+
+	return service.NewHTTPKeyAuth(service.HTTPConfig{
+		Address:  x.ServiceAddress,
+		BasePath: x.BasePath,
+		Cache:    x.CacheStore,
+		Client:   client,
+		Breaker:  x.Breaker,
+		TTLPath:  x.CacheTTLPath,
+		TagPath:  x.CacheTagPath,
+	}), nil
+}
+
+//Linter strict validation of the config block. The endpoint rejects every request when service_address
+//or request_map are missing, or when the cache_mode requires a missing cache_address
+var Linter = lint.Linter{
+	Namespace: namespace,
+	Scope:     lint.ScopeEndpoint,
+	Security:  true,
+	Fields: append([]lint.Field{
+		{Name: "service_address", Kind: lint.KindString, Required: true, Check: lint.NotEmpty},
+		{Name: "request_map", Kind: lint.KindObject, Required: true, Check: lint.All(lint.NotEmpty, lint.Values(lint.Selector))},
+		{Name: "response_map", Kind: lint.KindObject, Check: lint.Keys(lint.WritableSelector)},
+		{Name: "base_path", Kind: lint.KindString},
+		{Name: "cache_duration", Kind: lint.KindInt, Check: lint.Min(0)},
+		{Name: "cache_size", Kind: lint.KindInt, Check: lint.Min(0)},
+		{Name: "cache_ttl_path", Kind: lint.KindString},
+		{Name: "cache_tag_path", Kind: lint.KindString},
+		{Name: "cache_key_fields", Kind: lint.KindArray},
+		{Name: "fail_open", Kind: lint.KindBool},
+		{Name: "transport", Kind: lint.KindString, Check: lint.OneOf(service.TransportHTTP, service.TransportGRPC)},
+		service.ClientField,
+		service.BreakerField,
+	}, cache.Fields...),
+	Check: cache.Check,
+}
@@ -1,37 +1,39 @@
 package keyauth
 
 import (
+	"context"
 	"testing"
 
+	"github.com/devopsfaith/krakend-ce/ext/lint"
 	"github.com/devopsfaith/krakend/config"
 	"github.com/stretchr/testify/assert"
 )
 
 func TestConfigInvalidParse(t *testing.T) {
 
-	assert.Nil(t, configGetter(config.ExtraConfig{
+	assert.Nil(t, configGetter(context.Background(), "/test", config.ExtraConfig{
 		"keyauth": map[string]interface{}{
 			"service_address": "http://localhost:8080",
 		},
 	}), "Should nil")
 
-	assert.Nil(t, configGetter(config.ExtraConfig{
+	assert.Nil(t, configGetter(context.Background(), "/test", config.ExtraConfig{
 		namespace: "keyauth",
 	}), "Should nil")
 
-	assert.Nil(t, configGetter(config.ExtraConfig{
+	assert.Nil(t, configGetter(context.Background(), "/test", config.ExtraConfig{
 		namespace: map[string]interface{}{
 			"service_address": "http://localhost:8080",
 		},
 	}), "Should nil")
 
-	assert.Nil(t, configGetter(config.ExtraConfig{
+	assert.Nil(t, configGetter(context.Background(), "/test", config.ExtraConfig{
 		namespace: map[string]interface{}{
 			"key_path": "body.key_api",
 		},
 	}), "Should nil")
 
-	assert.Nil(t, configGetter(config.ExtraConfig{
+	assert.Nil(t, configGetter(context.Background(), "/test", config.ExtraConfig{
 		namespace: map[string]interface{}{
 			"service_address": "http://localhost:8080",
 			"key_path":        "body",
@@ -49,7 +51,7 @@ func TestConfigParse(t *testing.T) {
 		},
 	}
 
-	cfg := configGetter(xtra)
+	cfg := configGetter(context.Background(), "/test", xtra)
 
 	assert.NotNil(t, cfg, "Should not nil")
 	assert.NotNil(t, cfg.Service)
@@ -73,10 +75,95 @@ func TestConfigCustomParse(t *testing.T) {
 		},
 	}
 
-	cfg := configGetter(xtra)
+	cfg := configGetter(context.Background(), "/test", xtra)
 
 	assert.NotNil(t, cfg, "Should not nil")
 	assert.Equal(t, cfg.BasePath, "/v2/auth/key", "Should not default")
 	assert.Equal(t, cfg.CacheDuration, 10, "Should not default")
 	assert.Equal(t, 1, len(cfg.ResponseMap))
 }
+
+func TestConfigCacheParse(t *testing.T) {
+	xtra := config.ExtraConfig{
+		namespace: map[string]interface{}{
+			"service_address": "http://localhost:8080",
+			"request_map": map[string]interface{}{
+				"key": "body.key_api",
+			},
+			"cache_mode":    "remote",
+			"cache_address": "localhost:6379",
+			"cache_prefix":  "apikey:",
+		},
+	}
+
+	cfg := configGetter(context.Background(), "/test", xtra)
+	assert.NotNil(t, cfg, "Should not nil")
+	assert.Equal(t, "remote", cfg.Cache.Mode)
+	assert.Equal(t, "apikey:", cfg.Cache.Remote.Prefix)
+
+	delete(xtra[namespace].(map[string]interface{}), "cache_prefix")
+	cfg = configGetter(context.Background(), "/test", xtra)
+	assert.NotNil(t, cfg, "Should not nil")
+	assert.Equal(t, "keyauth:/test:", cfg.Cache.Remote.Prefix, "Should be kept per endpoint")
+
+	delete(xtra[namespace].(map[string]interface{}), "cache_address")
+	assert.Nil(t, configGetter(context.Background(), "/test", xtra), "Should nil without the remote address")
+}
+
+func TestConfigCacheKeyFieldsParse(t *testing.T) {
+	cfg := configGetter(context.Background(), "/test", config.ExtraConfig{
+		namespace: map[string]interface{}{
+			"service_address": "http://localhost:8080",
+			"request_map": map[string]interface{}{
+				"key":    "header.X-Key",
+				"client": "header.X-Client",
+			},
+			"cache_key_fields": []interface{}{"key"},
+		},
+	})
+
+	assert.NotNil(t, cfg, "Should not nil")
+	assert.Equal(t, []string{"key"}, cfg.CacheKeyFields)
+}
+
+func TestLinter(t *testing.T) {
+	errs := lint.Lint(config.ServiceConfig{
+		Endpoints: []*config.EndpointConfig{
+			{
+				Endpoint: "/foo",
+				ExtraConfig: config.ExtraConfig{
+					namespace: map[string]interface{}{
+						"service_address": "http://localhost:8080",
+						"request_map":     map[string]interface{}{"key": "query"},
+						"response_map":    map[string]interface{}{"param.id": "result.id"},
+						"transport":       "tcp",
+					},
+				},
+			},
+		},
+	}, Linter)
+
+	assert.Len(t, errs, 3)
+	assert.Len(t, errs.Security(), 3)
+	assert.Equal(t, `endpoint GET /foo: github_com/sahalzain/krakend-keyauth.request_map: key "key": invalid selector "query"`, errs[0].Error())
+	assert.Equal(t, "response_map", errs[1].Field)
+	assert.Equal(t, "transport", errs[2].Field)
+
+	errs = lint.Lint(config.ServiceConfig{
+		Endpoints: []*config.EndpointConfig{
+			{
+				Endpoint: "/foo",
+				ExtraConfig: config.ExtraConfig{
+					namespace: map[string]interface{}{
+						"service_address": "http://localhost:8080",
+						"request_map":     map[string]interface{}{"key": "header.X-Key"},
+						"cache_mode":      "remote",
+					},
+				},
+			},
+		},
+	}, Linter)
+
+	assert.Len(t, errs.Security(), 1)
+	assert.Equal(t, `endpoint GET /foo: github_com/sahalzain/krakend-keyauth.cache_address: required by the remote cache_mode`, errs[0].Error())
+}
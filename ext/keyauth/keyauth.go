@@ -1,21 +1,20 @@
 package keyauth
 
 import (
-	"bytes"
-	"crypto/sha256"
-	"errors"
-	"fmt"
-	"io/ioutil"
+	"context"
+	"encoding/json"
 	"net/http"
-	"strings"
 
+	cache "github.com/devopsfaith/krakend-ce/ext/cache"
+	"github.com/devopsfaith/krakend-ce/ext/health"
+	"github.com/devopsfaith/krakend-ce/ext/reqctx"
+	"github.com/devopsfaith/krakend-ce/ext/selector"
+	"github.com/devopsfaith/krakend-ce/ext/service"
 	"github.com/devopsfaith/krakend/config"
 	"github.com/devopsfaith/krakend/logging"
 	"github.com/devopsfaith/krakend/proxy"
 	krakendgin "github.com/devopsfaith/krakend/router/gin"
 	"github.com/gin-gonic/gin"
-	"github.com/tidwall/gjson"
-	"github.com/tidwall/sjson"
 )
 
 //Request KeyAuth request model
@@ -23,8 +22,23 @@ type Request map[string]interface{}
 
 //Hash calculate request hash
 func (r *Request) Hash() [32]byte {
-	val := fmt.Sprintf("%v", r)
-	return sha256.Sum256([]byte(val))
+	return service.Hash(r)
+}
+
+//keyRequest validation request cached on a subset of its fields
+type keyRequest struct {
+	*Request
+	fields []string
+}
+
+//Hash calculate request hash from the key fields
+func (k keyRequest) Hash() [32]byte {
+	return service.Hash(k.Request, k.fields...)
+}
+
+//MarshalJSON send the validation request as is
+func (k keyRequest) MarshalJSON() ([]byte, error) {
+	return json.Marshal(k.Request)
 }
 
 //Get get value
@@ -35,15 +49,27 @@ func (r *Request) Get(key string) interface{} {
 	return map[string]interface{}(*r)[key]
 }
 
-//HandlerFactory Open Policy Agent handler factory
+//HandlerFactory KeyAuth handler factory. The caches and health checks are kept for the process lifetime
+//and start no background task, use HandlerFactoryWithContext to release them with the router
 func HandlerFactory(l logging.Logger, next krakendgin.HandlerFactory) krakendgin.HandlerFactory {
+	return HandlerFactoryWithContext(context.Background(), l, next)
+}
+
+//HandlerFactoryWithContext KeyAuth handler factory, the caches live until the context is cancelled
+func HandlerFactoryWithContext(ctx context.Context, l logging.Logger, next krakendgin.HandlerFactory) krakendgin.HandlerFactory {
 	//l.Debug("Enabling OPA handler ")
 	return func(remote *config.EndpointConfig, p proxy.Proxy) gin.HandlerFunc {
 		handlerFunc := next(remote, p)
 
-		conf := configGetter(remote.ExtraConfig)
+		conf := configGetter(ctx, remote.Endpoint, remote.ExtraConfig)
 
 		if conf == nil {
+			if _, ok := remote.ExtraConfig[namespace]; ok {
+				l.Error("[KeyAuth] Invalid config for endpoint ", remote.Endpoint, ", rejecting every request")
+				return func(c *gin.Context) {
+					c.AbortWithStatus(http.StatusInternalServerError)
+				}
+			}
 			//l.Debug("[OPA] No config for policy agent ")
 			return func(c *gin.Context) {
 				handlerFunc(c)
@@ -51,12 +77,30 @@ func HandlerFactory(l logging.Logger, next krakendgin.HandlerFactory) krakendgin
 		}
 
 		l.Debug("[KeyAuth] KeyAuth is enabled for endpoint ", remote.Endpoint)
+		cache.Register(ctx, "keyauth", remote.Endpoint, conf.CacheStore)
+		if svc, ok := conf.Service.(service.Pinger); ok {
+			//the endpoint keeps serving while the service is unavailable when failing open
+			register := health.RegisterCheck
+			if conf.FailOpen {
+				register = health.RegisterOptionalCheck
+			}
+			register(ctx, "keyauth:"+remote.Endpoint, svc.Ping)
+		}
 
 		return func(c *gin.Context) {
+			reqctx.FromGin(c)
+
 			res, err := conf.validateKey(c.Request)
+			if err != nil && conf.FailOpen && service.IsUnavailable(err) {
+				l.Warning("[KeyAuth] Key service unavailable, failing open ", err)
+				conf.clearResponseMap(c.Request)
+				handlerFunc(c)
+				return
+			}
+
 			if err != nil {
 				l.Error("[KeyAuth] Error validating key ", err)
-				c.AbortWithStatusJSON(http.StatusUnauthorized, map[string]interface{}{"error": err.Error()})
+				c.AbortWithStatusJSON(http.StatusUnauthorized, map[string]interface{}{"error": "Invalid KEY API"})
 				return
 			}
 
@@ -77,17 +121,26 @@ func (x *xtraConfig) validateKey(r *http.Request) (bool, error) {
 		return false, err
 	}
 
-	res, err := x.Service.Validate(req)
+	var key service.Cacheable = req
+	if len(x.CacheKeyFields) > 0 {
+		key = keyRequest{Request: req, fields: x.CacheKeyFields}
+	}
+
+	ctx := service.WithRequestID(r.Context(), r.Header.Get(x.Client.RequestIDHeader))
+	res, err := x.Service.Validate(ctx, key)
 	if err != nil || res == nil {
 		return false, err
 	}
 
+	reqctx.FromRequest(r).Set(reqctx.KeyAuthResult, res)
+
+	x.clearResponseMap(r)
 	for k, v := range x.ResponseMap {
 		val, ok := lookup(v, res)
 		if !ok {
 			continue
 		}
-		if err := x.injectResult(k, fmt.Sprintf("%v", val), r); err != nil {
+		if err := selector.Set(r, k, selector.Format(val)); err != nil {
 			continue
 		}
 	}
@@ -95,6 +148,14 @@ func (x *xtraConfig) validateKey(r *http.Request) (bool, error) {
 	return true, nil
 }
 
+//clearResponseMap remove the values of the response map targets sent by the client, so only the
+//ones set from the key service response reach the backends
+func (x *xtraConfig) clearResponseMap(r *http.Request) {
+	for k := range x.ResponseMap {
+		selector.Delete(r, k)
+	}
+}
+
 func (x *xtraConfig) buildValidationRequest(r *http.Request) (*Request, error) {
 	req := make(map[string]interface{})
 	for k, v := range x.RequestMap {
@@ -109,74 +170,9 @@ func (x *xtraConfig) buildValidationRequest(r *http.Request) (*Request, error) {
 }
 
 func (x *xtraConfig) extractKey(path string, r *http.Request) (string, error) {
-	parts := strings.Split(path, ".")
-	switch strings.ToLower(parts[0]) {
-	case "body":
-		if r.Body == nil {
-			return "", errors.New("Empty request body")
-		}
-
-		raw, _ := ioutil.ReadAll(r.Body)
-		if raw == nil {
-			return "", errors.New("Unable to read request body")
-		}
-		r.Body = ioutil.NopCloser(bytes.NewReader(raw))
-
-		if val := gjson.Get(string(raw), strings.Join(parts[1:], ".")); val.Exists() {
-			return val.String(), nil
-		}
-
-		return "", errors.New("API Key on body not found")
-
-	case "header":
-		if r.Header == nil {
-			return "", errors.New("Empty header")
-		}
-
-		if val := r.Header.Get(parts[1]); val != "" {
-			return val, nil
-		}
-
-		return "", errors.New("API Key on header not found")
-	case "query":
-		if r.URL.Query() == nil {
-			return "", errors.New("Empty url query")
-		}
-
-		if val := r.URL.Query().Get(parts[1]); val != "" {
-			return val, nil
-		}
-
-		return "", errors.New("API Key on query not found")
-	default:
-		return "", errors.New("Invalid path")
-	}
-}
-
-func (x *xtraConfig) injectResult(path, id string, r *http.Request) error {
-	parts := strings.Split(path, ".")
-	if len(parts) < 2 {
-		return errors.New("Invalid result path")
-	}
-
-	switch parts[0] {
-	case "header":
-		r.Header.Set(parts[1], id)
-		return nil
-	case "body":
-		raw, _ := ioutil.ReadAll(r.Body)
-		if raw == nil {
-			return errors.New("Unable to read request body")
-		}
-		res, err := sjson.Set(string(raw), strings.Join(parts[1:], "."), id)
-		if err != nil {
-			return err
-		}
-		bres := []byte(res)
-		r.Body = ioutil.NopCloser(bytes.NewReader(bres))
-		r.Header.Set("Content-Length", fmt.Sprintf("%v", len(bres)))
-		return nil
-	default:
-		return errors.New("Invalid result path")
+	val, err := selector.Get(r, path)
+	if err != nil {
+		return "", err
 	}
+	return selector.Format(val), nil
 }
@@ -0,0 +1,219 @@
+package lint
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/devopsfaith/krakend-ce/ext/selector"
+)
+
+//Check validation of a field value along with the JSON Schema keywords describing it.
+//The zero value accepts every value
+type Check struct {
+	validate func(interface{}) error
+	schema   map[string]interface{}
+}
+
+//NewCheck check validated by the function and described by the JSON Schema keywords
+func NewCheck(validate func(interface{}) error, schema map[string]interface{}) Check {
+	return Check{validate: validate, schema: schema}
+}
+
+//Func check validated by the function, without schema keywords
+func Func(validate func(interface{}) error) Check {
+	return Check{validate: validate}
+}
+
+//Validate check the value
+func (c Check) Validate(v interface{}) error {
+	if c.validate == nil {
+		return nil
+	}
+	return c.validate(v)
+}
+
+//Schema the JSON Schema keywords describing the values accepted by the check
+func (c Check) Schema() map[string]interface{} {
+	res := make(map[string]interface{}, len(c.schema))
+	for k, v := range c.schema {
+		res[k] = v
+	}
+	return res
+}
+
+//selectorPattern schema pattern of the selectors, source and dotted path
+const selectorPattern = `^[A-Za-z]+(\.[^.]+)+$`
+
+var (
+	//Selector check the value is a valid selector
+	Selector = NewCheck(validSelector, map[string]interface{}{
+		"pattern": selectorPattern,
+	})
+
+	//WritableSelector check the value is a valid selector the modules can write to
+	WritableSelector = NewCheck(writableSelector, map[string]interface{}{
+		"pattern": selectorPattern,
+	})
+
+	//NotEmpty check the string, object or array is not empty
+	NotEmpty = NewCheck(notEmpty, map[string]interface{}{
+		"minLength":     1,
+		"minProperties": 1,
+		"minItems":      1,
+	})
+)
+
+func validSelector(v interface{}) error {
+	s, ok := v.(string)
+	if !ok {
+		return errors.New("must be a selector string")
+	}
+	if _, err := selector.Parse(s); err != nil {
+		return fmt.Errorf("invalid selector %q", s)
+	}
+	return nil
+}
+
+func writableSelector(v interface{}) error {
+	if err := validSelector(v); err != nil {
+		return err
+	}
+	if sel, _ := selector.Parse(v.(string)); sel.ReadOnly() {
+		return fmt.Errorf("read only selector %q", v)
+	}
+	return nil
+}
+
+func notEmpty(v interface{}) error {
+	switch t := v.(type) {
+	case string:
+		if t != "" {
+			return nil
+		}
+	case map[string]interface{}:
+		if len(t) > 0 {
+			return nil
+		}
+	case []interface{}:
+		if len(t) > 0 {
+			return nil
+		}
+	default:
+		return nil
+	}
+	return errors.New("must not be empty")
+}
+
+//Range check the integer is between min and max, both included
+func Range(min, max int) Check {
+	return NewCheck(func(v interface{}) error {
+		if i, _ := Int(v); i < min || i > max {
+			return fmt.Errorf("must be between %d and %d", min, max)
+		}
+		return nil
+	}, map[string]interface{}{"minimum": min, "maximum": max})
+}
+
+//Min check the integer is not lower than min
+func Min(min int) Check {
+	return NewCheck(func(v interface{}) error {
+		if i, _ := Int(v); i < min {
+			return fmt.Errorf("must be %d or greater", min)
+		}
+		return nil
+	}, map[string]interface{}{"minimum": min})
+}
+
+//OneOf check the string is one of the values
+func OneOf(values ...string) Check {
+	return NewCheck(func(v interface{}) error {
+		for _, s := range values {
+			if v == s {
+				return nil
+			}
+		}
+		return fmt.Errorf("must be one of %v", values)
+	}, map[string]interface{}{"enum": values})
+}
+
+//Keys check every key of the object
+func Keys(check Check) Check {
+	return NewCheck(func(v interface{}) error {
+		m, _ := v.(map[string]interface{})
+		for _, k := range sortedKeys(m) {
+			if err := check.Validate(k); err != nil {
+				return fmt.Errorf("key %q: %s", k, err)
+			}
+		}
+		return nil
+	}, map[string]interface{}{"propertyNames": check.Schema()})
+}
+
+//Values check every value of the object or array
+func Values(check Check) Check {
+	return NewCheck(func(v interface{}) error {
+		switch t := v.(type) {
+		case map[string]interface{}:
+			for _, k := range sortedKeys(t) {
+				if err := check.Validate(t[k]); err != nil {
+					return fmt.Errorf("key %q: %s", k, err)
+				}
+			}
+		case []interface{}:
+			for i, e := range t {
+				if err := check.Validate(e); err != nil {
+					return fmt.Errorf("item %d: %s", i, err)
+				}
+			}
+		}
+		return nil
+	}, map[string]interface{}{"additionalProperties": check.Schema(), "items": check.Schema()})
+}
+
+//Items check every item of the array against the field, e.g. the nested fields of the object items
+func Items(item Field) Check {
+	return NewCheck(func(v interface{}) error {
+		arr, _ := v.([]interface{})
+		for i, e := range arr {
+			for _, issue := range item.check("", e) {
+				if issue.Warning {
+					continue
+				}
+				if issue.Field == "" {
+					return fmt.Errorf("item %d: %s", i, issue.Msg)
+				}
+				return fmt.Errorf("item %d: %s: %s", i, strings.TrimPrefix(issue.Field, "."), issue.Msg)
+			}
+		}
+		return nil
+	}, map[string]interface{}{"items": item.Schema()})
+}
+
+//All run the checks in order, stopping at the first error. The schema keywords are merged
+func All(checks ...Check) Check {
+	schema := map[string]interface{}{}
+	for _, check := range checks {
+		for k, v := range check.schema {
+			schema[k] = v
+		}
+	}
+	return NewCheck(func(v interface{}) error {
+		for _, check := range checks {
+			if err := check.Validate(v); err != nil {
+				return err
+			}
+		}
+		return nil
+	}, schema)
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
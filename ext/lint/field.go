@@ -0,0 +1,105 @@
+package lint
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+//Kind expected type of a field value
+type Kind string
+
+const (
+	//KindString string values
+	KindString Kind = "string"
+	//KindInt integers, numeric strings are accepted as the module parsers do
+	KindInt Kind = "integer"
+	//KindBool boolean values
+	KindBool Kind = "boolean"
+	//KindObject objects, their Fields are checked when declared
+	KindObject Kind = "object"
+	//KindArray arrays
+	KindArray Kind = "array"
+	//KindDuration strings parsed by time.ParseDuration, e.g. 5s
+	KindDuration Kind = "duration"
+	//KindSelector request data selectors, see the selector package
+	KindSelector Kind = "selector"
+)
+
+//Field a field of a config block
+type Field struct {
+	Name     string
+	Kind     Kind
+	Required bool
+	//Fields nested fields of the objects
+	Fields []Field
+	//Description documentation of the field, published in the schema
+	Description string
+	//Check validation of the value once its kind matches
+	Check Check
+}
+
+func (f Field) check(path string, v interface{}) []Issue {
+	if err := f.Kind.check(v); err != nil {
+		return []Issue{{Field: path, Msg: err.Error()}}
+	}
+
+	var issues []Issue
+	if f.Kind == KindObject {
+		issues = checkFields(path+".", v.(map[string]interface{}), f.Fields)
+		if hasErrors(issues) {
+			return issues
+		}
+	}
+
+	if err := f.Check.Validate(v); err != nil {
+		issues = append(issues, Issue{Field: path, Msg: err.Error()})
+	}
+	return issues
+}
+
+func (k Kind) check(v interface{}) error {
+	switch k {
+	case KindString:
+		if _, ok := v.(string); !ok {
+			return fmt.Errorf("must be a string")
+		}
+	case KindInt:
+		if _, err := Int(v); err != nil {
+			return err
+		}
+	case KindBool:
+		if _, ok := v.(bool); !ok {
+			return fmt.Errorf("must be a boolean")
+		}
+	case KindObject:
+		if _, ok := v.(map[string]interface{}); !ok {
+			return fmt.Errorf("must be an object")
+		}
+	case KindArray:
+		if _, ok := v.([]interface{}); !ok {
+			return fmt.Errorf("must be an array")
+		}
+	case KindDuration:
+		s, ok := v.(string)
+		if !ok {
+			return fmt.Errorf("must be a duration string, e.g. 5s")
+		}
+		if _, err := time.ParseDuration(s); err != nil {
+			return fmt.Errorf("invalid duration %q", s)
+		}
+	case KindSelector:
+		return Selector.Validate(v)
+	}
+	return nil
+}
+
+//Int the value of an integer field, parsed as the module parsers do
+func Int(v interface{}) (int, error) {
+	i, err := strconv.Atoi(fmt.Sprintf("%v", v))
+	if err != nil {
+		return 0, errors.New("must be an integer")
+	}
+	return i, nil
+}
@@ -0,0 +1,193 @@
+//Package lint validates the extra config blocks of the ext modules. The module parsers skip the
+//invalid settings, or the whole module, so a typo can silently disable a feature. Every module
+//describes its block with a Linter and Lint reports all the problems found in the gateway config.
+package lint
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/devopsfaith/krakend/config"
+)
+
+//Scope levels of the config where a namespace is read
+type Scope int
+
+const (
+	//ScopeService the service extra config
+	ScopeService Scope = 1 << iota
+	//ScopeEndpoint the endpoint extra config
+	ScopeEndpoint
+	//ScopeBackend the backend extra config
+	ScopeBackend
+)
+
+//Linter describes the config block of a module namespace
+type Linter struct {
+	Namespace string
+	Scope     Scope
+	//Security the module enforces a security check, so an invalid block leaves the gateway unprotected
+	Security bool
+	//Fields the known fields of the block. Unknown fields are reported when not empty
+	Fields []Field
+	//Check validation across the fields of the block, run once the fields are valid
+	Check func(map[string]interface{}) []Issue
+}
+
+//Issue problem found in a config block, Field is the dotted path of the offending field
+type Issue struct {
+	Field string
+	Msg   string
+	//Warning the setting is ignored but the module works as configured, e.g. unknown fields
+	Warning bool
+}
+
+//Error problem found in the gateway config
+type Error struct {
+	//Location the service, endpoint or backend declaring the block
+	Location  string
+	Namespace string
+	Field     string
+	Msg       string
+	//Security the block of a security module is invalid, so the endpoint could be left unprotected
+	Security bool
+}
+
+func (e *Error) Error() string {
+	path := e.Namespace
+	if e.Field != "" {
+		path += "." + e.Field
+	}
+	return fmt.Sprintf("%s: %s: %s", e.Location, path, e.Msg)
+}
+
+//Errors problems found in the gateway config
+type Errors []*Error
+
+func (e Errors) Error() string {
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "\n")
+}
+
+//Security the errors found in the blocks of the security modules
+func (e Errors) Security() Errors {
+	var res Errors
+	for _, err := range e {
+		if err.Security {
+			res = append(res, err)
+		}
+	}
+	return res
+}
+
+//Lint check the service, endpoint and backend extra config blocks with the linters
+func Lint(cfg config.ServiceConfig, linters ...Linter) Errors {
+	var errs Errors
+
+	errs = append(errs, lintLevel("service", ScopeService, cfg.ExtraConfig, linters)...)
+	for _, e := range cfg.Endpoints {
+		location := fmt.Sprintf("endpoint %s %s", method(e.Method), e.Endpoint)
+		errs = append(errs, lintLevel(location, ScopeEndpoint, e.ExtraConfig, linters)...)
+
+		for i, b := range e.Backend {
+			backend := fmt.Sprintf("%s backend %d (%s)", location, i, b.URLPattern)
+			errs = append(errs, lintLevel(backend, ScopeBackend, b.ExtraConfig, linters)...)
+		}
+	}
+
+	return errs
+}
+
+func lintLevel(location string, scope Scope, cfg config.ExtraConfig, linters []Linter) Errors {
+	var errs Errors
+	for _, l := range linters {
+		v, ok := cfg[l.Namespace]
+		if !ok {
+			continue
+		}
+
+		fail := func(i Issue) {
+			errs = append(errs, &Error{
+				Location:  location,
+				Namespace: l.Namespace,
+				Field:     i.Field,
+				Msg:       i.Msg,
+				Security:  l.Security && !i.Warning,
+			})
+		}
+
+		if l.Scope&scope == 0 {
+			fail(Issue{Msg: "not supported at this level, the block is ignored"})
+			continue
+		}
+
+		tmp, ok := v.(map[string]interface{})
+		if !ok {
+			fail(Issue{Msg: "must be an object"})
+			continue
+		}
+
+		issues := checkFields("", tmp, l.Fields)
+		if !hasErrors(issues) && l.Check != nil {
+			issues = append(issues, l.Check(tmp)...)
+		}
+		for _, i := range issues {
+			fail(i)
+		}
+	}
+	return errs
+}
+
+func checkFields(prefix string, tmp map[string]interface{}, fields []Field) []Issue {
+	if len(fields) == 0 {
+		return nil
+	}
+
+	var issues []Issue
+	known := make(map[string]bool, len(fields))
+	for _, f := range fields {
+		known[f.Name] = true
+
+		v, ok := tmp[f.Name]
+		if !ok {
+			if f.Required {
+				issues = append(issues, Issue{Field: prefix + f.Name, Msg: "required"})
+			}
+			continue
+		}
+		issues = append(issues, f.check(prefix+f.Name, v)...)
+	}
+
+	var unknown []string
+	for name := range tmp {
+		if !known[name] {
+			unknown = append(unknown, name)
+		}
+	}
+	sort.Strings(unknown)
+	for _, name := range unknown {
+		issues = append(issues, Issue{Field: prefix + name, Msg: "unknown field, ignored", Warning: true})
+	}
+
+	return issues
+}
+
+func hasErrors(issues []Issue) bool {
+	for _, i := range issues {
+		if !i.Warning {
+			return true
+		}
+	}
+	return false
+}
+
+func method(m string) string {
+	if m == "" {
+		return "GET"
+	}
+	return strings.ToUpper(m)
+}
@@ -0,0 +1,169 @@
+package lint
+
+import (
+	"testing"
+
+	"github.com/devopsfaith/krakend/config"
+	"github.com/stretchr/testify/assert"
+)
+
+var testLinter = Linter{
+	Namespace: "test",
+	Scope:     ScopeEndpoint,
+	Security:  true,
+	Fields: []Field{
+		{Name: "address", Kind: KindString, Required: true, Check: NotEmpty},
+		{Name: "size", Kind: KindInt, Check: Min(0)},
+		{Name: "timeout", Kind: KindDuration},
+		{Name: "source", Kind: KindSelector},
+		{Name: "target", Kind: KindString, Check: WritableSelector},
+		{Name: "mode", Kind: KindString, Check: OneOf("a", "b")},
+		{Name: "client", Kind: KindObject, Fields: []Field{
+			{Name: "retries", Kind: KindInt},
+		}},
+	},
+}
+
+func endpointConfig(extra config.ExtraConfig) config.ServiceConfig {
+	return config.ServiceConfig{
+		Endpoints: []*config.EndpointConfig{
+			{
+				Endpoint:    "/foo",
+				ExtraConfig: extra,
+			},
+		},
+	}
+}
+
+func TestLintValid(t *testing.T) {
+	errs := Lint(endpointConfig(config.ExtraConfig{
+		"test": map[string]interface{}{
+			"address": "http://localhost:8080",
+			"size":    "10",
+			"timeout": "5s",
+			"source":  "query.key",
+			"target":  "header.X-Key",
+			"mode":    "a",
+			"client": map[string]interface{}{
+				"retries": 2.0,
+			},
+		},
+	}), testLinter)
+
+	assert.Empty(t, errs)
+}
+
+func TestLintErrors(t *testing.T) {
+	errs := Lint(endpointConfig(config.ExtraConfig{
+		"test": map[string]interface{}{
+			"size":    -1,
+			"timeout": "5 seconds",
+			"source":  "nowhere",
+			"target":  "param.id",
+			"mode":    "c",
+			"client": map[string]interface{}{
+				"retries": "many",
+			},
+		},
+	}), testLinter)
+
+	assert.Equal(t, []string{
+		"endpoint GET /foo: test.address: required",
+		"endpoint GET /foo: test.size: must be 0 or greater",
+		"endpoint GET /foo: test.timeout: invalid duration \"5 seconds\"",
+		"endpoint GET /foo: test.source: invalid selector \"nowhere\"",
+		"endpoint GET /foo: test.target: read only selector \"param.id\"",
+		"endpoint GET /foo: test.mode: must be one of [a b]",
+		"endpoint GET /foo: test.client.retries: must be an integer",
+	}, messages(errs))
+	assert.Len(t, errs.Security(), len(errs))
+}
+
+func TestLintUnknownFields(t *testing.T) {
+	errs := Lint(endpointConfig(config.ExtraConfig{
+		"test": map[string]interface{}{
+			"address":  "http://localhost:8080",
+			"adress":   "http://localhost:8080",
+			"client":   map[string]interface{}{"retry": 2},
+			"failopen": true,
+		},
+	}), testLinter)
+
+	assert.Equal(t, []string{
+		"endpoint GET /foo: test.client.retry: unknown field, ignored",
+		"endpoint GET /foo: test.adress: unknown field, ignored",
+		"endpoint GET /foo: test.failopen: unknown field, ignored",
+	}, messages(errs))
+	assert.Empty(t, errs.Security())
+}
+
+func TestLintScope(t *testing.T) {
+	cfg := endpointConfig(config.ExtraConfig{"test": "enabled"})
+	cfg.Endpoints[0].Method = "post"
+	cfg.ExtraConfig = config.ExtraConfig{"test": map[string]interface{}{"address": "x"}}
+	cfg.Endpoints[0].Backend = []*config.Backend{
+		{
+			URLPattern:  "/bar",
+			ExtraConfig: config.ExtraConfig{"test": map[string]interface{}{"address": "x"}},
+		},
+	}
+
+	errs := Lint(cfg, testLinter)
+
+	assert.Equal(t, []string{
+		"service: test: not supported at this level, the block is ignored",
+		"endpoint POST /foo: test: must be an object",
+		"endpoint POST /foo backend 0 (/bar): test: not supported at this level, the block is ignored",
+	}, messages(errs))
+}
+
+func TestLintCheck(t *testing.T) {
+	l := testLinter
+	l.Security = false
+	l.Check = func(tmp map[string]interface{}) []Issue {
+		return []Issue{{Field: "address", Msg: "not reachable"}}
+	}
+
+	errs := Lint(endpointConfig(config.ExtraConfig{
+		"test": map[string]interface{}{"address": "x"},
+	}), l)
+	assert.Equal(t, []string{"endpoint GET /foo: test.address: not reachable"}, messages(errs))
+	assert.Empty(t, errs.Security())
+
+	//the block level check runs once the fields are valid
+	errs = Lint(endpointConfig(config.ExtraConfig{
+		"test": map[string]interface{}{},
+	}), l)
+	assert.Equal(t, []string{"endpoint GET /foo: test.address: required"}, messages(errs))
+}
+
+func TestErrors(t *testing.T) {
+	errs := Errors{
+		{Location: "service", Namespace: "a", Msg: "must be an object"},
+		{Location: "endpoint GET /foo", Namespace: "b", Field: "c", Msg: "required", Security: true},
+	}
+
+	assert.Equal(t, "service: a: must be an object\nendpoint GET /foo: b.c: required", errs.Error())
+	assert.Equal(t, Errors{errs[1]}, errs.Security())
+}
+
+func TestHelpers(t *testing.T) {
+	assert.NoError(t, Keys(WritableSelector).Validate(map[string]interface{}{"header.X-A": 1}))
+	assert.EqualError(t, Keys(WritableSelector).Validate(map[string]interface{}{"jwt.payload.sub": 1}), `key "jwt.payload.sub": read only selector "jwt.payload.sub"`)
+	assert.EqualError(t, Values(Selector).Validate([]interface{}{"query.a", 1}), "item 1: must be a selector string")
+	assert.EqualError(t, All(NotEmpty, Values(Selector)).Validate(map[string]interface{}{}), "must not be empty")
+	assert.EqualError(t, Range(100, 599).Validate(600), "must be between 100 and 599")
+
+	items := Items(Field{Kind: KindObject, Fields: []Field{{Name: "name", Kind: KindString, Required: true}}})
+	assert.NoError(t, items.Validate([]interface{}{map[string]interface{}{"name": "a", "unknown": 1}}))
+	assert.EqualError(t, items.Validate([]interface{}{map[string]interface{}{"name": "a"}, map[string]interface{}{}}), "item 1: name: required")
+	assert.EqualError(t, items.Validate([]interface{}{"a"}), "item 0: must be an object")
+}
+
+func messages(errs Errors) []string {
+	res := []string{}
+	for _, e := range errs {
+		res = append(res, e.Error())
+	}
+	return res
+}
@@ -0,0 +1,217 @@
+package lint
+
+const (
+	//SchemaID identifier of the gateway config schema
+	SchemaID = "https://github.com/devopsfaith/krakend-ce/krakend.schema.json"
+
+	//durationPattern schema pattern of the strings accepted by time.ParseDuration
+	durationPattern = `^[-+]?(0|([0-9]*(\.[0-9]*)?(ns|us|µs|ms|s|m|h))+)$`
+)
+
+//Schema JSON Schema of the field value
+func (f Field) Schema() map[string]interface{} {
+	s := f.Check.Schema()
+	switch f.Kind {
+	case KindInt:
+		//numeric strings are accepted as the module parsers do
+		s["type"] = []string{"integer", "string"}
+		s["pattern"] = `^[-+]?[0-9]+$`
+	case KindDuration:
+		s["type"] = "string"
+		s["pattern"] = durationPattern
+	case KindSelector:
+		s["type"] = "string"
+		s["pattern"] = selectorPattern
+	case KindObject:
+		s["type"] = "object"
+		if len(f.Fields) > 0 {
+			for k, v := range objectSchema(f.Fields) {
+				s[k] = v
+			}
+		}
+	default:
+		s["type"] = string(f.Kind)
+	}
+
+	if f.Kind != KindObject {
+		removeKeywords(s, "minProperties", "propertyNames", "additionalProperties")
+	}
+	if f.Kind != KindArray {
+		removeKeywords(s, "minItems", "items")
+	}
+	if f.Kind != KindString {
+		removeKeywords(s, "minLength")
+	}
+	if f.Description != "" {
+		s["description"] = f.Description
+	}
+	return s
+}
+
+//Schema JSON Schema of the config block
+func (l Linter) Schema() map[string]interface{} {
+	s := map[string]interface{}{"type": "object"}
+	if len(l.Fields) > 0 {
+		for k, v := range objectSchema(l.Fields) {
+			s[k] = v
+		}
+	}
+	return s
+}
+
+//Schema JSON Schema of the gateway config, the service, endpoint and backend blocks along with the
+//extra config namespaces of the linters at the levels they are read
+func Schema(linters ...Linter) map[string]interface{} {
+	definitions := map[string]interface{}{}
+	for _, l := range linters {
+		definitions[l.Namespace] = l.Schema()
+	}
+
+	extra := func(scope Scope) map[string]interface{} {
+		props := map[string]interface{}{}
+		for _, l := range linters {
+			if l.Scope&scope != 0 {
+				props[l.Namespace] = map[string]interface{}{"$ref": "#/definitions/" + escapeRef(l.Namespace)}
+			}
+		}
+		return map[string]interface{}{"type": "object", "properties": props}
+	}
+
+	backend := coreSchema(backendFields)
+	backend["properties"].(map[string]interface{})["extra_config"] = extra(ScopeBackend)
+
+	endpoint := coreSchema(endpointFields)
+	endpoint["properties"].(map[string]interface{})["extra_config"] = extra(ScopeEndpoint)
+	endpoint["properties"].(map[string]interface{})["backend"] = map[string]interface{}{"type": "array", "items": backend}
+
+	service := coreSchema(serviceFields)
+	service["properties"].(map[string]interface{})["extra_config"] = extra(ScopeService)
+	service["properties"].(map[string]interface{})["endpoints"] = map[string]interface{}{"type": "array", "items": endpoint}
+
+	service["$schema"] = "http://json-schema.org/draft-07/schema#"
+	service["$id"] = SchemaID
+	service["title"] = "KrakenD CE configuration"
+	service["definitions"] = definitions
+	return service
+}
+
+//objectSchema properties of the fields. Unknown fields are ignored by the modules, so they are
+//rejected to catch the typos
+func objectSchema(fields []Field) map[string]interface{} {
+	props := map[string]interface{}{}
+	required := []string{}
+	for _, f := range fields {
+		props[f.Name] = f.Schema()
+		if f.Required {
+			required = append(required, f.Name)
+		}
+	}
+
+	s := map[string]interface{}{
+		"properties":           props,
+		"additionalProperties": false,
+	}
+	if len(required) > 0 {
+		s["required"] = required
+	}
+	return s
+}
+
+//coreSchema schema of the KrakenD config objects. They accept the fields added by newer versions
+func coreSchema(fields []Field) map[string]interface{} {
+	s := objectSchema(fields)
+	s["type"] = "object"
+	delete(s, "additionalProperties")
+	return s
+}
+
+func removeKeywords(s map[string]interface{}, keywords ...string) {
+	for _, k := range keywords {
+		delete(s, k)
+	}
+}
+
+//escapeRef escape the JSON pointer reference token
+func escapeRef(s string) string {
+	res := make([]rune, 0, len(s))
+	for _, r := range s {
+		switch r {
+		case '~':
+			res = append(res, '~', '0')
+		case '/':
+			res = append(res, '~', '1')
+		default:
+			res = append(res, r)
+		}
+	}
+	return string(res)
+}
+
+var (
+	tlsFields = []Field{
+		{Name: "public_key", Kind: KindString},
+		{Name: "private_key", Kind: KindString},
+		{Name: "disabled", Kind: KindBool},
+		{Name: "min_version", Kind: KindString, Check: OneOf("SSL3.0", "TLS10", "TLS11", "TLS12", "TLS13")},
+		{Name: "max_version", Kind: KindString, Check: OneOf("SSL3.0", "TLS10", "TLS11", "TLS12", "TLS13")},
+		{Name: "curve_preferences", Kind: KindArray},
+		{Name: "prefer_server_cipher_suites", Kind: KindBool},
+		{Name: "cipher_suites", Kind: KindArray},
+	}
+
+	serviceFields = []Field{
+		{Name: "version", Kind: KindInt, Required: true, Check: Range(2, 2), Description: "config file format version"},
+		{Name: "name", Kind: KindString},
+		{Name: "port", Kind: KindInt, Check: Range(0, 65535)},
+		{Name: "host", Kind: KindArray, Description: "default hosts of the backends"},
+		{Name: "timeout", Kind: KindDuration, Description: "default timeout of the endpoints"},
+		{Name: "cache_ttl", Kind: KindDuration},
+		{Name: "output_encoding", Kind: KindString},
+		{Name: "read_timeout", Kind: KindDuration},
+		{Name: "write_timeout", Kind: KindDuration},
+		{Name: "idle_timeout", Kind: KindDuration},
+		{Name: "read_header_timeout", Kind: KindDuration},
+		{Name: "disable_keep_alives", Kind: KindBool},
+		{Name: "disable_compression", Kind: KindBool},
+		{Name: "disable_rest", Kind: KindBool},
+		{Name: "max_idle_connections", Kind: KindInt, Check: Min(0)},
+		{Name: "max_idle_connections_per_host", Kind: KindInt, Check: Min(0)},
+		{Name: "idle_connection_timeout", Kind: KindDuration},
+		{Name: "response_header_timeout", Kind: KindDuration},
+		{Name: "expect_continue_timeout", Kind: KindDuration},
+		{Name: "dialer_timeout", Kind: KindDuration},
+		{Name: "dialer_fallback_delay", Kind: KindDuration},
+		{Name: "dialer_keep_alive", Kind: KindDuration},
+		{Name: "plugin", Kind: KindObject, Fields: []Field{
+			{Name: "folder", Kind: KindString},
+			{Name: "pattern", Kind: KindString},
+		}},
+		{Name: "tls", Kind: KindObject, Fields: tlsFields},
+	}
+
+	endpointFields = []Field{
+		{Name: "endpoint", Kind: KindString, Required: true, Check: NotEmpty},
+		{Name: "method", Kind: KindString},
+		{Name: "timeout", Kind: KindDuration},
+		{Name: "cache_ttl", Kind: KindDuration},
+		{Name: "concurrent_calls", Kind: KindInt, Check: Min(1)},
+		{Name: "querystring_params", Kind: KindArray},
+		{Name: "headers_to_pass", Kind: KindArray},
+		{Name: "output_encoding", Kind: KindString},
+	}
+
+	backendFields = []Field{
+		{Name: "url_pattern", Kind: KindString, Required: true},
+		{Name: "host", Kind: KindArray},
+		{Name: "method", Kind: KindString},
+		{Name: "encoding", Kind: KindString},
+		{Name: "sd", Kind: KindString},
+		{Name: "group", Kind: KindString},
+		{Name: "target", Kind: KindString},
+		{Name: "is_collection", Kind: KindBool},
+		{Name: "disable_host_sanitize", Kind: KindBool},
+		{Name: "blacklist", Kind: KindArray},
+		{Name: "whitelist", Kind: KindArray},
+		{Name: "mapping", Kind: KindObject},
+	}
+)
@@ -0,0 +1,75 @@
+package lint
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFieldSchema(t *testing.T) {
+	assert.Equal(t, map[string]interface{}{
+		"type":    []string{"integer", "string"},
+		"pattern": `^[-+]?[0-9]+$`,
+		"minimum": 0,
+	}, Field{Name: "size", Kind: KindInt, Check: Min(0)}.Schema())
+
+	assert.Equal(t, map[string]interface{}{
+		"type":          "object",
+		"minProperties": 1,
+		"propertyNames": map[string]interface{}{"pattern": selectorPattern},
+		"description":   "selectors",
+	}, Field{Name: "map", Kind: KindObject, Check: All(NotEmpty, Keys(WritableSelector)), Description: "selectors"}.Schema())
+
+	assert.Equal(t, map[string]interface{}{
+		"type":      "string",
+		"minLength": 1,
+		"enum":      []string{"a", "b"},
+	}, Field{Name: "mode", Kind: KindString, Check: All(NotEmpty, OneOf("a", "b"))}.Schema())
+}
+
+func TestLinterSchema(t *testing.T) {
+	s := testLinter.Schema()
+
+	assert.Equal(t, "object", s["type"])
+	assert.Equal(t, false, s["additionalProperties"])
+	assert.Equal(t, []string{"address"}, s["required"])
+	assert.Len(t, s["properties"], len(testLinter.Fields))
+
+	client := s["properties"].(map[string]interface{})["client"].(map[string]interface{})
+	assert.Equal(t, false, client["additionalProperties"])
+	assert.Contains(t, client["properties"], "retries")
+}
+
+func TestSchema(t *testing.T) {
+	service := Linter{Namespace: "github_com/test/service", Scope: ScopeService}
+	s := Schema(testLinter, service)
+
+	_, err := json.Marshal(s)
+	assert.NoError(t, err)
+
+	assert.Equal(t, SchemaID, s["$id"])
+	assert.Contains(t, s["definitions"], "test")
+	assert.Contains(t, s["definitions"], "github_com/test/service")
+
+	props := s["properties"].(map[string]interface{})
+	assert.Equal(t, map[string]interface{}{
+		"github_com~1test~1service": nil,
+	}, refs(props["extra_config"]))
+
+	endpoint := props["endpoints"].(map[string]interface{})["items"].(map[string]interface{})
+	assert.Equal(t, []string{"endpoint"}, endpoint["required"])
+	assert.Equal(t, map[string]interface{}{"test": nil}, refs(endpoint["properties"].(map[string]interface{})["extra_config"]))
+
+	backend := endpoint["properties"].(map[string]interface{})["backend"].(map[string]interface{})["items"].(map[string]interface{})
+	assert.Equal(t, map[string]interface{}{}, refs(backend["properties"].(map[string]interface{})["extra_config"]))
+}
+
+//refs the definitions referenced by the extra config schema
+func refs(extra interface{}) map[string]interface{} {
+	res := map[string]interface{}{}
+	for _, v := range extra.(map[string]interface{})["properties"].(map[string]interface{}) {
+		res[v.(map[string]interface{})["$ref"].(string)[len("#/definitions/"):]] = nil
+	}
+	return res
+}
@@ -0,0 +1,235 @@
+package listener
+
+import (
+	"crypto/tls"
+	"fmt"
+	"strings"
+
+	"github.com/devopsfaith/krakend-ce/ext/certs"
+	"github.com/devopsfaith/krakend-ce/ext/lint"
+	"github.com/devopsfaith/krakend/config"
+)
+
+const (
+	namespace         = "github_com/sahalzain/krakend-listeners"
+	endpointNamespace = "github_com/sahalzain/krakend-listeners/endpoint"
+
+	//DefaultName name of the main listener, the one on the service port
+	DefaultName = "default"
+)
+
+//Linter strict validation of the service config block declaring the listeners
+var Linter = lint.Linter{
+	Namespace: namespace,
+	Scope:     lint.ScopeService,
+	Fields: []lint.Field{
+		{Name: "listeners", Kind: lint.KindArray, Required: true, Check: lint.All(lint.NotEmpty, lint.Items(listenerField))},
+	},
+	Check: func(tmp map[string]interface{}) []lint.Issue {
+		var issues []lint.Issue
+		seen := map[string]bool{DefaultName: true}
+		for i, v := range tmp["listeners"].([]interface{}) {
+			name := v.(map[string]interface{})["name"].(string)
+			if seen[name] {
+				issues = append(issues, lint.Issue{Field: fmt.Sprintf("listeners.%d.name", i), Msg: fmt.Sprintf("duplicated listener %q", name)})
+			}
+			seen[name] = true
+		}
+		return issues
+	},
+}
+
+//EndpointLinter strict validation of the endpoint config block assigning the endpoint to the listeners
+var EndpointLinter = lint.Linter{
+	Namespace: endpointNamespace,
+	Scope:     lint.ScopeEndpoint,
+	Fields: []lint.Field{
+		{Name: "listeners", Kind: lint.KindArray, Check: lint.Values(nonEmpty), Description: "the listeners serving the endpoint, the tags and patterns are ignored when set"},
+		{Name: "tags", Kind: lint.KindArray, Check: lint.Values(nonEmpty)},
+	},
+}
+
+var (
+	//nonEmpty check of the listener names and tags
+	nonEmpty = lint.NewCheck(func(v interface{}) error {
+		if s, ok := v.(string); !ok || s == "" {
+			return fmt.Errorf("must be a non empty string")
+		}
+		return nil
+	}, map[string]interface{}{"type": "string", "minLength": 1})
+
+	tlsVersions = []string{"SSL3.0", "TLS10", "TLS11", "TLS12", "TLS13"}
+
+	listenerField = lint.Field{Kind: lint.KindObject, Fields: []lint.Field{
+		{Name: "name", Kind: lint.KindString, Required: true, Check: lint.NotEmpty},
+		{Name: "address", Kind: lint.KindString, Required: true, Check: lint.NotEmpty, Description: "host and port to listen on, e.g. 127.0.0.1:8090"},
+		{Name: "tls", Kind: lint.KindObject, Description: "the certificates selected by the SNI host of the main listener are served instead of the key pair when declared", Fields: []lint.Field{
+			{Name: "public_key", Kind: lint.KindString, Required: true},
+			{Name: "private_key", Kind: lint.KindString, Required: true},
+			{Name: "min_version", Kind: lint.KindString, Check: lint.OneOf(tlsVersions...)},
+			{Name: "max_version", Kind: lint.KindString, Check: lint.OneOf(tlsVersions...)},
+		}},
+		{Name: "endpoints", Kind: lint.KindArray, Check: lint.Values(nonEmpty), Description: "endpoint patterns served, a trailing * matches any suffix"},
+		{Name: "tags", Kind: lint.KindArray, Check: lint.Values(nonEmpty), Description: "endpoint tags served"},
+		{Name: "admin", Kind: lint.KindBool, Description: "serve the routes that are not endpoints, e.g. the health and debug ones"},
+	}}
+)
+
+//Listener an extra server of the gateway, serving the endpoints assigned to it
+type Listener struct {
+	Name    string
+	Address string
+	TLS     *TLS
+	//Endpoints patterns of the endpoints served, a trailing * matches any suffix
+	Endpoints []string
+	//Tags the endpoints with any of the tags are served
+	Tags []string
+	//Admin serve the routes that are not endpoints
+	Admin bool
+}
+
+//TLS the certificate and versions of a listener
+type TLS struct {
+	PublicKey  string
+	PrivateKey string
+	MinVersion uint16
+	MaxVersion uint16
+}
+
+//config the TLS config of the listener. The certificates of the main listener are handed by the SNI
+//host of every handshake when it serves them, with the versions of the listener, so they are
+//reloaded and verify the client certificates here as well. The key pair of the listener is served
+//otherwise
+func (t *TLS) config() *tls.Config {
+	return &tls.Config{
+		MinVersion: t.MinVersion,
+		MaxVersion: t.MaxVersion,
+		GetConfigForClient: func(hello *tls.ClientHelloInfo) (*tls.Config, error) {
+			c, err := certs.ConfigForClient(hello)
+			if c == nil || err != nil {
+				return nil, err
+			}
+			c = c.Clone()
+			c.MinVersion, c.MaxVersion = t.MinVersion, t.MaxVersion
+			return c, nil
+		},
+	}
+}
+
+//catchAll the listener serves the endpoints not assigned to any listener
+func (l Listener) catchAll() bool {
+	return !l.Admin && len(l.Endpoints) == 0 && len(l.Tags) == 0
+}
+
+//serves the listener patterns match the endpoint or it shares any of its tags
+func (l Listener) serves(endpoint string, tags []string) bool {
+	for _, p := range l.Endpoints {
+		if p == endpoint || strings.HasSuffix(p, "*") && strings.HasPrefix(endpoint, strings.TrimSuffix(p, "*")) {
+			return true
+		}
+	}
+	for _, t := range l.Tags {
+		for _, tag := range tags {
+			if t == tag {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func configGetter(cfg config.ExtraConfig) []Listener {
+	v, ok := cfg[namespace]
+	if !ok {
+		return nil
+	}
+	tmp, ok := v.(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	arr, ok := tmp["listeners"].([]interface{})
+	if !ok {
+		return nil
+	}
+
+	var res []Listener
+	seen := map[string]bool{DefaultName: true}
+	for _, v := range arr {
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		l := Listener{
+			Endpoints: parseStrings(m["endpoints"]),
+			Tags:      parseStrings(m["tags"]),
+		}
+		l.Name, _ = m["name"].(string)
+		l.Address, _ = m["address"].(string)
+		l.Admin, _ = m["admin"].(bool)
+		if l.Name == "" || l.Address == "" || seen[l.Name] {
+			continue
+		}
+		seen[l.Name] = true
+
+		if t, ok := m["tls"].(map[string]interface{}); ok {
+			l.TLS = &TLS{
+				MinVersion: parseTLSVersion(t["min_version"], tls.VersionTLS12),
+				MaxVersion: parseTLSVersion(t["max_version"], tls.VersionTLS13),
+			}
+			l.TLS.PublicKey, _ = t["public_key"].(string)
+			l.TLS.PrivateKey, _ = t["private_key"].(string)
+			if l.TLS.PublicKey == "" || l.TLS.PrivateKey == "" {
+				continue
+			}
+		}
+		res = append(res, l)
+	}
+	return res
+}
+
+//endpointConfig the listeners and tags of an endpoint
+type endpointConfig struct {
+	Listeners []string
+	Tags      []string
+}
+
+func endpointConfigGetter(cfg config.ExtraConfig) endpointConfig {
+	tmp, ok := cfg[endpointNamespace].(map[string]interface{})
+	if !ok {
+		return endpointConfig{}
+	}
+	return endpointConfig{
+		Listeners: parseStrings(tmp["listeners"]),
+		Tags:      parseStrings(tmp["tags"]),
+	}
+}
+
+func parseStrings(v interface{}) []string {
+	arr, ok := v.([]interface{})
+	if !ok {
+		return nil
+	}
+	res := []string{}
+	for _, e := range arr {
+		if s, ok := e.(string); ok && s != "" {
+			res = append(res, s)
+		}
+	}
+	return res
+}
+
+func parseTLSVersion(v interface{}, def uint16) uint16 {
+	switch v {
+	case "SSL3.0":
+		return tls.VersionSSL30
+	case "TLS10":
+		return tls.VersionTLS10
+	case "TLS11":
+		return tls.VersionTLS11
+	case "TLS12":
+		return tls.VersionTLS12
+	case "TLS13":
+		return tls.VersionTLS13
+	}
+	return def
+}
@@ -0,0 +1,102 @@
+package listener
+
+import (
+	"crypto/tls"
+	"testing"
+
+	"github.com/devopsfaith/krakend-ce/ext/lint"
+	"github.com/devopsfaith/krakend/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConfigGetter(t *testing.T) {
+	listeners := configGetter(config.ExtraConfig{
+		namespace: map[string]interface{}{
+			"listeners": []interface{}{
+				map[string]interface{}{"name": "internal", "address": ":8081", "tags": []interface{}{"internal", 1}},
+				map[string]interface{}{"name": "admin", "address": "127.0.0.1:8090", "admin": true, "tls": map[string]interface{}{
+					"public_key":  "cert.pem",
+					"private_key": "key.pem",
+					"min_version": "TLS13",
+				}},
+				map[string]interface{}{"name": "internal", "address": ":8082"},
+				map[string]interface{}{"name": DefaultName, "address": ":8083"},
+				map[string]interface{}{"name": "no address"},
+				map[string]interface{}{"name": "no keys", "address": ":8084", "tls": map[string]interface{}{}},
+				"wrong",
+			},
+		},
+	})
+
+	assert.Equal(t, []Listener{
+		{Name: "internal", Address: ":8081", Tags: []string{"internal"}},
+		{Name: "admin", Address: "127.0.0.1:8090", Admin: true, TLS: &TLS{
+			PublicKey:  "cert.pem",
+			PrivateKey: "key.pem",
+			MinVersion: tls.VersionTLS13,
+			MaxVersion: tls.VersionTLS13,
+		}},
+	}, listeners)
+
+	assert.Nil(t, configGetter(config.ExtraConfig{}))
+}
+
+func TestEndpointConfigGetter(t *testing.T) {
+	assert.Equal(t, endpointConfig{Listeners: []string{"internal"}, Tags: []string{"a", "b"}}, endpointConfigGetter(config.ExtraConfig{
+		endpointNamespace: map[string]interface{}{
+			"listeners": []interface{}{"internal"},
+			"tags":      []interface{}{"a", "b", ""},
+		},
+	}))
+	assert.Equal(t, endpointConfig{}, endpointConfigGetter(config.ExtraConfig{}))
+}
+
+func TestListener_serves(t *testing.T) {
+	l := Listener{Endpoints: []string{"/internal/*", "/status"}, Tags: []string{"internal"}}
+
+	assert.True(t, l.serves("/internal/users/:id", nil))
+	assert.True(t, l.serves("/status", nil))
+	assert.True(t, l.serves("/users", []string{"public", "internal"}))
+	assert.False(t, l.serves("/status/:id", []string{"public"}))
+	assert.False(t, l.catchAll())
+	assert.True(t, Listener{Name: "public"}.catchAll())
+	assert.False(t, Listener{Name: "admin", Admin: true}.catchAll())
+}
+
+func TestLinter(t *testing.T) {
+	errs := lint.Lint(config.ServiceConfig{
+		ExtraConfig: config.ExtraConfig{
+			namespace: map[string]interface{}{
+				"listeners": []interface{}{
+					map[string]interface{}{"name": "internal", "address": ":8081"},
+					map[string]interface{}{"name": "internal", "address": ":8082"},
+				},
+			},
+		},
+		Endpoints: []*config.EndpointConfig{
+			{
+				Endpoint: "/foo",
+				ExtraConfig: config.ExtraConfig{
+					endpointNamespace: map[string]interface{}{"listeners": "internal"},
+				},
+			},
+		},
+	}, Linter, EndpointLinter)
+
+	assert.Len(t, errs, 2)
+	assert.Equal(t, "service: github_com/sahalzain/krakend-listeners.listeners.1.name: duplicated listener \"internal\"", errs[0].Error())
+	assert.Equal(t, "endpoint GET /foo: github_com/sahalzain/krakend-listeners/endpoint.listeners: must be an array", errs[1].Error())
+
+	errs = lint.Lint(config.ServiceConfig{
+		ExtraConfig: config.ExtraConfig{
+			namespace: map[string]interface{}{
+				"listeners": []interface{}{
+					map[string]interface{}{"name": "admin", "address": ":8090", "tls": map[string]interface{}{"public_key": "cert.pem"}},
+				},
+			},
+		},
+	}, Linter)
+
+	assert.Len(t, errs, 1)
+	assert.Equal(t, "item 0: tls.private_key: required", errs[0].Msg)
+}
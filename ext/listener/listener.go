@@ -0,0 +1,187 @@
+//Package listener serves the gateway router on several listeners, so the public, internal and
+//admin routes can be exposed on different ports from the same process. Every request is marked
+//with the listener receiving it and the routes not assigned to that listener are not found.
+package listener
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/devopsfaith/krakend-ce/ext/certs"
+	"github.com/devopsfaith/krakend/config"
+	"github.com/devopsfaith/krakend/logging"
+	router "github.com/devopsfaith/krakend/router/gin"
+	"github.com/gin-gonic/gin"
+)
+
+type contextKey struct{}
+
+//Name the name of the listener receiving the request
+func Name(ctx context.Context) (string, bool) {
+	name, ok := ctx.Value(contextKey{}).(string)
+	return name, ok
+}
+
+//Handler mark the requests with the name of the listener receiving them
+func Handler(name string, h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), contextKey{}, name)))
+	})
+}
+
+//RunServer wrap the RunServer so the listeners declared in the service extra config serve the
+//router along with the main one, the default listener. The first listener stopping stops the rest
+func RunServer(l logging.Logger, next router.RunServerFunc) router.RunServerFunc {
+	return func(ctx context.Context, cfg config.ServiceConfig, h http.Handler) error {
+		listeners := configGetter(cfg.ExtraConfig)
+		if len(listeners) == 0 {
+			return next(ctx, cfg, h)
+		}
+
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+
+		errs := make(chan error, len(listeners)+1)
+		go func() { errs <- next(ctx, cfg, Handler(DefaultName, h)) }()
+		for _, ln := range listeners {
+			l.Info(fmt.Sprintf("Listener %s on %s", ln.Name, ln.Address))
+			go func(ln Listener) {
+				err := ln.serve(ctx, cfg, Handler(ln.Name, h))
+				if err != nil && err != http.ErrServerClosed {
+					err = fmt.Errorf("listener %s: %s", ln.Name, err)
+				}
+				errs <- err
+			}(ln)
+		}
+
+		err := <-errs
+		cancel()
+		for range listeners {
+			if e := <-errs; err == nil {
+				err = e
+			}
+		}
+		return err
+	}
+}
+
+//serve run a server for the listener, with the timeouts of the main one, until the context is done
+func (ln Listener) serve(ctx context.Context, cfg config.ServiceConfig, h http.Handler) error {
+	s := &http.Server{
+		Addr:              ln.Address,
+		Handler:           h,
+		ReadTimeout:       cfg.ReadTimeout,
+		WriteTimeout:      cfg.WriteTimeout,
+		ReadHeaderTimeout: cfg.ReadHeaderTimeout,
+		IdleTimeout:       cfg.IdleTimeout,
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		if ln.TLS == nil {
+			done <- s.ListenAndServe()
+			return
+		}
+		s.TLSConfig = ln.TLS.config()
+		s.Handler = certs.Handler(h)
+		done <- s.ListenAndServeTLS(ln.TLS.PublicKey, ln.TLS.PrivateKey)
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return s.Shutdown(context.Background())
+	}
+}
+
+//Register add the middleware rejecting the requests to the routes not assigned to the listener
+//receiving them. It must be registered before the routes it covers
+func Register(cfg config.ServiceConfig, l logging.Logger, engine *gin.Engine) {
+	listeners := configGetter(cfg.ExtraConfig)
+	if len(listeners) == 0 {
+		return
+	}
+	r := newRoutes(cfg, listeners, l)
+
+	engine.Use(func(c *gin.Context) {
+		name, ok := Name(c.Request.Context())
+		if !ok || r.allowed(name, c.Request.Method, c.FullPath()) {
+			c.Next()
+			return
+		}
+		c.AbortWithStatus(http.StatusNotFound)
+	})
+}
+
+//routes the listeners serving every route
+type routes struct {
+	//endpoints the listeners of the endpoints, by method and path
+	endpoints map[string]map[string]bool
+	//admin the listeners of the routes that are not endpoints
+	admin map[string]bool
+}
+
+func newRoutes(cfg config.ServiceConfig, listeners []Listener, l logging.Logger) routes {
+	known := map[string]bool{DefaultName: true}
+	catchAll := map[string]bool{DefaultName: true}
+	admin := map[string]bool{}
+	for _, ln := range listeners {
+		known[ln.Name] = true
+		if ln.catchAll() {
+			catchAll[ln.Name] = true
+		}
+		if ln.Admin {
+			admin[ln.Name] = true
+		}
+	}
+	if len(admin) == 0 {
+		admin[DefaultName] = true
+	}
+
+	r := routes{endpoints: map[string]map[string]bool{}, admin: admin}
+	for _, e := range cfg.Endpoints {
+		key := routeKey(e.Method, e.Endpoint)
+		ec := endpointConfigGetter(e.ExtraConfig)
+
+		names := map[string]bool{}
+		for _, n := range ec.Listeners {
+			if !known[n] {
+				l.Warning(fmt.Sprintf("listeners: endpoint %s assigned to the unknown listener %s", key, n))
+				continue
+			}
+			names[n] = true
+		}
+		if len(ec.Listeners) == 0 {
+			for _, ln := range listeners {
+				if ln.serves(e.Endpoint, ec.Tags) {
+					names[ln.Name] = true
+				}
+			}
+			if len(names) == 0 {
+				names = catchAll
+			}
+		}
+		r.endpoints[key] = names
+	}
+	return r
+}
+
+//allowed the route is served by the listener. The routes that are not endpoints, including the
+//unrouted requests answered by a middleware such as the health probes, are served by the admin
+//listeners only
+func (r routes) allowed(listener, method, path string) bool {
+	if names, ok := r.endpoints[routeKey(method, path)]; ok {
+		return names[listener]
+	}
+	return r.admin[listener]
+}
+
+func routeKey(method, path string) string {
+	if method == "" {
+		method = http.MethodGet
+	}
+	return strings.ToUpper(method) + " " + path
+}
@@ -0,0 +1,189 @@
+package listener
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/devopsfaith/krakend/config"
+	"github.com/devopsfaith/krakend/logging"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+var logger, _ = logging.NewLogger("CRITICAL", ioutil.Discard, "")
+
+func testConfig() config.ServiceConfig {
+	return config.ServiceConfig{
+		ExtraConfig: config.ExtraConfig{
+			namespace: map[string]interface{}{
+				"listeners": []interface{}{
+					map[string]interface{}{"name": "internal", "address": ":8081", "endpoints": []interface{}{"/internal/*"}, "tags": []interface{}{"internal"}},
+					map[string]interface{}{"name": "admin", "address": ":8090", "admin": true},
+				},
+			},
+		},
+		Endpoints: []*config.EndpointConfig{
+			{Endpoint: "/public", Method: "GET"},
+			{Endpoint: "/internal/users", Method: "GET"},
+			{Endpoint: "/orders", Method: "POST", ExtraConfig: config.ExtraConfig{
+				endpointNamespace: map[string]interface{}{"tags": []interface{}{"internal"}},
+			}},
+			{Endpoint: "/both", Method: "GET", ExtraConfig: config.ExtraConfig{
+				endpointNamespace: map[string]interface{}{"listeners": []interface{}{DefaultName, "internal", "unknown"}},
+			}},
+		},
+	}
+}
+
+func TestRegister(t *testing.T) {
+	cfg := testConfig()
+	engine := gin.New()
+	Register(cfg, logger, engine)
+	engine.Use(func(c *gin.Context) {
+		if c.Request.URL.Path == "/__live" {
+			c.AbortWithStatus(http.StatusOK)
+		}
+	})
+	engine.GET("/__health", func(c *gin.Context) { c.Status(http.StatusOK) })
+	for _, e := range cfg.Endpoints {
+		engine.Handle(e.Method, e.Endpoint, func(c *gin.Context) { c.Status(http.StatusOK) })
+	}
+
+	for _, tc := range []struct {
+		listener string
+		method   string
+		path     string
+		status   int
+	}{
+		{DefaultName, "GET", "/public", http.StatusOK},
+		{"internal", "GET", "/public", http.StatusNotFound},
+		{"admin", "GET", "/public", http.StatusNotFound},
+		{DefaultName, "GET", "/internal/users", http.StatusNotFound},
+		{"internal", "GET", "/internal/users", http.StatusOK},
+		{"internal", "POST", "/orders", http.StatusOK},
+		{DefaultName, "POST", "/orders", http.StatusNotFound},
+		{DefaultName, "GET", "/both", http.StatusOK},
+		{"internal", "GET", "/both", http.StatusOK},
+		{"admin", "GET", "/__health", http.StatusOK},
+		{DefaultName, "GET", "/__health", http.StatusNotFound},
+		{"internal", "GET", "/__health", http.StatusNotFound},
+		{"internal", "GET", "/unknown", http.StatusNotFound},
+		{"admin", "GET", "/__live", http.StatusOK},
+		{DefaultName, "GET", "/__live", http.StatusNotFound},
+		{"internal", "GET", "/__live", http.StatusNotFound},
+	} {
+		req, _ := http.NewRequest(tc.method, "http://localhost"+tc.path, nil)
+		w := httptest.NewRecorder()
+		Handler(tc.listener, engine).ServeHTTP(w, req)
+		assert.Equal(t, tc.status, w.Code, tc.listener+" "+tc.method+" "+tc.path)
+	}
+
+	// the requests not received by a listener are not filtered
+	req, _ := http.NewRequest("GET", "http://localhost/internal/users", nil)
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestRegister_noListeners(t *testing.T) {
+	engine := gin.New()
+	Register(config.ServiceConfig{}, logger, engine)
+	engine.GET("/public", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	req, _ := http.NewRequest("GET", "http://localhost/public", nil)
+	w := httptest.NewRecorder()
+	Handler("internal", engine).ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestRunServer(t *testing.T) {
+	address := freeAddress(t)
+	cfg := config.ServiceConfig{
+		ExtraConfig: config.ExtraConfig{
+			namespace: map[string]interface{}{
+				"listeners": []interface{}{
+					map[string]interface{}{"name": "internal", "address": address},
+				},
+			},
+		},
+	}
+
+	received := make(chan string, 1)
+	next := func(ctx context.Context, _ config.ServiceConfig, h http.Handler) error {
+		req, _ := http.NewRequest("GET", "http://localhost/", nil)
+		h.ServeHTTP(httptest.NewRecorder(), req)
+		<-ctx.Done()
+		return nil
+	}
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name, _ := Name(r.Context())
+		select {
+		case received <- name:
+		default:
+		}
+		w.Write([]byte(name))
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- RunServer(logger, next)(ctx, cfg, h) }()
+
+	assert.Equal(t, DefaultName, <-received)
+
+	var resp *http.Response
+	var err error
+	for i := 0; i < 50; i++ {
+		if resp, err = http.Get("http://" + address + "/"); err == nil {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if !assert.NoError(t, err) {
+		cancel()
+		return
+	}
+	body, _ := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	assert.Equal(t, "internal", string(body))
+
+	cancel()
+	select {
+	case err := <-done:
+		assert.NoError(t, err)
+	case <-time.After(time.Second):
+		t.Error("the servers should stop with the context")
+	}
+}
+
+func TestRunServer_error(t *testing.T) {
+	cfg := config.ServiceConfig{
+		ExtraConfig: config.ExtraConfig{
+			namespace: map[string]interface{}{
+				"listeners": []interface{}{
+					map[string]interface{}{"name": "internal", "address": freeAddress(t)},
+				},
+			},
+		},
+	}
+	next := func(context.Context, config.ServiceConfig, http.Handler) error {
+		return errors.New("listen tcp :8080: bind: address already in use")
+	}
+
+	err := RunServer(logger, next)(context.Background(), cfg, http.NotFoundHandler())
+	assert.EqualError(t, err, "listen tcp :8080: bind: address already in use")
+}
+
+func freeAddress(t *testing.T) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	return l.Addr().String()
+}
@@ -1,9 +1,15 @@
 package opa
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"strconv"
+	"strings"
+	"time"
 
+	cache "github.com/devopsfaith/krakend-ce/ext/cache"
+	"github.com/devopsfaith/krakend-ce/ext/lint"
 	"github.com/devopsfaith/krakend-ce/ext/service"
 	"github.com/devopsfaith/krakend/config"
 )
@@ -23,10 +29,21 @@ type xtraConfig struct {
 	PayloadMap     map[string]string
 	CacheDuration  int
 	CacheSize      int
+	CacheTTLPath   string
+	CacheKeyFields []string
+	Cache          cache.Config
+	CacheStore     cache.Local
+	Client         service.ClientConfig
+	Breaker        *service.BreakerConfig
+	FailOpen       bool
+	Transport      string
+	ExtAuthzAPI    string
 	Service        service.Policy
 }
 
-func configGetter(cfg config.ExtraConfig) *xtraConfig {
+//configGetter parse the config block of the endpoint. The remote cache entries are kept per endpoint
+//unless a cache_prefix is set
+func configGetter(ctx context.Context, endpoint string, cfg config.ExtraConfig) *xtraConfig {
 	v, ok := cfg[namespace]
 	if !ok {
 		return nil
@@ -40,6 +57,7 @@ func configGetter(cfg config.ExtraConfig) *xtraConfig {
 		BasePath:      basePath,
 		CacheDuration: defaultCacheDuration,
 		CacheSize:     0,
+		Transport:     service.TransportHTTP,
 	}
 
 	if sa, ok := tmp["service_address"].(string); ok {
@@ -58,6 +76,18 @@ func configGetter(cfg config.ExtraConfig) *xtraConfig {
 		conf.Directive = dr
 	}
 
+	if tp, ok := tmp["cache_ttl_path"].(string); ok {
+		conf.CacheTTLPath = tp
+	}
+
+	if kf, ok := tmp["cache_key_fields"].([]interface{}); ok {
+		for _, f := range kf {
+			if fs, ok := f.(string); ok {
+				conf.CacheKeyFields = append(conf.CacheKeyFields, fs)
+			}
+		}
+	}
+
 	if bp, ok := tmp["base_path"].(string); ok {
 		conf.BasePath = bp
 	}
@@ -87,7 +117,102 @@ func configGetter(cfg config.ExtraConfig) *xtraConfig {
 		conf.PayloadMap = tmp
 	}
 
-	conf.Service = service.NewHTTPOPA(conf.ServiceAddress, conf.BasePath, conf.CacheDuration, conf.CacheSize)
+	conf.Cache = cache.ParseConfig(tmp)
+	conf.Cache.Context = ctx
+	conf.Cache.Duration = time.Duration(conf.CacheDuration) * time.Second
+	conf.Cache.Size = conf.CacheSize
+	if conf.Cache.Remote.Prefix == "" {
+		conf.Cache.Remote.Prefix = "opa:" + endpoint + ":"
+	}
+
+	if fo, ok := tmp["fail_open"].(bool); ok {
+		conf.FailOpen = fo
+	}
+
+	if tr, ok := tmp["transport"].(string); ok {
+		conf.Transport = tr
+	}
+
+	if api, ok := tmp["ext_authz_api"].(string); ok {
+		conf.ExtAuthzAPI = api
+	}
+
+	store, err := cache.New(conf.Cache)
+	if err != nil {
+		return nil
+	}
+	conf.CacheStore = store
+	conf.Breaker = service.ParseBreakerConfig(tmp)
+	conf.Client = service.ParseClientConfig(tmp)
+	svc, err := conf.newService(ctx)
+	if err != nil {
+		svc = &service.DummyOPA{Error: err}
+	}
+	conf.Service = svc
 
 	return &conf
 }
+
+func (x *xtraConfig) newService(ctx context.Context) (service.Policy, error) {
+	if x.Transport == service.TransportGRPC {
+		return service.NewGRPCOPA(service.GRPCConfig{
+			Context:     ctx,
+			Address:     x.ServiceAddress,
+			Cache:       x.CacheStore,
+			Client:      x.Client,
+			Breaker:     x.Breaker,
+			ExtAuthzAPI: x.ExtAuthzAPI,
+		})
+	}
+
+	client, err := service.NewClient(x.Client)
+	if err != nil {
+		return nil, err
+	}
+
+	return service.NewHTTPOPA(service.HTTPConfig{
+		Address:  x.ServiceAddress,
+		BasePath: x.BasePath,
+		Cache:    x.CacheStore,
+		Client:   client,
+		Breaker:  x.Breaker,
+		TTLPath:  x.CacheTTLPath,
+	}), nil
+}
+
+//Linter strict validation of the config block. The endpoint rejects every request when service_address
+//or package_name are missing, or when the cache_mode requires a missing cache_address
+var Linter = lint.Linter{
+	Namespace: namespace,
+	Scope:     lint.ScopeEndpoint,
+	Security:  true,
+	Fields: append([]lint.Field{
+		{Name: "service_address", Kind: lint.KindString, Required: true, Check: lint.NotEmpty},
+		{Name: "package_name", Kind: lint.KindString, Required: true, Check: lint.NotEmpty},
+		{Name: "directive", Kind: lint.KindString, Check: lint.NotEmpty},
+		{Name: "base_path", Kind: lint.KindString},
+		{Name: "payload", Kind: lint.KindObject, Check: lint.Values(lint.Func(payloadValue))},
+		{Name: "cache_duration", Kind: lint.KindInt, Check: lint.Min(0)},
+		{Name: "cache_size", Kind: lint.KindInt, Check: lint.Min(0)},
+		{Name: "cache_ttl_path", Kind: lint.KindString},
+		{Name: "cache_key_fields", Kind: lint.KindArray},
+		{Name: "fail_open", Kind: lint.KindBool},
+		{Name: "transport", Kind: lint.KindString, Check: lint.OneOf(service.TransportHTTP, service.TransportGRPC)},
+		{Name: "ext_authz_api", Kind: lint.KindString, Check: lint.OneOf(service.ExtAuthzV2, service.ExtAuthzV3)},
+		service.ClientField,
+		service.BreakerField,
+	}, cache.Fields...),
+	Check: cache.Check,
+}
+
+//payloadValue payload values are constants, or selectors when they contain a dot
+func payloadValue(v interface{}) error {
+	s, ok := v.(string)
+	if !ok {
+		return errors.New("must be a string, other values are ignored")
+	}
+	if !strings.Contains(s, ".") {
+		return nil
+	}
+	return lint.Selector.Validate(s)
+}
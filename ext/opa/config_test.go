@@ -1,31 +1,33 @@
 package opa
 
 import (
+	"context"
 	"testing"
 
+	"github.com/devopsfaith/krakend-ce/ext/lint"
 	"github.com/devopsfaith/krakend/config"
 	"github.com/stretchr/testify/assert"
 )
 
 func TestConfigInvalidParse(t *testing.T) {
 
-	assert.Nil(t, configGetter(config.ExtraConfig{
+	assert.Nil(t, configGetter(context.Background(), "/test", config.ExtraConfig{
 		"opa": map[string]interface{}{
 			"service_address": "http://localhost:8080",
 		},
 	}), "Should nil")
 
-	assert.Nil(t, configGetter(config.ExtraConfig{
+	assert.Nil(t, configGetter(context.Background(), "/test", config.ExtraConfig{
 		namespace: "opa",
 	}), "Should nil")
 
-	assert.Nil(t, configGetter(config.ExtraConfig{
+	assert.Nil(t, configGetter(context.Background(), "/test", config.ExtraConfig{
 		namespace: map[string]interface{}{
 			"service_address": "http://localhost:8080",
 		},
 	}), "Should nil")
 
-	assert.Nil(t, configGetter(config.ExtraConfig{
+	assert.Nil(t, configGetter(context.Background(), "/test", config.ExtraConfig{
 		namespace: map[string]interface{}{
 			"package_name": "opa.test",
 		},
@@ -40,7 +42,7 @@ func TestConfigParse(t *testing.T) {
 		},
 	}
 
-	cfg := configGetter(xtra)
+	cfg := configGetter(context.Background(), "/test", xtra)
 
 	assert.NotNil(t, cfg, "Should not nil")
 	assert.NotNil(t, cfg.Service)
@@ -60,7 +62,7 @@ func TestConfigCustomParse(t *testing.T) {
 		},
 	}
 
-	cfg := configGetter(xtra)
+	cfg := configGetter(context.Background(), "/test", xtra)
 
 	assert.NotNil(t, cfg, "Should not nil")
 	assert.Equal(t, cfg.BasePath, "/v2/data", "Should not default")
@@ -82,9 +84,80 @@ func TestConfigPayloadParse(t *testing.T) {
 		},
 	}
 
-	cfg := configGetter(xtra)
+	cfg := configGetter(context.Background(), "/test", xtra)
 	assert.NotNil(t, cfg, "Should not nil")
 
 	assert.Equal(t, cfg.PayloadMap["data"], "body.data", "Should be body.data")
 	assert.Equal(t, cfg.PayloadMap["version"], "", "Should be nil")
 }
+
+func TestConfigCacheParse(t *testing.T) {
+	xtra := config.ExtraConfig{
+		namespace: map[string]interface{}{
+			"service_address": "http://localhost:8080",
+			"package_name":    "opa.test",
+			"cache_size":      100,
+			"cache_mode":      "tiered",
+			"cache_address":   "localhost:6379",
+		},
+	}
+
+	cfg := configGetter(context.Background(), "/test", xtra)
+	assert.NotNil(t, cfg, "Should not nil")
+	assert.Equal(t, "tiered", cfg.Cache.Mode)
+	assert.Equal(t, "localhost:6379", cfg.Cache.Remote.Address)
+	assert.Equal(t, "opa:/test:", cfg.Cache.Remote.Prefix, "Should be kept per endpoint")
+	assert.Equal(t, 100, cfg.Cache.Size)
+}
+
+func TestConfigCacheKeyFieldsParse(t *testing.T) {
+	cfg := configGetter(context.Background(), "/test", config.ExtraConfig{
+		namespace: map[string]interface{}{
+			"service_address":  "http://localhost:8080",
+			"package_name":     "opa.test",
+			"cache_key_fields": []interface{}{"input.method", "input.payload.sub", 1},
+		},
+	})
+
+	assert.NotNil(t, cfg, "Should not nil")
+	assert.Equal(t, []string{"input.method", "input.payload.sub"}, cfg.CacheKeyFields)
+}
+
+func TestLinter(t *testing.T) {
+	cfg := config.ServiceConfig{
+		Endpoints: []*config.EndpointConfig{
+			{
+				Endpoint: "/foo",
+				ExtraConfig: config.ExtraConfig{
+					namespace: map[string]interface{}{
+						"service_address": "http://localhost:8181",
+						"payload":         map[string]interface{}{"role": "admin", "user": "query.user"},
+						"cache_mode":      "tiered",
+					},
+				},
+			},
+		},
+	}
+
+	errs := lint.Lint(cfg, Linter)
+	assert.Len(t, errs, 1)
+	assert.Equal(t, "package_name", errs[0].Field)
+	assert.Equal(t, "required", errs[0].Msg)
+	assert.True(t, errs[0].Security)
+
+	cfg.Endpoints[0].ExtraConfig[namespace].(map[string]interface{})["package_name"] = "opa.test"
+	errs = lint.Lint(cfg, Linter)
+	assert.Len(t, errs, 1)
+	assert.Equal(t, "cache_address", errs[0].Field)
+	assert.Equal(t, "required by the tiered cache_mode", errs[0].Msg)
+	assert.True(t, errs[0].Security)
+
+	cfg.Endpoints[0].ExtraConfig[namespace].(map[string]interface{})["cache_address"] = "localhost:6379"
+	assert.Empty(t, lint.Lint(cfg, Linter))
+
+	cfg.Endpoints[0].ExtraConfig[namespace].(map[string]interface{})["payload"] = map[string]interface{}{"user": "query"}
+	cfg.Endpoints[0].ExtraConfig[namespace].(map[string]interface{})["ext_authz_api"] = "v1"
+	errs = lint.Lint(cfg, Linter)
+	assert.Len(t, errs, 1)
+	assert.Equal(t, "ext_authz_api", errs[0].Field)
+}
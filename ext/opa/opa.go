@@ -1,35 +1,38 @@
 package opa
 
 import (
-	"bytes"
-	"encoding/base64"
-	"encoding/json"
+	"context"
 	"errors"
-	"fmt"
-
-	"io/ioutil"
 	"net/http"
 	"strings"
 
-	"crypto/sha256"
-
+	cache "github.com/devopsfaith/krakend-ce/ext/cache"
+	"github.com/devopsfaith/krakend-ce/ext/health"
+	"github.com/devopsfaith/krakend-ce/ext/reqctx"
+	"github.com/devopsfaith/krakend-ce/ext/selector"
+	"github.com/devopsfaith/krakend-ce/ext/service"
 	"github.com/devopsfaith/krakend/config"
 	"github.com/devopsfaith/krakend/logging"
 	"github.com/devopsfaith/krakend/proxy"
 	krakendgin "github.com/devopsfaith/krakend/router/gin"
 	"github.com/gin-gonic/gin"
-	"github.com/tidwall/gjson"
 )
 
 //Request OPA request model
 type Request struct {
 	Input Input `json:"input,omitempty" mapstructure:"input"`
+	//KeyFields dotted paths of the request fields taking part in the cache key, all when empty
+	KeyFields []string `json:"-" mapstructure:"-"`
 }
 
 //Hash calculate request hash
 func (r *Request) Hash() [32]byte {
-	val := fmt.Sprintf("%v", r)
-	return sha256.Sum256([]byte(val))
+	return service.Hash(r, r.KeyFields...)
+}
+
+//HTTPAttributes request method and path sent to the ext_authz servers
+func (r *Request) HTTPAttributes() (string, string) {
+	return r.Input.Method, "/" + strings.Join(r.Input.Path, "/")
 }
 
 //Response OPA response model
@@ -44,15 +47,27 @@ type Input struct {
 	Payload map[string]interface{} `json:"payload,omitempty" mapstructure:"payload"`
 }
 
-//HandlerFactory Open Policy Agent handler factory
+//HandlerFactory Open Policy Agent handler factory. The caches and health checks are kept for the process lifetime
+//and start no background task, use HandlerFactoryWithContext to release them with the router
 func HandlerFactory(l logging.Logger, next krakendgin.HandlerFactory) krakendgin.HandlerFactory {
+	return HandlerFactoryWithContext(context.Background(), l, next)
+}
+
+//HandlerFactoryWithContext Open Policy Agent handler factory, the caches live until the context is cancelled
+func HandlerFactoryWithContext(ctx context.Context, l logging.Logger, next krakendgin.HandlerFactory) krakendgin.HandlerFactory {
 	//l.Debug("Enabling OPA handler ")
 	return func(remote *config.EndpointConfig, p proxy.Proxy) gin.HandlerFunc {
 		handlerFunc := next(remote, p)
 
-		conf := configGetter(remote.ExtraConfig)
+		conf := configGetter(ctx, remote.Endpoint, remote.ExtraConfig)
 
 		if conf == nil {
+			if _, ok := remote.ExtraConfig[namespace]; ok {
+				l.Error("[OPA] Invalid config for endpoint ", remote.Endpoint, ", rejecting every request")
+				return func(c *gin.Context) {
+					c.AbortWithStatus(http.StatusInternalServerError)
+				}
+			}
 			//l.Debug("[OPA] No config for policy agent ")
 			return func(c *gin.Context) {
 				handlerFunc(c)
@@ -60,10 +75,26 @@ func HandlerFactory(l logging.Logger, next krakendgin.HandlerFactory) krakendgin
 		}
 
 		l.Debug("[OPA] OPA is enabled for endpoint ", remote.Endpoint)
+		cache.Register(ctx, "opa", remote.Endpoint, conf.CacheStore, conf.PackageName, conf.PackageName+"/"+conf.Directive)
+		if svc, ok := conf.Service.(service.Pinger); ok {
+			//the endpoint keeps serving while the service is unavailable when failing open
+			register := health.RegisterCheck
+			if conf.FailOpen {
+				register = health.RegisterOptionalCheck
+			}
+			register(ctx, "opa:"+remote.Endpoint, svc.Ping)
+		}
 
 		return func(c *gin.Context) {
+			reqctx.FromGin(c)
 
 			res, err := conf.checkPermission(c.Request)
+			if err != nil && conf.FailOpen && service.IsUnavailable(err) {
+				l.Warning("[OPA] Policy service unavailable, failing open ", err)
+				handlerFunc(c)
+				return
+			}
+
 			if err != nil {
 				l.Error("[OPA] Error checking permission ", err)
 				c.AbortWithError(http.StatusInternalServerError, err)
@@ -90,120 +121,18 @@ func (x *xtraConfig) buildRequest(r *http.Request) *Request {
 			Method: r.Method,
 			Path:   strings.Split(strings.Trim(r.URL.Path, "/"), "/"),
 		},
+		KeyFields: x.CacheKeyFields,
 	}
 
 	if x.PayloadMap != nil {
-		token := ""
-		tHeaders := ""
-		tPayload := ""
-
-		bodyRead := false
-		tokenRead := false
-		var raw []byte
-
 		data := make(map[string]interface{})
 		for k, v := range x.PayloadMap {
 			if !strings.Contains(v, ".") {
 				data[k] = v
 				continue
 			}
-			parts := strings.Split(v, ".")
-
-			switch strings.ToLower(parts[0]) {
-			case "jwt":
-				if !tokenRead {
-					tokenRead = true
-					if r.Header == nil {
-						continue
-					}
-					if auth, ok := r.Header[authHeader]; ok {
-						token = auth[0]
-					}
-
-					if token != "" {
-						token = strings.TrimPrefix(token, "Bearer ")
-						//Just in case using lower case bearer
-						token = strings.TrimPrefix(token, "bearer ")
-					}
-
-					tparts := strings.Split(token, ".")
-					if len(tparts) != 3 {
-						token = ""
-						continue
-					} else {
-						if b, err := base64.RawStdEncoding.DecodeString(tparts[0]); err == nil {
-							tHeaders = string(b)
-						}
-
-						if b, err := base64.RawStdEncoding.DecodeString(tparts[1]); err == nil {
-							tPayload = string(b)
-						}
-					}
-				}
-				if tHeaders == "" || tPayload == "" {
-					continue
-				}
-				if parts[1] == "raw" {
-					data[k] = token
-					continue
-				}
-				if val := getJWTValue(tHeaders, tPayload, parts[1:]); val != "" {
-					data[k] = val
-				}
-			case "body":
-				if !bodyRead {
-					bodyRead = true
-					if r.Body == nil {
-						continue
-					}
-					raw, _ = ioutil.ReadAll(r.Body)
-					if raw == nil {
-						continue
-					}
-					r.Body = ioutil.NopCloser(bytes.NewReader(raw))
-				}
-				if raw == nil {
-					continue
-				}
-				if parts[1] == "raw" {
-					var d map[string]interface{}
-					if err := json.Unmarshal(raw, &d); err == nil {
-						data[k] = d
-					}
-					continue
-				}
-
-				if val := getBodyValue(string(raw), parts[1:]); val != "" {
-					data[k] = val
-				}
-
-			case "header":
-				if r.Header == nil {
-					continue
-				}
-				if parts[1] == "raw" {
-					data[k] = r.Header
-					continue
-				}
-
-				if val := r.Header.Get(parts[1]); val != "" {
-					data[k] = val
-				}
-			case "query":
-				if r.URL.Query() == nil {
-					continue
-				}
-				if parts[1] == "raw" {
-					data[k] = r.URL.Query()
-					continue
-				}
-
-				if val := r.URL.Query().Get(parts[1]); val != "" {
-					data[k] = val
-				}
-
-			default:
-				continue
+			if val, err := selector.Get(r, v); err == nil {
+				data[k] = val
 			}
 		}
 
@@ -220,34 +149,13 @@ func (x *xtraConfig) checkPermission(r *http.Request) (bool, error) {
 		return false, errors.New("Fail to build input request")
 	}
 
-	return x.Service.Evaluate(x.PackageName, x.Directive, req)
-
-}
-
-func getBodyValue(body string, path []string) interface{} {
-	if val := gjson.Get(body, strings.Join(path, ".")); val.Exists() {
-		return val.Value()
+	ctx := service.WithRequestID(r.Context(), r.Header.Get(x.Client.RequestIDHeader))
+	res, err := x.Service.Evaluate(ctx, x.PackageName, x.Directive, req)
+	if err != nil {
+		return false, err
 	}
-	return nil
-}
 
-func getJWTValue(header string, payload string, path []string) interface{} {
-	var data string
-	switch strings.ToLower(path[0]) {
-	case "header":
-		data = header
-	case "payload":
-		data = payload
-	default:
-		return nil
-	}
+	reqctx.FromRequest(r).Set(reqctx.OPADecision, res)
 
-	if len(path) == 1 {
-		var d map[string]interface{}
-		return json.Unmarshal([]byte(data), &d)
-	}
-	if val := gjson.Get(data, strings.Join(path[1:], ".")); val.Exists() {
-		return val.Value()
-	}
-	return nil
+	return res, nil
 }
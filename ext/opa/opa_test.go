@@ -1,12 +1,25 @@
 package opa
 
 import (
+	"bufio"
 	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
 	"io/ioutil"
+	"net"
 	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
 	"testing"
 
 	"github.com/devopsfaith/krakend-ce/ext/service"
+	"github.com/devopsfaith/krakend/config"
+	"github.com/devopsfaith/krakend/logging"
+	"github.com/devopsfaith/krakend/proxy"
+	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -132,3 +145,161 @@ func TestJWTPayload(t *testing.T) {
 	assert.Equal(t, "GX6vvdg12DwnnWMnDdc5tMeu2QGy9c9LNfPi19t7bwVm", oreq.Input.Payload["subject"])
 	assert.Equal(t, []string{"default"}, oreq.Input.Payload["groups"])
 }
+
+func TestRequestHash(t *testing.T) {
+	cfg := &xtraConfig{
+		ServiceAddress: "http://localhost:8080",
+		PackageName:    "opa.test",
+		PayloadMap: map[string]string{
+			"headers": "header.raw",
+			"city":    "query.city",
+			"user":    "header.X-User",
+		},
+	}
+
+	newRequest := func(city, user, trace string) *http.Request {
+		req, _ := http.NewRequest("GET", "http://localhost:8000/echo/alpha?city="+city, nil)
+		req.Header.Set("X-User", user)
+		req.Header.Set("X-Trace", trace)
+		req.Header.Set("Accept", "application/json")
+		return req
+	}
+
+	first := cfg.buildRequest(newRequest("Jakarta", "user1", "1")).Hash()
+	for i := 0; i < 50; i++ {
+		assert.Equal(t, first, cfg.buildRequest(newRequest("Jakarta", "user1", "1")).Hash(), "Hash should be stable")
+	}
+	assert.NotEqual(t, first, cfg.buildRequest(newRequest("Jakarta", "user1", "2")).Hash())
+	assert.NotEqual(t, first, cfg.buildRequest(newRequest("Bandung", "user1", "1")).Hash())
+
+	cfg.CacheKeyFields = []string{"input.method", "input.path", "input.payload.user"}
+	keyed := cfg.buildRequest(newRequest("Jakarta", "user1", "1")).Hash()
+	assert.Equal(t, keyed, cfg.buildRequest(newRequest("Bandung", "user1", "2")).Hash(), "Only the key fields should count")
+	assert.NotEqual(t, keyed, cfg.buildRequest(newRequest("Jakarta", "user2", "1")).Hash())
+}
+
+func TestHandlerInvalidConfig(t *testing.T) {
+	logger, _ := logging.NewLogger("CRITICAL", ioutil.Discard, "")
+	next := func(_ *config.EndpointConfig, _ proxy.Proxy) gin.HandlerFunc {
+		return func(c *gin.Context) {
+			t.Error("The request should not reach the next handler")
+		}
+	}
+
+	handler := HandlerFactory(logger, next)(&config.EndpointConfig{
+		Endpoint: "/invalid",
+		ExtraConfig: config.ExtraConfig{
+			namespace: map[string]interface{}{"service_address": "http://127.0.0.1:1"},
+		},
+	}, nil)
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request, _ = http.NewRequest("GET", "http://localhost:8000/invalid", nil)
+	handler(c)
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+}
+
+//fakeRedis serves GET and SET from memory, the rest of the commands are acknowledged
+type fakeRedis struct {
+	mu    sync.Mutex
+	items map[string]string
+}
+
+func (f *fakeRedis) serve(conn net.Conn) {
+	defer conn.Close()
+	rd := bufio.NewReader(conn)
+	for {
+		var n int
+		if _, err := fmt.Fscanf(rd, "*%d\r\n", &n); err != nil {
+			return
+		}
+		args := make([]string, n)
+		for i := range args {
+			var size int
+			if _, err := fmt.Fscanf(rd, "$%d\r\n", &size); err != nil {
+				return
+			}
+			buf := make([]byte, size+2)
+			if _, err := io.ReadFull(rd, buf); err != nil {
+				return
+			}
+			args[i] = string(buf[:size])
+		}
+
+		f.mu.Lock()
+		res := ":-1\r\n"
+		switch strings.ToUpper(args[0]) {
+		case "GET":
+			res = "$-1\r\n"
+			if v, ok := f.items[args[1]]; ok {
+				res = fmt.Sprintf("$%d\r\n%s\r\n", len(v), v)
+			}
+		case "SET":
+			f.items[args[1]] = args[2]
+			res = "+OK\r\n"
+		}
+		f.mu.Unlock()
+		conn.Write([]byte(res))
+	}
+}
+
+func TestHandlerSharedRemoteCache(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	redis := &fakeRedis{items: map[string]string{}}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go redis.serve(conn)
+		}
+	}()
+
+	// the policy only allows the requests to /a
+	policy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req Request
+		json.NewDecoder(r.Body).Decode(&req)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{"result": len(req.Input.Path) > 0 && req.Input.Path[0] == "a"})
+	}))
+	defer policy.Close()
+
+	logger, _ := logging.NewLogger("CRITICAL", ioutil.Discard, "")
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	next := func(_ *config.EndpointConfig, _ proxy.Proxy) gin.HandlerFunc {
+		return func(c *gin.Context) { c.Status(http.StatusOK) }
+	}
+	handler := func(endpoint string) gin.HandlerFunc {
+		return HandlerFactoryWithContext(ctx, logger, next)(&config.EndpointConfig{
+			Endpoint: endpoint,
+			ExtraConfig: config.ExtraConfig{
+				namespace: map[string]interface{}{
+					"service_address":  policy.URL,
+					"package_name":     "opa.test",
+					"payload":          map[string]interface{}{"user": "header.X-User"},
+					"cache_key_fields": []interface{}{"input.payload.user"},
+					"cache_mode":       "remote",
+					"cache_address":    ln.Addr().String(),
+				},
+			},
+		}, nil)
+	}
+	serve := func(h gin.HandlerFunc, path string) int {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request, _ = http.NewRequest("GET", "http://localhost:8000"+path, nil)
+		c.Request.Header.Set("X-User", "user1")
+		h(c)
+		return w.Code
+	}
+
+	assert.Equal(t, http.StatusOK, serve(handler("/a"), "/a"))
+	assert.Equal(t, http.StatusUnauthorized, serve(handler("/b"), "/b"), "The decision cached for another endpoint should not be reused")
+}
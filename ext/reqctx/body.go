@@ -0,0 +1,91 @@
+package reqctx
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+)
+
+const bodyKey = "request.body"
+
+//ErrBodyTooLarge the request body exceeds the configured limit
+var ErrBodyTooLarge = errors.New("Request body too large")
+
+//body shared copy of the request body and the reader installed on the request
+type body struct {
+	raw    []byte
+	reader io.ReadCloser
+}
+
+//Body get the request body. The body is read once per request and shared through the bag,
+//leaving a fresh reader on the request so it stays readable for the next handlers
+func Body(r *http.Request) ([]byte, error) {
+	return ReadBody(r, 0)
+}
+
+//ReadBody get the request body as Body does, failing with ErrBodyTooLarge when it is
+//longer than limit bytes. No limit is applied when limit is 0 or lower
+func ReadBody(r *http.Request, limit int64) ([]byte, error) {
+	if r == nil || r.Body == nil || r.Body == http.NoBody {
+		return nil, nil
+	}
+
+	b := FromRequest(r)
+	if v, ok := b.Get(bodyKey); ok {
+		//the cached copy is stale when some handler replaced the body
+		if cached, ok := v.(*body); ok && cached.reader == r.Body {
+			if limit > 0 && int64(len(cached.raw)) > limit {
+				return nil, ErrBodyTooLarge
+			}
+			rewind(r, cached)
+			return cached.raw, nil
+		}
+	}
+
+	if limit > 0 && r.ContentLength > limit {
+		return nil, ErrBodyTooLarge
+	}
+
+	src := io.Reader(r.Body)
+	if limit > 0 {
+		src = io.LimitReader(r.Body, limit+1)
+	}
+	raw, err := ioutil.ReadAll(src)
+	if err != nil {
+		return nil, err
+	}
+	if limit > 0 && int64(len(raw)) > limit {
+		return nil, ErrBodyTooLarge
+	}
+
+	cached := &body{raw: raw}
+	rewind(r, cached)
+	b.Set(bodyKey, cached)
+
+	return raw, nil
+}
+
+//SetBody replace the request body, updating the shared copy and the content length
+func SetBody(r *http.Request, raw []byte) {
+	if r == nil {
+		return
+	}
+
+	cached := &body{raw: raw}
+	rewind(r, cached)
+	FromRequest(r).Set(bodyKey, cached)
+
+	r.ContentLength = int64(len(raw))
+	if r.Header == nil {
+		r.Header = http.Header{}
+	}
+	r.Header.Set("Content-Length", strconv.Itoa(len(raw)))
+}
+
+func rewind(r *http.Request, b *body) {
+	b.reader = ioutil.NopCloser(bytes.NewReader(b.raw))
+	r.Body = b.reader
+}
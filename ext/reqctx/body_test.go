@@ -0,0 +1,90 @@
+package reqctx
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+type countingReader struct {
+	r     *bytes.Reader
+	reads int
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	c.reads++
+	return c.r.Read(p)
+}
+
+func newBodyRequest(body string) (*http.Request, *countingReader) {
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	src := &countingReader{r: bytes.NewReader([]byte(body))}
+	c.Request, _ = http.NewRequest("POST", "http://localhost:8000/echo", ioutil.NopCloser(src))
+	FromGin(c)
+	return c.Request, src
+}
+
+func TestBodyShared(t *testing.T) {
+	r, src := newBodyRequest(`{"name":"janet"}`)
+
+	raw, err := Body(r)
+	assert.Nil(t, err)
+	assert.Equal(t, `{"name":"janet"}`, string(raw))
+	reads := src.reads
+
+	raw, err = Body(r)
+	assert.Nil(t, err)
+	assert.Equal(t, `{"name":"janet"}`, string(raw))
+	assert.Equal(t, reads, src.reads, "Body should be read once")
+
+	next, _ := ioutil.ReadAll(r.Body)
+	assert.Equal(t, `{"name":"janet"}`, string(next), "Body should stay readable")
+
+	raw, _ = Body(r)
+	assert.Equal(t, `{"name":"janet"}`, string(raw), "Body should be rewound after being consumed")
+}
+
+func TestSetBody(t *testing.T) {
+	r, _ := newBodyRequest(`{"name":"janet"}`)
+
+	SetBody(r, []byte(`{"name":"john"}`))
+	assert.Equal(t, int64(15), r.ContentLength)
+	assert.Equal(t, "15", r.Header.Get("Content-Length"))
+
+	raw, _ := Body(r)
+	assert.Equal(t, `{"name":"john"}`, string(raw))
+
+	r.Body = ioutil.NopCloser(bytes.NewReader([]byte("replaced")))
+	raw, _ = Body(r)
+	assert.Equal(t, "replaced", string(raw), "Bodies replaced by other handlers should be read again")
+}
+
+func TestReadBodyLimit(t *testing.T) {
+	r, _ := newBodyRequest("0123456789")
+	r.ContentLength = -1
+
+	_, err := ReadBody(r, 5)
+	assert.Equal(t, ErrBodyTooLarge, err)
+
+	r, _ = newBodyRequest("0123456789")
+	_, err = ReadBody(r, 5)
+	assert.Equal(t, ErrBodyTooLarge, err, "Content-Length should be checked before reading")
+
+	r, _ = newBodyRequest("0123456789")
+	raw, err := ReadBody(r, 10)
+	assert.Nil(t, err)
+	assert.Equal(t, "0123456789", string(raw))
+
+	_, err = ReadBody(r, 5)
+	assert.Equal(t, ErrBodyTooLarge, err, "The limit should also apply to the shared copy")
+
+	empty, _ := http.NewRequest("GET", "http://localhost:8000/echo", nil)
+	raw, err = ReadBody(empty, 5)
+	assert.Nil(t, err)
+	assert.Equal(t, 0, len(raw))
+}
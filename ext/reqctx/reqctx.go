@@ -0,0 +1,216 @@
+package reqctx
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	//Prefix path prefix used by ext modules to read values from the bag
+	Prefix = "ctx"
+
+	//KeyAuthResult key used by keyauth to store the lookup result
+	KeyAuthResult = "keyauth.result"
+	//JWTUnverifiedHeader key holding the JWT header decoded from the bearer token. The signature is
+	//not verified, so the value must not be trusted unless a previous middleware validated the token
+	JWTUnverifiedHeader = "jwt.unverified_header"
+	//JWTUnverifiedClaims key holding the JWT claims decoded from the bearer token, see JWTUnverifiedHeader
+	JWTUnverifiedClaims = "jwt.unverified_claims"
+	//OPADecision key used by opa to store the policy decision
+	OPADecision = "opa.decision"
+	//ExtAuthzStatus key used by extauthz to store the authorization service response status
+	ExtAuthzStatus = "extauthz.status"
+	//ExtAuthzHeaders key used by extauthz to store the headers added to the request
+	ExtAuthzHeaders = "extauthz.headers"
+	//Params key holding the endpoint URL parameters
+	Params = "params"
+
+	ginKey     = "github_com/sahalzain/krakend-ctx"
+	jwtPrefix  = "jwt."
+	jwtToken   = "jwt.token"
+	authHeader = "Authorization"
+)
+
+type contextKey struct{}
+
+//Bag per request context store shared by ext modules
+type Bag map[string]interface{}
+
+//Token decoded JWT token
+type Token struct {
+	Raw     string
+	Header  string
+	Payload string
+}
+
+//FromGin get the bag attached to the gin context, creating it when missing
+func FromGin(c *gin.Context) Bag {
+	if v, ok := c.Get(ginKey); ok {
+		if b, ok := v.(Bag); ok {
+			return b
+		}
+	}
+
+	b := Bag{}
+	if len(c.Params) > 0 {
+		params := make(map[string]string, len(c.Params))
+		for _, p := range c.Params {
+			params[p.Key] = p.Value
+		}
+		b[Params] = params
+	}
+	c.Set(ginKey, b)
+	if c.Request != nil {
+		c.Request = c.Request.WithContext(context.WithValue(c.Request.Context(), contextKey{}, b))
+	}
+	return b
+}
+
+//FromRequest get the bag attached to the request, nil if not present
+func FromRequest(r *http.Request) Bag {
+	if r == nil {
+		return nil
+	}
+	b, _ := r.Context().Value(contextKey{}).(Bag)
+	return b
+}
+
+//Set store value on dotted key, nested maps are created as needed
+func (b Bag) Set(key string, val interface{}) {
+	if b == nil {
+		return
+	}
+	parts := strings.Split(key, ".")
+	m := map[string]interface{}(b)
+	for _, p := range parts[:len(parts)-1] {
+		next, ok := m[p].(map[string]interface{})
+		if !ok {
+			next = make(map[string]interface{})
+			m[p] = next
+		}
+		m = next
+	}
+	m[parts[len(parts)-1]] = val
+}
+
+//Delete remove the value on dotted key
+func (b Bag) Delete(key string) {
+	if b == nil {
+		return
+	}
+	parts := strings.Split(key, ".")
+	m := map[string]interface{}(b)
+	for _, p := range parts[:len(parts)-1] {
+		next, ok := m[p].(map[string]interface{})
+		if !ok {
+			return
+		}
+		m = next
+	}
+	delete(m, parts[len(parts)-1])
+}
+
+//Get get value by dotted key
+func (b Bag) Get(key string) (interface{}, bool) {
+	if b == nil {
+		return nil, false
+	}
+	var cur interface{} = map[string]interface{}(b)
+	for _, p := range strings.Split(key, ".") {
+		switch m := cur.(type) {
+		case map[string]interface{}:
+			v, ok := m[p]
+			if !ok {
+				return nil, false
+			}
+			cur = v
+		case map[string]string:
+			v, ok := m[p]
+			if !ok {
+				return nil, false
+			}
+			cur = v
+		default:
+			return nil, false
+		}
+	}
+	return cur, true
+}
+
+//Value get the value by dotted key from the request bag. The jwt keys are decoded from the bearer
+//token on their first lookup
+func Value(r *http.Request, key string) (interface{}, bool) {
+	if strings.HasPrefix(strings.ToLower(key), jwtPrefix) {
+		ParseToken(r)
+	}
+	return FromRequest(r).Get(key)
+}
+
+//Lookup resolve a ctx path, e.g. ctx.jwt.unverified_claims.sub, from the request bag
+func Lookup(r *http.Request, path string) (interface{}, bool) {
+	parts := strings.SplitN(path, ".", 2)
+	if len(parts) < 2 || strings.ToLower(parts[0]) != Prefix {
+		return nil, false
+	}
+	return Value(r, parts[1])
+}
+
+//ParseToken decode the bearer token from the Authorization header.
+//The token is decoded once per request and shared through the bag
+func ParseToken(r *http.Request) (*Token, error) {
+	b := FromRequest(r)
+	if v, ok := b.Get(jwtToken); ok {
+		if t, ok := v.(*Token); ok {
+			return t, nil
+		}
+	}
+
+	if r == nil || r.Header == nil {
+		return nil, errors.New("Empty header")
+	}
+
+	token := r.Header.Get(authHeader)
+	if token == "" {
+		return nil, errors.New("Token is empty")
+	}
+
+	token = strings.TrimPrefix(token, "Bearer ")
+	//Just in case using lower case bearer
+	token = strings.TrimPrefix(token, "bearer ")
+
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("Token is malformed")
+	}
+
+	t := &Token{Raw: token}
+	if d, err := base64.RawStdEncoding.DecodeString(parts[0]); err == nil {
+		t.Header = string(d)
+	}
+	if d, err := base64.RawStdEncoding.DecodeString(parts[1]); err == nil {
+		t.Payload = string(d)
+	}
+
+	if t.Header == "" || t.Payload == "" {
+		return nil, errors.New("Token content is not valid")
+	}
+
+	if b != nil {
+		b.Set(jwtToken, t)
+		var h, c map[string]interface{}
+		if err := json.Unmarshal([]byte(t.Header), &h); err == nil {
+			b.Set(JWTUnverifiedHeader, h)
+		}
+		if err := json.Unmarshal([]byte(t.Payload), &c); err == nil {
+			b.Set(JWTUnverifiedClaims, c)
+		}
+	}
+
+	return t, nil
+}
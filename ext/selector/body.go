@@ -0,0 +1,109 @@
+package selector
+
+import (
+	"encoding/json"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/devopsfaith/krakend-ce/ext/reqctx"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+const formContentType = "application/x-www-form-urlencoded"
+
+func (s Selector) getBody(r *http.Request) (interface{}, error) {
+	raw, err := reqctx.Body(r)
+	if err != nil {
+		return nil, s.fail(err)
+	}
+	if len(raw) == 0 {
+		return nil, s.fail(ErrNotFound)
+	}
+
+	if isForm(r) {
+		form, err := url.ParseQuery(string(raw))
+		if err != nil {
+			return nil, s.fail(err)
+		}
+		if s.isRaw() {
+			return form, nil
+		}
+		return s.found(form.Get(strings.Join(s.Path, ".")))
+	}
+
+	if s.isRaw() {
+		var d map[string]interface{}
+		if err := json.Unmarshal(raw, &d); err != nil {
+			return nil, s.fail(err)
+		}
+		return d, nil
+	}
+
+	val := gjson.Get(string(raw), strings.Join(s.Path, "."))
+	if !val.Exists() {
+		return nil, s.fail(ErrNotFound)
+	}
+	return val.Value(), nil
+}
+
+func (s Selector) setBody(r *http.Request, val interface{}) error {
+	raw, err := reqctx.Body(r)
+	if err != nil {
+		return s.fail(err)
+	}
+
+	if isForm(r) {
+		form, err := url.ParseQuery(string(raw))
+		if err != nil {
+			return s.fail(err)
+		}
+		form.Set(strings.Join(s.Path, "."), Format(val))
+		reqctx.SetBody(r, []byte(form.Encode()))
+		return nil
+	}
+
+	res, err := sjson.Set(string(raw), strings.Join(s.Path, "."), val)
+	if err != nil {
+		return s.fail(err)
+	}
+	reqctx.SetBody(r, []byte(res))
+	return nil
+}
+
+func (s Selector) deleteBody(r *http.Request) error {
+	raw, err := reqctx.Body(r)
+	if err != nil {
+		return s.fail(err)
+	}
+	if len(raw) == 0 {
+		return nil
+	}
+
+	if isForm(r) {
+		form, err := url.ParseQuery(string(raw))
+		if err != nil {
+			return s.fail(err)
+		}
+		form.Del(strings.Join(s.Path, "."))
+		reqctx.SetBody(r, []byte(form.Encode()))
+		return nil
+	}
+
+	if !gjson.Get(string(raw), strings.Join(s.Path, ".")).Exists() {
+		return nil
+	}
+	res, err := sjson.Delete(string(raw), strings.Join(s.Path, "."))
+	if err != nil {
+		return s.fail(err)
+	}
+	reqctx.SetBody(r, []byte(res))
+	return nil
+}
+
+func isForm(r *http.Request) bool {
+	ct, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	return err == nil && ct == formContentType
+}
@@ -0,0 +1,52 @@
+package selector
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newFormRequest(body string) *http.Request {
+	r, _ := http.NewRequest("POST", "http://localhost:8000/login", bytes.NewReader([]byte(body)))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded; charset=utf-8")
+	return r
+}
+
+func TestFormBody(t *testing.T) {
+	r := newFormRequest("user=janet&api.key=k1")
+
+	val, err := Get(r, "body.user")
+	assert.Nil(t, err)
+	assert.Equal(t, "janet", val)
+
+	val, err = Get(r, "body.api.key")
+	assert.Nil(t, err)
+	assert.Equal(t, "k1", val, "Dotted names should be read as a single form field")
+
+	val, err = Get(r, "body.raw")
+	assert.Nil(t, err)
+	assert.Equal(t, url.Values{"user": {"janet"}, "api.key": {"k1"}}, val)
+
+	_, err = Get(r, "body.missing")
+	assert.True(t, IsNotFound(err))
+
+	assert.Nil(t, Set(r, "body.partner", "partner1"))
+	raw, _ := ioutil.ReadAll(r.Body)
+	form, _ := url.ParseQuery(string(raw))
+	assert.Equal(t, "partner1", form.Get("partner"))
+	assert.Equal(t, "janet", form.Get("user"))
+	assert.Equal(t, int64(len(raw)), r.ContentLength)
+}
+
+func TestEmptyJSONBody(t *testing.T) {
+	r, _ := http.NewRequest("POST", "http://localhost:8000/echo", nil)
+
+	assert.Nil(t, Set(r, "body.partner", "partner1"))
+	raw, _ := ioutil.ReadAll(r.Body)
+	assert.Equal(t, `{"partner":"partner1"}`, string(raw))
+	assert.Equal(t, "22", r.Header.Get("Content-Length"))
+}
@@ -0,0 +1,379 @@
+//Package selector implements the path language used by the ext modules to read and write request data.
+//
+//A selector is a source followed by a dotted path:
+//
+//	header.<name>         request header, header.raw for all of them (read only)
+//	query.<name>          query string parameter, query.raw for all of them (read only)
+//	body.<path>           JSON body field (gjson/sjson path) or form field for url encoded bodies,
+//	                      body.raw for the decoded body (read only)
+//	cookie.<name>         request cookie
+//	param.<name>          endpoint URL parameter (read only)
+//	jwt.raw               bearer token as sent (read only)
+//	jwt.header[.<path>]   decoded JWT header or one of its fields (read only)
+//	jwt.payload[.<path>]  decoded JWT claims or one of its fields (read only)
+//	ctx.<path>            value stored in the request context bag, see reqctx. The unverified token
+//	                      claims are read as ctx.jwt.unverified_claims.<path>
+//
+//Sources are case insensitive. Empty values are reported as not found.
+package selector
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/devopsfaith/krakend-ce/ext/reqctx"
+	"github.com/tidwall/gjson"
+)
+
+const (
+	//SourceHeader request headers
+	SourceHeader = "header"
+	//SourceQuery query string parameters
+	SourceQuery = "query"
+	//SourceBody JSON or url encoded form body
+	SourceBody = "body"
+	//SourceCookie request cookies
+	SourceCookie = "cookie"
+	//SourceParam endpoint URL parameters
+	SourceParam = "param"
+	//SourceJWT bearer token
+	SourceJWT = "jwt"
+	//SourceContext request context bag
+	SourceContext = reqctx.Prefix
+
+	raw = "raw"
+)
+
+var (
+	//ErrInvalid the selector is malformed or uses an unknown source
+	ErrInvalid = errors.New("invalid selector")
+	//ErrNotFound the selected value is not present in the request
+	ErrNotFound = errors.New("value not found")
+	//ErrReadOnly the selected value can not be written
+	ErrReadOnly = errors.New("read only selector")
+)
+
+//Error selector failure, Err is one of ErrInvalid, ErrNotFound, ErrReadOnly or the error
+//raised while decoding or encoding the request data
+type Error struct {
+	Selector string
+	Err      error
+}
+
+func (e *Error) Error() string {
+	return e.Selector + ": " + e.Err.Error()
+}
+
+//IsNotFound checks if the selected value is missing
+func IsNotFound(err error) bool {
+	e, ok := err.(*Error)
+	return ok && e.Err == ErrNotFound
+}
+
+//IsInvalid checks if the selector is malformed
+func IsInvalid(err error) bool {
+	e, ok := err.(*Error)
+	return ok && e.Err == ErrInvalid
+}
+
+//Selector parsed selector
+type Selector struct {
+	Source string
+	Path   []string
+}
+
+//Parse parse and validate a selector
+func Parse(s string) (Selector, error) {
+	parts := strings.Split(s, ".")
+	sel := Selector{Source: strings.ToLower(parts[0]), Path: parts[1:]}
+
+	for _, p := range sel.Path {
+		if p == "" {
+			return sel, &Error{Selector: s, Err: ErrInvalid}
+		}
+	}
+
+	switch sel.Source {
+	case SourceHeader, SourceQuery, SourceBody, SourceCookie, SourceParam, SourceContext:
+		if len(sel.Path) == 0 {
+			return sel, &Error{Selector: s, Err: ErrInvalid}
+		}
+	case SourceJWT:
+		if len(sel.Path) == 0 {
+			return sel, &Error{Selector: s, Err: ErrInvalid}
+		}
+		switch strings.ToLower(sel.Path[0]) {
+		case raw:
+			if len(sel.Path) > 1 {
+				return sel, &Error{Selector: s, Err: ErrInvalid}
+			}
+		case "header", "payload":
+		default:
+			return sel, &Error{Selector: s, Err: ErrInvalid}
+		}
+	default:
+		return sel, &Error{Selector: s, Err: ErrInvalid}
+	}
+
+	return sel, nil
+}
+
+//Get read the value of the selector from the request
+func Get(r *http.Request, selector string) (interface{}, error) {
+	sel, err := Parse(selector)
+	if err != nil {
+		return nil, err
+	}
+	return sel.Get(r)
+}
+
+//Set write the value of the selector into the request
+func Set(r *http.Request, selector string, val interface{}) error {
+	sel, err := Parse(selector)
+	if err != nil {
+		return err
+	}
+	return sel.Set(r, val)
+}
+
+//Delete remove the value of the selector from the request
+func Delete(r *http.Request, selector string) error {
+	sel, err := Parse(selector)
+	if err != nil {
+		return err
+	}
+	return sel.Delete(r)
+}
+
+//String selector as written in the config
+func (s Selector) String() string {
+	return strings.Join(append([]string{s.Source}, s.Path...), ".")
+}
+
+//ReadOnly whether Set always fails for the selector
+func (s Selector) ReadOnly() bool {
+	switch s.Source {
+	case SourceParam, SourceJWT:
+		return true
+	case SourceContext:
+		return false
+	default:
+		return s.isRaw()
+	}
+}
+
+func (s Selector) isRaw() bool {
+	return len(s.Path) == 1 && strings.ToLower(s.Path[0]) == raw
+}
+
+func (s Selector) fail(err error) error {
+	return &Error{Selector: s.String(), Err: err}
+}
+
+//Get read the value of the selector from the request. Headers, query parameters, cookies and
+//params are strings, body and JWT fields keep their JSON type
+func (s Selector) Get(r *http.Request) (interface{}, error) {
+	if r == nil || r.URL == nil {
+		return nil, s.fail(ErrNotFound)
+	}
+
+	switch s.Source {
+	case SourceHeader:
+		if s.isRaw() {
+			return r.Header, nil
+		}
+		return s.found(r.Header.Get(s.Path[0]))
+	case SourceQuery:
+		if s.isRaw() {
+			return r.URL.Query(), nil
+		}
+		return s.found(r.URL.Query().Get(s.Path[0]))
+	case SourceBody:
+		return s.getBody(r)
+	case SourceCookie:
+		c, err := r.Cookie(s.Path[0])
+		if err != nil {
+			return nil, s.fail(ErrNotFound)
+		}
+		return s.found(c.Value)
+	case SourceParam:
+		v, ok := reqctx.FromRequest(r).Get(reqctx.Params + "." + s.Path[0])
+		if !ok {
+			return nil, s.fail(ErrNotFound)
+		}
+		return s.found(Format(v))
+	case SourceJWT:
+		return s.getJWT(r)
+	case SourceContext:
+		v, ok := reqctx.Value(r, strings.Join(s.Path, "."))
+		if !ok {
+			return nil, s.fail(ErrNotFound)
+		}
+		return v, nil
+	default:
+		return nil, s.fail(ErrInvalid)
+	}
+}
+
+//Set write the value of the selector into the request. Values are written as strings, see Format,
+//except JSON body fields and context values, which keep their type
+func (s Selector) Set(r *http.Request, val interface{}) error {
+	if r == nil || r.URL == nil {
+		return s.fail(ErrNotFound)
+	}
+	if s.ReadOnly() {
+		return s.fail(ErrReadOnly)
+	}
+
+	switch s.Source {
+	case SourceHeader:
+		if r.Header == nil {
+			r.Header = http.Header{}
+		}
+		r.Header.Set(s.Path[0], Format(val))
+		return nil
+	case SourceQuery:
+		q := r.URL.Query()
+		q.Set(s.Path[0], Format(val))
+		r.URL.RawQuery = q.Encode()
+		return nil
+	case SourceBody:
+		return s.setBody(r, val)
+	case SourceCookie:
+		return s.setCookie(r, Format(val))
+	case SourceContext:
+		b := reqctx.FromRequest(r)
+		if b == nil {
+			return s.fail(ErrNotFound)
+		}
+		b.Set(strings.Join(s.Path, "."), val)
+		return nil
+	default:
+		return s.fail(ErrInvalid)
+	}
+}
+
+//Delete remove the value of the selector from the request. Missing values are not an error
+func (s Selector) Delete(r *http.Request) error {
+	if r == nil || r.URL == nil {
+		return nil
+	}
+	if s.ReadOnly() {
+		return s.fail(ErrReadOnly)
+	}
+
+	switch s.Source {
+	case SourceHeader:
+		r.Header.Del(s.Path[0])
+		return nil
+	case SourceQuery:
+		q := r.URL.Query()
+		if _, ok := q[s.Path[0]]; ok {
+			q.Del(s.Path[0])
+			r.URL.RawQuery = q.Encode()
+		}
+		return nil
+	case SourceBody:
+		return s.deleteBody(r)
+	case SourceCookie:
+		s.deleteCookie(r)
+		return nil
+	case SourceContext:
+		reqctx.FromRequest(r).Delete(strings.Join(s.Path, "."))
+		return nil
+	default:
+		return s.fail(ErrInvalid)
+	}
+}
+
+//Format render a selected value as the string written into the request. Numbers are written without
+//exponent, so the JSON numbers, decoded as float64, keep their digits
+func Format(v interface{}) string {
+	switch t := v.(type) {
+	case string:
+		return t
+	case float64:
+		return strconv.FormatFloat(t, 'f', -1, 64)
+	case float32:
+		return strconv.FormatFloat(float64(t), 'f', -1, 32)
+	case json.Number:
+		return t.String()
+	}
+	return fmt.Sprintf("%v", v)
+}
+
+func (s Selector) found(v string) (interface{}, error) {
+	if v == "" {
+		return nil, s.fail(ErrNotFound)
+	}
+	return v, nil
+}
+
+func (s Selector) getJWT(r *http.Request) (interface{}, error) {
+	token, err := reqctx.ParseToken(r)
+	if err != nil {
+		return nil, s.fail(ErrNotFound)
+	}
+
+	var data string
+	switch strings.ToLower(s.Path[0]) {
+	case raw:
+		return token.Raw, nil
+	case "header":
+		data = token.Header
+	default:
+		data = token.Payload
+	}
+
+	if len(s.Path) == 1 {
+		var d map[string]interface{}
+		if err := json.Unmarshal([]byte(data), &d); err != nil {
+			return nil, s.fail(err)
+		}
+		return d, nil
+	}
+
+	val := gjson.Get(data, strings.Join(s.Path[1:], "."))
+	if !val.Exists() {
+		return nil, s.fail(ErrNotFound)
+	}
+	return val.Value(), nil
+}
+
+func (s Selector) deleteCookie(r *http.Request) {
+	if r.Header == nil {
+		return
+	}
+	cookies := r.Cookies()
+	r.Header.Del("Cookie")
+	for _, c := range cookies {
+		if c.Name != s.Path[0] {
+			r.AddCookie(c)
+		}
+	}
+}
+
+func (s Selector) setCookie(r *http.Request, val string) error {
+	if r.Header == nil {
+		r.Header = http.Header{}
+	}
+	cookies := r.Cookies()
+	r.Header.Del("Cookie")
+
+	replaced := false
+	for _, c := range cookies {
+		if c.Name == s.Path[0] {
+			c.Value = val
+			replaced = true
+		}
+		r.AddCookie(c)
+	}
+	if !replaced {
+		r.AddCookie(&http.Cookie{Name: s.Path[0], Value: val})
+	}
+	return nil
+}
@@ -0,0 +1,107 @@
+package service
+
+import (
+	"time"
+
+	"github.com/devopsfaith/krakend-ce/ext/lint"
+	"github.com/sony/gobreaker"
+)
+
+const (
+	defaultBreakerInterval  = 60
+	defaultBreakerTimeout   = 10
+	defaultBreakerMaxErrors = 5
+)
+
+//BreakerConfig circuit breaker settings. As the backend circuit breaker, it opens after more than
+//MaxErrors consecutive errors in Interval seconds and stays open for Timeout seconds
+type BreakerConfig struct {
+	Interval  int
+	Timeout   int
+	MaxErrors int
+}
+
+//UnavailableError error returned when the lookup service can not be reached, answers with a 5xx
+//or its circuit breaker is open
+type UnavailableError struct {
+	Err error
+}
+
+func (e *UnavailableError) Error() string {
+	return e.Err.Error()
+}
+
+//IsUnavailable checks if the lookup failed because the service is unavailable
+func IsUnavailable(err error) bool {
+	_, ok := err.(*UnavailableError)
+	return ok
+}
+
+//BreakerField lint description of the "circuit_breaker" object parsed by ParseBreakerConfig
+var BreakerField = lint.Field{
+	Name: "circuit_breaker",
+	Kind: lint.KindObject,
+	Fields: []lint.Field{
+		{Name: "interval", Kind: lint.KindInt, Check: lint.Min(1)},
+		{Name: "timeout", Kind: lint.KindInt, Check: lint.Min(1)},
+		{Name: "max_errors", Kind: lint.KindInt, Check: lint.Min(1)},
+	},
+}
+
+//ParseBreakerConfig read the circuit breaker settings from the "circuit_breaker" object of the module
+//extra config, nil when not configured
+func ParseBreakerConfig(tmp map[string]interface{}) *BreakerConfig {
+	c, ok := tmp["circuit_breaker"].(map[string]interface{})
+	if !ok {
+		return nil
+	}
+
+	return &BreakerConfig{
+		Interval:  parseInt(c["interval"], defaultBreakerInterval),
+		Timeout:   parseInt(c["timeout"], defaultBreakerTimeout),
+		MaxErrors: parseInt(c["max_errors"], defaultBreakerMaxErrors),
+	}
+}
+
+type breaker struct {
+	cb *gobreaker.CircuitBreaker
+}
+
+func newBreaker(name string, cfg *BreakerConfig) *breaker {
+	if cfg == nil {
+		return nil
+	}
+	return &breaker{
+		cb: gobreaker.NewCircuitBreaker(gobreaker.Settings{
+			Name:     name,
+			Interval: time.Duration(cfg.Interval) * time.Second,
+			Timeout:  time.Duration(cfg.Timeout) * time.Second,
+			ReadyToTrip: func(counts gobreaker.Counts) bool {
+				return counts.ConsecutiveFailures > uint32(cfg.MaxErrors)
+			},
+		}),
+	}
+}
+
+//call run the lookup through the breaker. Only unavailability errors count as failures, so
+//rejected keys or invalid payloads never open the circuit
+func (b *breaker) call(f func() error) error {
+	if b == nil {
+		return f()
+	}
+
+	var res error
+	_, err := b.cb.Execute(func() (interface{}, error) {
+		res = f()
+		if IsUnavailable(res) {
+			return nil, res
+		}
+		return nil, nil
+	})
+
+	if err == gobreaker.ErrOpenState || err == gobreaker.ErrTooManyRequests {
+		return &UnavailableError{Err: err}
+	}
+
+	return res
+}
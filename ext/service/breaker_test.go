@@ -0,0 +1,68 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	cache "github.com/devopsfaith/krakend-ce/ext/cache"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseBreakerConfig(t *testing.T) {
+	assert.Nil(t, ParseBreakerConfig(map[string]interface{}{}))
+
+	cfg := ParseBreakerConfig(map[string]interface{}{
+		"circuit_breaker": map[string]interface{}{
+			"max_errors": 2,
+		},
+	})
+	assert.Equal(t, &BreakerConfig{Interval: defaultBreakerInterval, Timeout: defaultBreakerTimeout, MaxErrors: 2}, cfg)
+}
+
+func TestHTTPKeyAuthBreaker(t *testing.T) {
+	calls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer srv.Close()
+
+	ka := NewHTTPKeyAuth(HTTPConfig{
+		Address: srv.URL,
+		Cache:   cache.NewMemoryCache(0),
+		Breaker: &BreakerConfig{Interval: 60, Timeout: 60, MaxErrors: 1},
+	})
+
+	for i := 0; i < 4; i++ {
+		_, err := ka.Validate(context.Background(), testInput("key1"))
+		assert.True(t, IsUnavailable(err))
+	}
+	assert.Equal(t, 2, calls, "Breaker should short-circuit the lookups once open")
+}
+
+func TestHTTPOPABreakerIgnoresRejections(t *testing.T) {
+	calls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{"error": "invalid input"})
+	}))
+	defer srv.Close()
+
+	opa := NewHTTPOPA(HTTPConfig{
+		Address:  srv.URL,
+		BasePath: "/v1/data/",
+		Cache:    cache.NewMemoryCache(0),
+		Breaker:  &BreakerConfig{Interval: 60, Timeout: 60, MaxErrors: 1},
+	})
+
+	for i := 0; i < 4; i++ {
+		_, err := opa.Evaluate(context.Background(), "opa.test", "allow", testInput("user1"))
+		assert.NotNil(t, err)
+		assert.False(t, IsUnavailable(err))
+	}
+	assert.Equal(t, 4, calls)
+}
@@ -0,0 +1,331 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/devopsfaith/krakend-ce/ext/lint"
+	"go.opencensus.io/plugin/ochttp"
+)
+
+const (
+	defaultTimeout             = 5 * time.Second
+	defaultDialTimeout         = 2 * time.Second
+	defaultIdleConnTimeout     = 90 * time.Second
+	defaultMaxIdleConns        = 100
+	defaultMaxIdleConnsPerHost = 100
+	defaultRetryBackoff        = 100 * time.Millisecond
+	//DefaultRequestIDHeader header carrying the request ID to the lookup services
+	DefaultRequestIDHeader = "X-Request-Id"
+)
+
+type requestIDKey struct{}
+
+//ClientConfig http client settings of a lookup service
+type ClientConfig struct {
+	Timeout             time.Duration
+	DialTimeout         time.Duration
+	IdleConnTimeout     time.Duration
+	MaxIdleConns        int
+	MaxIdleConnsPerHost int
+	CAFile              string
+	CertFile            string
+	KeyFile             string
+	InsecureSkipVerify  bool
+	//Retries number of extra attempts on connection errors and 5xx responses
+	Retries int
+	//RetryBackoff wait before the first retry, doubled on every attempt
+	RetryBackoff    time.Duration
+	RequestIDHeader string
+}
+
+//Client pooled http client posting JSON to the lookup services
+type Client struct {
+	client          *http.Client
+	retries         int
+	backoff         time.Duration
+	requestIDHeader string
+}
+
+//DefaultClientConfig returns the client settings used when none are configured
+func DefaultClientConfig() ClientConfig {
+	return ClientConfig{
+		Timeout:             defaultTimeout,
+		DialTimeout:         defaultDialTimeout,
+		IdleConnTimeout:     defaultIdleConnTimeout,
+		MaxIdleConns:        defaultMaxIdleConns,
+		MaxIdleConnsPerHost: defaultMaxIdleConnsPerHost,
+		RetryBackoff:        defaultRetryBackoff,
+		RequestIDHeader:     DefaultRequestIDHeader,
+	}
+}
+
+//ClientField lint description of the "client" object parsed by ParseClientConfig
+var ClientField = lint.Field{
+	Name: "client",
+	Kind: lint.KindObject,
+	Fields: []lint.Field{
+		{Name: "timeout", Kind: lint.KindDuration},
+		{Name: "dial_timeout", Kind: lint.KindDuration},
+		{Name: "idle_conn_timeout", Kind: lint.KindDuration},
+		{Name: "retry_backoff", Kind: lint.KindDuration},
+		{Name: "max_idle_conns", Kind: lint.KindInt, Check: lint.Min(0)},
+		{Name: "max_idle_conns_per_host", Kind: lint.KindInt, Check: lint.Min(0)},
+		{Name: "retries", Kind: lint.KindInt, Check: lint.Min(0)},
+		{Name: "tls_ca", Kind: lint.KindString},
+		{Name: "tls_cert", Kind: lint.KindString},
+		{Name: "tls_key", Kind: lint.KindString},
+		{Name: "tls_insecure_skip_verify", Kind: lint.KindBool},
+		{Name: "request_id_header", Kind: lint.KindString},
+	},
+}
+
+//ParseClientConfig read the client settings from the "client" object of the module extra config
+func ParseClientConfig(tmp map[string]interface{}) ClientConfig {
+	cfg := DefaultClientConfig()
+
+	c, ok := tmp["client"].(map[string]interface{})
+	if !ok {
+		return cfg
+	}
+
+	cfg.Timeout = parseDuration(c["timeout"], cfg.Timeout)
+	cfg.DialTimeout = parseDuration(c["dial_timeout"], cfg.DialTimeout)
+	cfg.IdleConnTimeout = parseDuration(c["idle_conn_timeout"], cfg.IdleConnTimeout)
+	cfg.RetryBackoff = parseDuration(c["retry_backoff"], cfg.RetryBackoff)
+	cfg.MaxIdleConns = parseInt(c["max_idle_conns"], cfg.MaxIdleConns)
+	cfg.MaxIdleConnsPerHost = parseInt(c["max_idle_conns_per_host"], cfg.MaxIdleConnsPerHost)
+	cfg.Retries = parseInt(c["retries"], cfg.Retries)
+
+	if v, ok := c["tls_ca"].(string); ok {
+		cfg.CAFile = v
+	}
+
+	if v, ok := c["tls_cert"].(string); ok {
+		cfg.CertFile = v
+	}
+
+	if v, ok := c["tls_key"].(string); ok {
+		cfg.KeyFile = v
+	}
+
+	if v, ok := c["tls_insecure_skip_verify"].(bool); ok {
+		cfg.InsecureSkipVerify = v
+	}
+
+	if v, ok := c["request_id_header"].(string); ok {
+		cfg.RequestIDHeader = v
+	}
+
+	return cfg
+}
+
+//NewClient create a client with its own connection pool
+func NewClient(cfg ClientConfig) (*Client, error) {
+	tlsConfig, err := cfg.tlsConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	transport := &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		DialContext: (&net.Dialer{
+			Timeout:   cfg.DialTimeout,
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		TLSClientConfig:       tlsConfig,
+		MaxIdleConns:          cfg.MaxIdleConns,
+		MaxIdleConnsPerHost:   cfg.MaxIdleConnsPerHost,
+		IdleConnTimeout:       cfg.IdleConnTimeout,
+		TLSHandshakeTimeout:   cfg.DialTimeout,
+		ExpectContinueTimeout: time.Second,
+	}
+
+	return &Client{
+		client: &http.Client{
+			//the ochttp transport propagates the trace context to the services
+			Transport: &ochttp.Transport{Base: transport},
+			Timeout:   cfg.Timeout,
+		},
+		retries:         cfg.Retries,
+		backoff:         cfg.RetryBackoff,
+		requestIDHeader: cfg.RequestIDHeader,
+	}, nil
+}
+
+var defaultClient, _ = NewClient(DefaultClientConfig())
+
+//WithRequestID returns a copy of the context carrying the request ID sent to the lookup services
+func WithRequestID(ctx context.Context, id string) context.Context {
+	if id == "" {
+		return ctx
+	}
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+//RequestID returns the request ID stored in the context
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+//Post send data as JSON to the url and decode the JSON response into res. Connection errors
+//and 5xx responses are retried with backoff until the retries or the context are exhausted,
+//then reported as an UnavailableError
+func (c *Client) Post(ctx context.Context, url string, data, res interface{}) error {
+	o, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	header := http.Header{"Content-Type": []string{"application/json"}}
+
+	var rsp *response
+	if err := retry(ctx, c.retries, c.backoff, func() (bool, error) {
+		rsp, err = c.send(ctx, http.MethodPost, url, header, o)
+		if err != nil {
+			return ctx.Err() == nil, err
+		}
+		if rsp.status >= 300 {
+			return rsp.status >= 500, errors.New(string(rsp.body))
+		}
+		return false, nil
+	}); err != nil {
+		return err
+	}
+
+	return json.Unmarshal(rsp.body, res)
+}
+
+//retry call f until it succeeds, fails with a non retriable error or the retries are exhausted.
+//The last retriable error is reported as an UnavailableError
+func retry(ctx context.Context, retries int, backoff time.Duration, f func() (bool, error)) error {
+	for attempt := 0; ; attempt++ {
+		retriable, err := f()
+		if err == nil || !retriable {
+			return err
+		}
+
+		if attempt >= retries {
+			return &UnavailableError{Err: err}
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+type response struct {
+	status int
+	header http.Header
+	body   []byte
+}
+
+func (c *Client) send(ctx context.Context, method, url string, header http.Header, body []byte) (*response, error) {
+	var rd io.Reader
+	if body != nil {
+		rd = bytes.NewReader(body)
+	}
+
+	req, err := http.NewRequest(method, url, rd)
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	for k, vs := range header {
+		req.Header[k] = vs
+	}
+	if host := header.Get("Host"); host != "" {
+		req.Host = host
+	}
+	if id := RequestID(ctx); id != "" && c.requestIDHeader != "" {
+		req.Header.Set(c.requestIDHeader, id)
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	defer resp.Body.Close()
+	rdata, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	return &response{
+		status: resp.StatusCode,
+		header: resp.Header,
+		body:   rdata,
+	}, nil
+}
+
+func (cfg ClientConfig) tlsConfig() (*tls.Config, error) {
+	if cfg.CAFile == "" && cfg.CertFile == "" && !cfg.InsecureSkipVerify {
+		return nil, nil
+	}
+
+	tc := &tls.Config{
+		InsecureSkipVerify: cfg.InsecureSkipVerify,
+	}
+
+	if cfg.CAFile != "" {
+		pem, err := ioutil.ReadFile(cfg.CAFile)
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, errors.New("Invalid CA certificate " + cfg.CAFile)
+		}
+		tc.RootCAs = pool
+	}
+
+	if cfg.CertFile != "" {
+		cert, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+		tc.Certificates = []tls.Certificate{cert}
+	}
+
+	return tc, nil
+}
+
+func parseDuration(v interface{}, def time.Duration) time.Duration {
+	s, ok := v.(string)
+	if !ok {
+		return def
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return def
+	}
+	return d
+}
+
+func parseInt(v interface{}, def int) int {
+	if v == nil {
+		return def
+	}
+	i, err := strconv.Atoi(fmt.Sprintf("%v", v))
+	if err != nil {
+		return def
+	}
+	return i
+}
@@ -0,0 +1,103 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseClientConfig(t *testing.T) {
+	cfg := ParseClientConfig(map[string]interface{}{})
+	assert.Equal(t, DefaultClientConfig(), cfg)
+
+	cfg = ParseClientConfig(map[string]interface{}{
+		"client": map[string]interface{}{
+			"timeout":                 "300ms",
+			"max_idle_conns_per_host": 10,
+			"retries":                 2,
+			"retry_backoff":           "5ms",
+			"request_id_header":       "X-Trace",
+			"tls_ca":                  "ca.pem",
+		},
+	})
+	assert.Equal(t, 300*time.Millisecond, cfg.Timeout)
+	assert.Equal(t, 10, cfg.MaxIdleConnsPerHost)
+	assert.Equal(t, 2, cfg.Retries)
+	assert.Equal(t, 5*time.Millisecond, cfg.RetryBackoff)
+	assert.Equal(t, "X-Trace", cfg.RequestIDHeader)
+	assert.Equal(t, "ca.pem", cfg.CAFile)
+	assert.Equal(t, defaultDialTimeout, cfg.DialTimeout)
+}
+
+func TestClientRetry(t *testing.T) {
+	calls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		assert.Equal(t, "req-1", r.Header.Get(DefaultRequestIDHeader))
+		if calls < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"result": true})
+	}))
+	defer srv.Close()
+
+	cfg := DefaultClientConfig()
+	cfg.Retries = 2
+	cfg.RetryBackoff = time.Millisecond
+	c, err := NewClient(cfg)
+	assert.Nil(t, err)
+
+	var rsp map[string]interface{}
+	err = c.Post(WithRequestID(context.Background(), "req-1"), srv.URL, map[string]interface{}{}, &rsp)
+	assert.Nil(t, err)
+	assert.Equal(t, true, rsp["result"])
+	assert.Equal(t, 3, calls)
+}
+
+func TestClientNoRetryOnClientError(t *testing.T) {
+	calls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer srv.Close()
+
+	cfg := DefaultClientConfig()
+	cfg.Retries = 2
+	c, _ := NewClient(cfg)
+
+	var rsp map[string]interface{}
+	assert.NotNil(t, c.Post(context.Background(), srv.URL, nil, &rsp))
+	assert.Equal(t, 1, calls)
+}
+
+func TestClientCancel(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(200 * time.Millisecond):
+		}
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	var rsp map[string]interface{}
+	assert.NotNil(t, defaultClient.Post(ctx, srv.URL, nil, &rsp))
+	assert.True(t, time.Since(start) < 150*time.Millisecond, "Lookup should be aborted by the context")
+}
+
+func TestNewClientInvalidTLS(t *testing.T) {
+	cfg := DefaultClientConfig()
+	cfg.CAFile = "missing-ca.pem"
+	_, err := NewClient(cfg)
+	assert.NotNil(t, err)
+}
@@ -1,36 +1,69 @@
 package service
 
 import (
-	"bytes"
-	"encoding/json"
-	"errors"
-	"io/ioutil"
-	"net/http"
-)
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
 
-func post(address, path string, data, res interface{}) error {
-	o, err := json.Marshal(data)
-	if err != nil {
-		return err
-	}
-	b := bytes.NewBuffer(o)
+	cache "github.com/devopsfaith/krakend-ce/ext/cache"
+)
 
-	url := address + path
+//HTTPConfig http service settings
+type HTTPConfig struct {
+	Address  string
+	BasePath string
+	Cache    cache.Local
+	//TTLPath dotted path of the response field holding the cache TTL in seconds
+	TTLPath string
+	//TagPath dotted path of the response field used to tag the cached entry, e.g. the key ID
+	TagPath string
+	//Client http client used for the lookups, a default pooled client when nil
+	Client *Client
+	//Breaker circuit breaker settings, no breaker when nil
+	Breaker *BreakerConfig
+}
 
-	resp, err := http.Post(url, "application/json", b)
-	if err != nil {
-		return err
+func (cfg HTTPConfig) client() *Client {
+	if cfg.Client != nil {
+		return cfg.Client
 	}
+	return defaultClient
+}
 
-	defer resp.Body.Close()
-	rdata, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		return err
+func responseTTL(path string, rsp map[string]interface{}) time.Duration {
+	v, ok := responseValue(path, rsp)
+	if !ok {
+		return 0
 	}
+	sec, err := strconv.ParseFloat(fmt.Sprintf("%v", v), 64)
+	if err != nil || sec <= 0 {
+		return 0
+	}
+	return time.Duration(sec * float64(time.Second))
+}
 
-	if resp.StatusCode >= 300 {
-		return errors.New(string(rdata))
+func responseTags(path string, rsp map[string]interface{}) []string {
+	v, ok := responseValue(path, rsp)
+	if !ok || v == nil {
+		return nil
 	}
+	return []string{fmt.Sprintf("%v", v)}
+}
 
-	return json.Unmarshal(rdata, res)
+func responseValue(path string, rsp map[string]interface{}) (interface{}, bool) {
+	if path == "" {
+		return nil, false
+	}
+	var cur interface{} = rsp
+	for _, p := range strings.Split(path, ".") {
+		m, ok := cur.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		if cur, ok = m[p]; !ok {
+			return nil, false
+		}
+	}
+	return cur, true
 }
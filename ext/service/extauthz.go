@@ -0,0 +1,136 @@
+package service
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/devopsfaith/krakend-ce/ext/reqctx"
+)
+
+//AuthzResponse external authorization decision. When denied, the status, headers and body
+//are the ones to return to the client, otherwise the headers are the ones to add to the request
+type AuthzResponse struct {
+	Allowed    bool
+	StatusCode int
+	Header     http.Header
+	Body       []byte
+}
+
+//Authorizer external authorization service interface
+type Authorizer interface {
+	Check(ctx context.Context, r *http.Request) (*AuthzResponse, error)
+}
+
+//ExtAuthzConfig Envoy ext_authz HTTP service settings
+type ExtAuthzConfig struct {
+	Address string
+	//PathPrefix prepended to the original request path
+	PathPrefix string
+	//AllowedHeaders request headers forwarded to the authorization service besides Authorization
+	AllowedHeaders []string
+	//MaxRequestBytes amount of the request body forwarded, none when 0
+	MaxRequestBytes int
+	//Client http client used for the checks, a default pooled client when nil
+	Client *Client
+	//Breaker circuit breaker settings, no breaker when nil
+	Breaker *BreakerConfig
+}
+
+//HTTPExtAuthz authorization service implementing the Envoy ext_authz HTTP contract: the check
+//request mirrors the method and path of the original one and a 2xx response allows it
+type HTTPExtAuthz struct {
+	address         string
+	pathPrefix      string
+	allowedHeaders  []string
+	maxRequestBytes int
+	client          *Client
+	breaker         *breaker
+}
+
+//DummyExtAuthz dummy external authorization service
+type DummyExtAuthz struct {
+	Response *AuthzResponse
+	Error    error
+}
+
+//NewHTTPExtAuthz create new ext_authz http service instance
+func NewHTTPExtAuthz(cfg ExtAuthzConfig) *HTTPExtAuthz {
+	client := cfg.Client
+	if client == nil {
+		client = defaultClient
+	}
+
+	return &HTTPExtAuthz{
+		address:         cfg.Address,
+		pathPrefix:      cfg.PathPrefix,
+		allowedHeaders:  append([]string{"Authorization"}, cfg.AllowedHeaders...),
+		maxRequestBytes: cfg.MaxRequestBytes,
+		client:          client,
+		breaker:         newBreaker("extauthz:"+cfg.Address+cfg.PathPrefix, cfg.Breaker),
+	}
+}
+
+//NewDummyExtAuthz create new dummy external authorization instance
+func NewDummyExtAuthz() *DummyExtAuthz {
+	return &DummyExtAuthz{}
+}
+
+//Check ask the authorization service about the request
+func (d *DummyExtAuthz) Check(ctx context.Context, r *http.Request) (*AuthzResponse, error) {
+	return d.Response, d.Error
+}
+
+//Check ask the authorization service about the request. Only connection errors are
+//retried, any response from the service is a decision
+func (h *HTTPExtAuthz) Check(ctx context.Context, r *http.Request) (res *AuthzResponse, err error) {
+	ctx, c := startCall(ctx, "extauthz", TransportHTTP, "extauthz.check")
+	defer func() { c.end(res != nil && res.Allowed, err) }()
+
+	body, err := h.body(r)
+	if err != nil {
+		return nil, err
+	}
+
+	header := http.Header{"Host": []string{r.Host}}
+	for _, k := range h.allowedHeaders {
+		if vs, ok := r.Header[http.CanonicalHeaderKey(k)]; ok {
+			header[http.CanonicalHeaderKey(k)] = vs
+		}
+	}
+
+	url := h.address + h.pathPrefix + r.URL.RequestURI()
+
+	var rsp *response
+	if err := h.breaker.call(func() error {
+		return retry(ctx, h.client.retries, h.client.backoff, func() (bool, error) {
+			rsp, err = h.client.send(ctx, r.Method, url, header, body)
+			return err != nil && ctx.Err() == nil, err
+		})
+	}); err != nil {
+		return nil, err
+	}
+
+	return &AuthzResponse{
+		Allowed:    rsp.status >= 200 && rsp.status < 300,
+		StatusCode: rsp.status,
+		Header:     rsp.header,
+		Body:       rsp.body,
+	}, nil
+}
+
+//body returns up to maxRequestBytes of the shared request body
+func (h *HTTPExtAuthz) body(r *http.Request) ([]byte, error) {
+	if h.maxRequestBytes <= 0 {
+		return nil, nil
+	}
+
+	b, err := reqctx.Body(r)
+	if err != nil {
+		return nil, err
+	}
+	if len(b) > h.maxRequestBytes {
+		b = b[:h.maxRequestBytes]
+	}
+
+	return b, nil
+}
@@ -0,0 +1,45 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHTTPExtAuthz(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		assert.Equal(t, "POST", r.Method)
+		assert.Equal(t, "/authz/echo?id=1", r.URL.RequestURI())
+		assert.Equal(t, "gateway.local", r.Host)
+		assert.Equal(t, "tenant1", r.Header.Get("X-Tenant"))
+		assert.Equal(t, "", r.Header.Get("Cookie"))
+		assert.Equal(t, "{\"a\"", string(body))
+		w.Header().Set("X-User-Id", "user1")
+	}))
+	defer srv.Close()
+
+	ea := NewHTTPExtAuthz(ExtAuthzConfig{
+		Address:         srv.URL,
+		PathPrefix:      "/authz",
+		AllowedHeaders:  []string{"x-tenant"},
+		MaxRequestBytes: 4,
+	})
+
+	req, _ := http.NewRequest("POST", "http://gateway.local/echo?id=1", bytes.NewBufferString(`{"a":1}`))
+	req.Header.Set("X-Tenant", "tenant1")
+	req.Header.Set("Cookie", "session=1")
+
+	res, err := ea.Check(context.Background(), req)
+	assert.Nil(t, err)
+	assert.True(t, res.Allowed)
+	assert.Equal(t, "user1", res.Header.Get("X-User-Id"))
+
+	body, _ := ioutil.ReadAll(req.Body)
+	assert.Equal(t, `{"a":1}`, string(body), "Request body should be preserved")
+}
@@ -0,0 +1,296 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	cache "github.com/devopsfaith/krakend-ce/ext/cache"
+	"github.com/golang/protobuf/jsonpb"
+	"go.opencensus.io/plugin/ocgrpc"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	//TransportHTTP JSON over HTTP lookups
+	TransportHTTP = "http"
+	//TransportGRPC gRPC lookups
+	TransportGRPC = "grpc"
+
+	//ExtAuthzV2 Envoy ext_authz v2 API
+	ExtAuthzV2 = "v2"
+	//ExtAuthzV3 Envoy ext_authz v3 API
+	ExtAuthzV3 = "v3"
+
+	keyAuthValidateMethod = "/krakend.keyauth.v1.KeyAuth/Validate"
+	extAuthzCheckMethod   = "/envoy.service.auth.%s.Authorization/Check"
+)
+
+//GRPCConfig grpc service settings
+type GRPCConfig struct {
+	//Context the connection is closed when the context is cancelled
+	Context context.Context
+	//Address target of the connection, e.g. localhost:9191
+	Address string
+	Cache   cache.Local
+	//TTLPath dotted path of the response field holding the cache TTL in seconds
+	TTLPath string
+	//TagPath dotted path of the response field used to tag the cached entry, e.g. the key ID
+	TagPath string
+	//Client timeouts, retries, TLS and request ID settings of the connection
+	Client ClientConfig
+	//Breaker circuit breaker settings, no breaker when nil
+	Breaker *BreakerConfig
+	//ExtAuthzAPI version of the ext_authz Authorization service, v3 by default
+	ExtAuthzAPI string
+}
+
+//HTTPAttributes request attributes sent to the ext_authz servers
+type HTTPAttributes interface {
+	HTTPAttributes() (method, path string)
+}
+
+type grpcConn struct {
+	conn            *grpc.ClientConn
+	timeout         time.Duration
+	retries         int
+	backoff         time.Duration
+	requestIDHeader string
+}
+
+func newGRPCConn(cfg GRPCConfig) (*grpcConn, error) {
+	tlsConfig, err := cfg.Client.tlsConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	//the ocgrpc handler propagates the trace context to the services
+	opts := []grpc.DialOption{grpc.WithStatsHandler(&ocgrpc.ClientHandler{})}
+	if tlsConfig != nil {
+		opts = append(opts, grpc.WithTransportCredentials(credentials.NewTLS(tlsConfig)))
+	} else {
+		opts = append(opts, grpc.WithInsecure())
+	}
+
+	ctx := cfg.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	conn, err := grpc.DialContext(ctx, cfg.Address, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	go func() {
+		<-ctx.Done()
+		conn.Close()
+	}()
+
+	return &grpcConn{
+		conn:            conn,
+		timeout:         cfg.Client.Timeout,
+		retries:         cfg.Client.Retries,
+		backoff:         cfg.Client.RetryBackoff,
+		requestIDHeader: strings.ToLower(cfg.Client.RequestIDHeader),
+	}, nil
+}
+
+func (c *grpcConn) invoke(ctx context.Context, method string, req, rsp interface{}) error {
+	if id := RequestID(ctx); id != "" && c.requestIDHeader != "" {
+		ctx = metadata.AppendToOutgoingContext(ctx, c.requestIDHeader, id)
+	}
+
+	return retry(ctx, c.retries, c.backoff, func() (bool, error) {
+		cctx := ctx
+		if c.timeout > 0 {
+			var cancel context.CancelFunc
+			cctx, cancel = context.WithTimeout(ctx, c.timeout)
+			defer cancel()
+		}
+
+		err := c.conn.Invoke(cctx, method, req, rsp)
+		switch status.Code(err) {
+		case codes.OK:
+			return false, nil
+		case codes.Unavailable, codes.DeadlineExceeded:
+			return ctx.Err() == nil, err
+		default:
+			return false, err
+		}
+	})
+}
+
+//GRPCKeyAuth grpc keyAuth service, see proto/keyauth.proto
+type GRPCKeyAuth struct {
+	conn    *grpcConn
+	cache   cache.Local
+	ttlPath string
+	tagPath string
+	breaker *breaker
+}
+
+//NewGRPCKeyAuth create instance of grpc keyAuth service
+func NewGRPCKeyAuth(cfg GRPCConfig) (*GRPCKeyAuth, error) {
+	conn, err := newGRPCConn(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	return &GRPCKeyAuth{
+		conn:    conn,
+		cache:   cfg.Cache,
+		ttlPath: cfg.TTLPath,
+		tagPath: cfg.TagPath,
+		breaker: newBreaker("keyauth:"+cfg.Address, cfg.Breaker),
+	}, nil
+}
+
+//Validate validate key api
+func (g *GRPCKeyAuth) Validate(ctx context.Context, key Cacheable) (res map[string]interface{}, err error) {
+	ctx, c := startCall(ctx, "keyauth", TransportGRPC, "keyauth.validate")
+	defer func() { c.end(err == nil, err) }()
+
+	hs := key.Hash()
+
+	if rsp, ok := g.cache.Get(hs); ok {
+		if res, ok := rsp.(map[string]interface{}); ok {
+			c.hit()
+			return res, nil
+		}
+	}
+
+	fields, err := stringFields(key)
+	if err != nil {
+		return nil, err
+	}
+
+	var out keyValidateResponse
+	if err := g.breaker.call(func() error {
+		return g.conn.invoke(ctx, keyAuthValidateMethod, &keyValidateRequest{Fields: fields}, &out)
+	}); err != nil {
+		return nil, err
+	}
+
+	res = map[string]interface{}{}
+	if out.Result != nil {
+		raw, err := (&jsonpb.Marshaler{}).MarshalToString(out.Result)
+		if err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal([]byte(raw), &res); err != nil {
+			return nil, err
+		}
+	}
+	rsp := map[string]interface{}{"result": res}
+
+	cache.SetWithTags(g.cache, hs, rsp, responseTTL(g.ttlPath, rsp), responseTags(g.tagPath, rsp)...)
+
+	return rsp, nil
+}
+
+//GRPCOPA policy service speaking the Envoy ext_authz Check API
+type GRPCOPA struct {
+	conn    *grpcConn
+	cache   cache.Local
+	method  string
+	breaker *breaker
+}
+
+//NewGRPCOPA create new ext_authz policy service instance
+func NewGRPCOPA(cfg GRPCConfig) (*GRPCOPA, error) {
+	conn, err := newGRPCConn(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	api := cfg.ExtAuthzAPI
+	if api == "" {
+		api = ExtAuthzV3
+	}
+
+	return &GRPCOPA{
+		conn:    conn,
+		cache:   cfg.Cache,
+		method:  fmt.Sprintf(extAuthzCheckMethod, api),
+		breaker: newBreaker("opa:"+cfg.Address, cfg.Breaker),
+	}, nil
+}
+
+//Evaluate evaluate input request against policy. The request is allowed when the
+//ext_authz server answers with an OK status
+func (g *GRPCOPA) Evaluate(ctx context.Context, pkg, directive string, data Cacheable) (res bool, err error) {
+	ctx, c := startCall(ctx, "opa", TransportGRPC, "opa.evaluate", policyAttributes(pkg, directive)...)
+	defer func() { c.end(res, err) }()
+
+	hs := data.Hash()
+
+	if rsp, ok := g.cache.Get(hs); ok {
+		if res, ok := rsp.(bool); ok {
+			c.hit()
+			return res, nil
+		}
+	}
+
+	body, err := json.Marshal(data)
+	if err != nil {
+		return false, err
+	}
+
+	hr := &httpRequest{
+		ID:      RequestID(ctx),
+		Headers: map[string]string{"content-type": "application/json"},
+		Size:    int64(len(body)),
+		Body:    string(body),
+	}
+	if a, ok := data.(HTTPAttributes); ok {
+		hr.Method, hr.Path = a.HTTPAttributes()
+	}
+
+	req := &checkRequest{
+		Attributes: &attributeContext{
+			Request: &attributeRequest{HTTP: hr},
+			ContextExtensions: map[string]string{
+				"package":   pkg,
+				"directive": directive,
+			},
+		},
+	}
+
+	var out checkResponse
+	if err := g.breaker.call(func() error {
+		return g.conn.invoke(ctx, g.method, req, &out)
+	}); err != nil {
+		return false, err
+	}
+
+	res = out.Status == nil || codes.Code(out.Status.Code) == codes.OK
+
+	g.cache.Set(hs, res)
+
+	return res, nil
+}
+
+func stringFields(data interface{}) (map[string]string, error) {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+
+	var m map[string]interface{}
+	if err := json.Unmarshal(raw, &m); err != nil {
+		return nil, err
+	}
+
+	res := make(map[string]string, len(m))
+	for k, v := range m {
+		res[k] = fmt.Sprintf("%v", v)
+	}
+	return res, nil
+}
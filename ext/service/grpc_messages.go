@@ -0,0 +1,100 @@
+package service
+
+import (
+	"github.com/golang/protobuf/proto"
+	structpb "github.com/golang/protobuf/ptypes/struct"
+)
+
+//Message types of proto/keyauth.proto, of the grpc.health.v1 API and of the subset of the Envoy
+//ext_authz API used by the gateway. They are kept by hand, so the Envoy protos are not required.
+//Unknown fields are skipped when decoding, so both the v2 and v3 Authorization services are supported
+
+type keyValidateRequest struct {
+	Fields map[string]string `protobuf:"bytes,1,rep,name=fields,proto3" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"`
+}
+
+func (m *keyValidateRequest) Reset()         { *m = keyValidateRequest{} }
+func (m *keyValidateRequest) String() string { return proto.CompactTextString(m) }
+func (*keyValidateRequest) ProtoMessage()    {}
+
+type keyValidateResponse struct {
+	Result *structpb.Struct `protobuf:"bytes,1,opt,name=result,proto3"`
+}
+
+func (m *keyValidateResponse) Reset()         { *m = keyValidateResponse{} }
+func (m *keyValidateResponse) String() string { return proto.CompactTextString(m) }
+func (*keyValidateResponse) ProtoMessage()    {}
+
+type checkRequest struct {
+	Attributes *attributeContext `protobuf:"bytes,1,opt,name=attributes,proto3"`
+}
+
+func (m *checkRequest) Reset()         { *m = checkRequest{} }
+func (m *checkRequest) String() string { return proto.CompactTextString(m) }
+func (*checkRequest) ProtoMessage()    {}
+
+type attributeContext struct {
+	Request           *attributeRequest `protobuf:"bytes,4,opt,name=request,proto3"`
+	ContextExtensions map[string]string `protobuf:"bytes,10,rep,name=context_extensions,json=contextExtensions,proto3" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"`
+}
+
+func (m *attributeContext) Reset()         { *m = attributeContext{} }
+func (m *attributeContext) String() string { return proto.CompactTextString(m) }
+func (*attributeContext) ProtoMessage()    {}
+
+type attributeRequest struct {
+	HTTP *httpRequest `protobuf:"bytes,2,opt,name=http,proto3"`
+}
+
+func (m *attributeRequest) Reset()         { *m = attributeRequest{} }
+func (m *attributeRequest) String() string { return proto.CompactTextString(m) }
+func (*attributeRequest) ProtoMessage()    {}
+
+type httpRequest struct {
+	ID       string            `protobuf:"bytes,1,opt,name=id,proto3"`
+	Method   string            `protobuf:"bytes,2,opt,name=method,proto3"`
+	Headers  map[string]string `protobuf:"bytes,3,rep,name=headers,proto3" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"`
+	Path     string            `protobuf:"bytes,4,opt,name=path,proto3"`
+	Host     string            `protobuf:"bytes,5,opt,name=host,proto3"`
+	Size     int64             `protobuf:"varint,9,opt,name=size,proto3"`
+	Protocol string            `protobuf:"bytes,10,opt,name=protocol,proto3"`
+	Body     string            `protobuf:"bytes,11,opt,name=body,proto3"`
+}
+
+func (m *httpRequest) Reset()         { *m = httpRequest{} }
+func (m *httpRequest) String() string { return proto.CompactTextString(m) }
+func (*httpRequest) ProtoMessage()    {}
+
+type checkResponse struct {
+	Status *rpcStatus `protobuf:"bytes,1,opt,name=status,proto3"`
+}
+
+func (m *checkResponse) Reset()         { *m = checkResponse{} }
+func (m *checkResponse) String() string { return proto.CompactTextString(m) }
+func (*checkResponse) ProtoMessage()    {}
+
+type rpcStatus struct {
+	Code    int32  `protobuf:"varint,1,opt,name=code,proto3"`
+	Message string `protobuf:"bytes,2,opt,name=message,proto3"`
+}
+
+func (m *rpcStatus) Reset()         { *m = rpcStatus{} }
+func (m *rpcStatus) String() string { return proto.CompactTextString(m) }
+func (*rpcStatus) ProtoMessage()    {}
+
+//healthCheckRequest and healthCheckResponse messages of the standard grpc.health.v1 Health service
+type healthCheckRequest struct {
+	Service string `protobuf:"bytes,1,opt,name=service,proto3"`
+}
+
+func (m *healthCheckRequest) Reset()         { *m = healthCheckRequest{} }
+func (m *healthCheckRequest) String() string { return proto.CompactTextString(m) }
+func (*healthCheckRequest) ProtoMessage()    {}
+
+type healthCheckResponse struct {
+	Status int32 `protobuf:"varint,1,opt,name=status,proto3"`
+}
+
+func (m *healthCheckResponse) Reset()         { *m = healthCheckResponse{} }
+func (m *healthCheckResponse) String() string { return proto.CompactTextString(m) }
+func (*healthCheckResponse) ProtoMessage()    {}
@@ -0,0 +1,155 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"testing"
+
+	cache "github.com/devopsfaith/krakend-ce/ext/cache"
+	"github.com/golang/protobuf/jsonpb"
+	structpb "github.com/golang/protobuf/ptypes/struct"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+type opaInput struct {
+	Method string `json:"method"`
+	User   string `json:"user"`
+}
+
+func (o opaInput) Hash() [32]byte {
+	return testInput(o.Method + o.User).Hash()
+}
+
+func (o opaInput) HTTPAttributes() (string, string) {
+	return o.Method, "/echo"
+}
+
+type testKey map[string]interface{}
+
+func (k *testKey) Hash() [32]byte {
+	return testInput(fmt.Sprintf("%v", *k)).Hash()
+}
+
+func unaryHandler(f func(ctx context.Context, dec func(interface{}) error) (interface{}, error)) func(interface{}, context.Context, func(interface{}) error, grpc.UnaryServerInterceptor) (interface{}, error) {
+	return func(_ interface{}, ctx context.Context, dec func(interface{}) error, _ grpc.UnaryServerInterceptor) (interface{}, error) {
+		return f(ctx, dec)
+	}
+}
+
+func newGRPCServer(t *testing.T, desc *grpc.ServiceDesc) (string, func()) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := grpc.NewServer()
+	srv.RegisterService(desc, struct{}{})
+	go srv.Serve(ln)
+	return ln.Addr().String(), srv.Stop
+}
+
+func TestGRPCKeyAuth(t *testing.T) {
+	calls := 0
+	addr, stop := newGRPCServer(t, &grpc.ServiceDesc{
+		ServiceName: "krakend.keyauth.v1.KeyAuth",
+		HandlerType: (*interface{})(nil),
+		Methods: []grpc.MethodDesc{{
+			MethodName: "Validate",
+			Handler: unaryHandler(func(ctx context.Context, dec func(interface{}) error) (interface{}, error) {
+				calls++
+				var in keyValidateRequest
+				if err := dec(&in); err != nil {
+					return nil, err
+				}
+				md, _ := metadata.FromIncomingContext(ctx)
+				assert.Equal(t, []string{"req-1"}, md.Get("x-request-id"))
+				if in.Fields["key"] != "valid" {
+					return nil, status.Error(codes.Unauthenticated, "invalid key")
+				}
+				res := &structpb.Struct{}
+				jsonpb.UnmarshalString(`{"id":"partner1"}`, res)
+				return &keyValidateResponse{Result: res}, nil
+			}),
+		}},
+	})
+	defer stop()
+
+	ka, err := NewGRPCKeyAuth(GRPCConfig{
+		Address: addr,
+		Cache:   cache.NewMemoryCache(0),
+		Client:  DefaultClientConfig(),
+		TagPath: "result.id",
+	})
+	assert.Nil(t, err)
+
+	ctx := WithRequestID(context.Background(), "req-1")
+	rsp, err := ka.Validate(ctx, &testKey{"key": "valid"})
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]interface{}{"result": map[string]interface{}{"id": "partner1"}}, rsp)
+
+	_, err = ka.Validate(ctx, &testKey{"key": "valid"})
+	assert.Nil(t, err)
+	assert.Equal(t, 1, calls, "Key details should be cached")
+
+	_, err = ka.Validate(ctx, &testKey{"key": "other"})
+	assert.NotNil(t, err)
+	assert.False(t, IsUnavailable(err))
+}
+
+func TestGRPCOPA(t *testing.T) {
+	addr, stop := newGRPCServer(t, &grpc.ServiceDesc{
+		ServiceName: "envoy.service.auth.v3.Authorization",
+		HandlerType: (*interface{})(nil),
+		Methods: []grpc.MethodDesc{{
+			MethodName: "Check",
+			Handler: unaryHandler(func(ctx context.Context, dec func(interface{}) error) (interface{}, error) {
+				var in checkRequest
+				if err := dec(&in); err != nil {
+					return nil, err
+				}
+				assert.Equal(t, "opa.test", in.Attributes.ContextExtensions["package"])
+				assert.Equal(t, "/echo", in.Attributes.Request.HTTP.Path)
+				if in.Attributes.Request.HTTP.Method != "GET" {
+					return &checkResponse{Status: &rpcStatus{Code: int32(codes.PermissionDenied)}}, nil
+				}
+				return &checkResponse{Status: &rpcStatus{}}, nil
+			}),
+		}},
+	})
+	defer stop()
+
+	opa, err := NewGRPCOPA(GRPCConfig{
+		Address: addr,
+		Cache:   cache.NewMemoryCache(0),
+		Client:  DefaultClientConfig(),
+	})
+	assert.Nil(t, err)
+
+	res, err := opa.Evaluate(context.Background(), "opa.test", "allow", opaInput{Method: "GET", User: "user1"})
+	assert.Nil(t, err)
+	assert.True(t, res)
+
+	res, err = opa.Evaluate(context.Background(), "opa.test", "allow", opaInput{Method: "POST", User: "user1"})
+	assert.Nil(t, err)
+	assert.False(t, res)
+}
+
+func TestGRPCUnavailable(t *testing.T) {
+	ln, _ := net.Listen("tcp", "127.0.0.1:0")
+	addr := ln.Addr().String()
+	ln.Close()
+
+	opa, err := NewGRPCOPA(GRPCConfig{
+		Address: addr,
+		Cache:   cache.NewMemoryCache(0),
+		Client:  DefaultClientConfig(),
+	})
+	assert.Nil(t, err)
+
+	_, err = opa.Evaluate(context.Background(), "opa.test", "allow", opaInput{Method: "GET"})
+	assert.True(t, IsUnavailable(err))
+}
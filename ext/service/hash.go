@@ -0,0 +1,44 @@
+package service
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+)
+
+//Hash canonical cache key of v, the SHA-256 of its JSON serialization where the object keys
+//are sorted. When fields are given, only those dotted paths (e.g. input.payload.sub) take part
+//in the key. Values that can not be serialized fall back to their Go syntax representation
+func Hash(v interface{}, fields ...string) [32]byte {
+	raw, err := canonicalJSON(v, fields)
+	if err != nil {
+		return sha256.Sum256([]byte(fmt.Sprintf("%#v", v)))
+	}
+	return sha256.Sum256(raw)
+}
+
+//canonicalJSON relies on encoding/json writing the map keys in sorted order, so equal values
+//always produce the same bytes whatever the map iteration order
+func canonicalJSON(v interface{}, fields []string) ([]byte, error) {
+	raw, err := json.Marshal(v)
+	if err != nil || len(fields) == 0 {
+		return raw, err
+	}
+
+	var doc map[string]interface{}
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+	if err := dec.Decode(&doc); err != nil {
+		return nil, err
+	}
+
+	sub := make(map[string]interface{}, len(fields))
+	for _, f := range fields {
+		if val, ok := responseValue(f, doc); ok {
+			sub[f] = val
+		}
+	}
+
+	return json.Marshal(sub)
+}
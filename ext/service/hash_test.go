@@ -0,0 +1,61 @@
+package service
+
+import (
+	"encoding/hex"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHashStable(t *testing.T) {
+	build := func(order []string) map[string]interface{} {
+		payload := map[string]interface{}{}
+		for _, k := range order {
+			payload[k] = map[string]interface{}{"key": k, "tags": []string{"a", "b"}}
+		}
+		return map[string]interface{}{
+			"payload": payload,
+			"header":  http.Header{"X-B": {"2"}, "X-A": {"1", "3"}},
+		}
+	}
+
+	first := Hash(build([]string{"a", "b", "c", "d", "e", "f"}))
+	for i := 0; i < 100; i++ {
+		assert.Equal(t, first, Hash(build([]string{"f", "e", "d", "c", "b", "a"})))
+	}
+}
+
+func TestHashGolden(t *testing.T) {
+	//sha256 of {"a":["x",{"y":null,"z":true}],"b":1}
+	h := Hash(map[string]interface{}{"b": 1, "a": []interface{}{"x", map[string]interface{}{"z": true, "y": nil}}})
+	assert.Equal(t, "802260f1752b85f4a9b24a7f0b89941faac51e2fbc373312b9c2662ee81ee798", hex.EncodeToString(h[:]))
+}
+
+func TestHashDistinct(t *testing.T) {
+	assert.NotEqual(t,
+		Hash(map[string]interface{}{"a": "1", "b": "2"}),
+		Hash(map[string]interface{}{"a": "12"}))
+	assert.NotEqual(t,
+		Hash(map[string]interface{}{"a": []string{"1", "2"}}),
+		Hash(map[string]interface{}{"a": []string{"2", "1"}}))
+	assert.NotEqual(t,
+		Hash(map[string]interface{}{"a": "1"}),
+		Hash(map[string]interface{}{"a": 1}))
+}
+
+func TestHashFields(t *testing.T) {
+	a := map[string]interface{}{"input": map[string]interface{}{"method": "GET", "payload": map[string]interface{}{"sub": "user1", "jti": "1"}}}
+	b := map[string]interface{}{"input": map[string]interface{}{"method": "GET", "payload": map[string]interface{}{"sub": "user1", "jti": "2"}}}
+	c := map[string]interface{}{"input": map[string]interface{}{"method": "GET", "payload": map[string]interface{}{"sub": "user2", "jti": "1"}}}
+
+	assert.NotEqual(t, Hash(a), Hash(b))
+	assert.Equal(t, Hash(a, "input.method", "input.payload.sub"), Hash(b, "input.method", "input.payload.sub"))
+	assert.NotEqual(t, Hash(a, "input.method", "input.payload.sub"), Hash(c, "input.method", "input.payload.sub"))
+	assert.NotEqual(t, Hash(a, "input.payload.sub"), Hash(a, "input.payload.jti"))
+}
+
+func TestHashUnsupported(t *testing.T) {
+	ch := make(chan int)
+	assert.Equal(t, Hash(map[string]interface{}{"c": ch}), Hash(map[string]interface{}{"c": ch}))
+}
@@ -1,7 +1,7 @@
 package service
 
 import (
-	"time"
+	"context"
 
 	cache "github.com/devopsfaith/krakend-ce/ext/cache"
 )
@@ -13,7 +13,7 @@ type KeyAuthResponse struct {
 
 //KeyAuth keyAuth service interface
 type KeyAuth interface {
-	Validate(key Cacheable) (map[string]interface{}, error)
+	Validate(ctx context.Context, key Cacheable) (map[string]interface{}, error)
 }
 
 //HTTPKeyAuth http keyAuth service
@@ -21,6 +21,10 @@ type HTTPKeyAuth struct {
 	address  string
 	basePath string
 	cache    cache.Local
+	ttlPath  string
+	tagPath  string
+	client   *Client
+	breaker  *breaker
 }
 
 //DummyKeyAuth dummy key auth service
@@ -30,20 +34,15 @@ type DummyKeyAuth struct {
 }
 
 //NewHTTPKeyAuth create instance of http keyAuth service
-func NewHTTPKeyAuth(address, basePath string, cacheDuration, cacheSize int) *HTTPKeyAuth {
-	var c cache.Local
-	if cacheSize > 0 {
-		c, _ = cache.NewLRU(cacheSize)
-	}
-
-	if c == nil {
-		c = cache.NewMemoryCache(time.Duration(cacheDuration) * time.Second)
-	}
-
+func NewHTTPKeyAuth(cfg HTTPConfig) *HTTPKeyAuth {
 	return &HTTPKeyAuth{
-		address:  address,
-		basePath: basePath,
-		cache:    c,
+		address:  cfg.Address,
+		basePath: cfg.BasePath,
+		cache:    cfg.Cache,
+		ttlPath:  cfg.TTLPath,
+		tagPath:  cfg.TagPath,
+		client:   cfg.client(),
+		breaker:  newBreaker("keyauth:"+cfg.Address+cfg.BasePath, cfg.Breaker),
 	}
 }
 
@@ -53,22 +52,32 @@ func NewDummyKeyAuth() *DummyKeyAuth {
 }
 
 //Validate validate key api
-func (h *HTTPKeyAuth) Validate(key Cacheable) (map[string]interface{}, error) {
+func (h *HTTPKeyAuth) Validate(ctx context.Context, key Cacheable) (res map[string]interface{}, err error) {
+	ctx, c := startCall(ctx, "keyauth", TransportHTTP, "keyauth.validate")
+	defer func() { c.end(err == nil, err) }()
+
 	hs := key.Hash()
 
 	if rsp, ok := h.cache.Get(hs); ok {
-		return rsp.(map[string]interface{}), nil
+		if res, ok := rsp.(map[string]interface{}); ok {
+			c.hit()
+			return res, nil
+		}
 	}
 
 	var rsp map[string]interface{}
-	if err := post(h.address, h.basePath, key, &rsp); err != nil {
+	if err := h.breaker.call(func() error {
+		return h.client.Post(ctx, h.address+h.basePath, key, &rsp)
+	}); err != nil {
 		return nil, err
 	}
 
+	cache.SetWithTags(h.cache, hs, rsp, responseTTL(h.ttlPath, rsp), responseTags(h.tagPath, rsp)...)
+
 	return rsp, nil
 }
 
 //Validate validate key api
-func (d *DummyKeyAuth) Validate(key Cacheable) (map[string]interface{}, error) {
+func (d *DummyKeyAuth) Validate(ctx context.Context, key Cacheable) (map[string]interface{}, error) {
 	return d.Result, d.Error
 }
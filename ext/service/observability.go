@@ -0,0 +1,105 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"go.opencensus.io/plugin/ochttp"
+	"go.opencensus.io/stats"
+	"go.opencensus.io/stats/view"
+	"go.opencensus.io/tag"
+	"go.opencensus.io/trace"
+)
+
+const (
+	resultAllow       = "allow"
+	resultDeny        = "deny"
+	resultError       = "error"
+	resultUnavailable = "unavailable"
+)
+
+var (
+	serviceKey   = tag.MustNewKey("krakend.io/ext/service")
+	transportKey = tag.MustNewKey("krakend.io/ext/transport")
+	resultKey    = tag.MustNewKey("krakend.io/ext/result")
+	cacheKey     = tag.MustNewKey("krakend.io/ext/cache")
+
+	callLatency = stats.Float64("krakend.io/ext/latency", "Latency of the ext service calls", stats.UnitMilliseconds)
+
+	//OpenCensusViews views exposing the latency distribution and the number of the ext service calls
+	OpenCensusViews = []*view.View{
+		{
+			Name:        "krakend.io/ext/latency",
+			Description: "Latency distribution of the ext service calls",
+			Measure:     callLatency,
+			Aggregation: ochttp.DefaultLatencyDistribution,
+			TagKeys:     []tag.Key{serviceKey, transportKey, resultKey, cacheKey},
+		},
+		{
+			Name:        "krakend.io/ext/calls",
+			Description: "Number of ext service calls",
+			Measure:     callLatency,
+			Aggregation: view.Count(),
+			TagKeys:     []tag.Key{serviceKey, transportKey, resultKey, cacheKey},
+		},
+	}
+)
+
+type call struct {
+	span      *trace.Span
+	start     time.Time
+	service   string
+	transport string
+	cacheHit  bool
+}
+
+//startCall start the span of a service call, child of the span found in the context
+func startCall(ctx context.Context, service, transport, name string, attrs ...trace.Attribute) (context.Context, *call) {
+	ctx, span := trace.StartSpan(ctx, name, trace.WithSpanKind(trace.SpanKindClient))
+	span.AddAttributes(append(attrs, trace.StringAttribute("transport", transport))...)
+	return ctx, &call{
+		span:      span,
+		start:     time.Now(),
+		service:   service,
+		transport: transport,
+	}
+}
+
+//hit flag the call as answered by the cache
+func (c *call) hit() {
+	c.cacheHit = true
+}
+
+//end close the span and record the call latency tagged with its result
+func (c *call) end(allowed bool, err error) {
+	result := resultAllow
+	switch {
+	case IsUnavailable(err):
+		result = resultUnavailable
+		c.span.SetStatus(trace.Status{Code: trace.StatusCodeUnavailable, Message: err.Error()})
+	case err != nil:
+		result = resultError
+		c.span.SetStatus(trace.Status{Code: trace.StatusCodeUnknown, Message: err.Error()})
+	case !allowed:
+		result = resultDeny
+		c.span.SetStatus(trace.Status{Code: trace.StatusCodePermissionDenied, Message: resultDeny})
+	}
+
+	cached := "miss"
+	if c.cacheHit {
+		cached = "hit"
+	}
+
+	c.span.AddAttributes(
+		trace.BoolAttribute("cache.hit", c.cacheHit),
+		trace.StringAttribute("result", result),
+	)
+	c.span.End()
+
+	stats.RecordWithTags(context.Background(), []tag.Mutator{
+		tag.Upsert(serviceKey, c.service),
+		tag.Upsert(transportKey, c.transport),
+		tag.Upsert(resultKey, result),
+		tag.Upsert(cacheKey, cached),
+	}, callLatency.M(float64(time.Since(c.start))/float64(time.Millisecond)))
+}
@@ -0,0 +1,132 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	cache "github.com/devopsfaith/krakend-ce/ext/cache"
+	"github.com/stretchr/testify/assert"
+	"go.opencensus.io/stats/view"
+	"go.opencensus.io/trace"
+)
+
+type spanRecorder struct {
+	sync.Mutex
+	spans []*trace.SpanData
+}
+
+func (s *spanRecorder) ExportSpan(sd *trace.SpanData) {
+	s.Lock()
+	s.spans = append(s.spans, sd)
+	s.Unlock()
+}
+
+func (s *spanRecorder) find(name string) []*trace.SpanData {
+	s.Lock()
+	defer s.Unlock()
+	var res []*trace.SpanData
+	for _, sd := range s.spans {
+		if sd.Name == name {
+			res = append(res, sd)
+		}
+	}
+	return res
+}
+
+func TestHTTPOPATracing(t *testing.T) {
+	rec := &spanRecorder{}
+	trace.RegisterExporter(rec)
+	defer trace.UnregisterExporter(rec)
+
+	var traceHeader string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceHeader = r.Header.Get("X-B3-Traceid")
+		json.NewEncoder(w).Encode(map[string]interface{}{"result": true})
+	}))
+	defer srv.Close()
+
+	opa := NewHTTPOPA(HTTPConfig{
+		Address:  srv.URL,
+		BasePath: "/v1/data/",
+		Cache:    cache.NewMemoryCache(0),
+	})
+
+	ctx, parent := trace.StartSpan(context.Background(), "request", trace.WithSampler(trace.AlwaysSample()))
+	_, err := opa.Evaluate(ctx, "opa.test", "allow", testInput("traced"))
+	assert.Nil(t, err)
+	_, err = opa.Evaluate(ctx, "opa.test", "allow", testInput("traced"))
+	assert.Nil(t, err)
+	parent.End()
+
+	assert.Equal(t, parent.SpanContext().TraceID.String(), traceHeader)
+
+	spans := rec.find("opa.evaluate")
+	assert.Len(t, spans, 2)
+	for _, sd := range spans {
+		assert.Equal(t, parent.SpanContext().SpanID, sd.ParentSpanID)
+		assert.Equal(t, "opa.test", sd.Attributes["opa.package"])
+		assert.Equal(t, "allow", sd.Attributes["opa.directive"])
+		assert.Equal(t, resultAllow, sd.Attributes["result"])
+	}
+	assert.Equal(t, false, spans[0].Attributes["cache.hit"])
+	assert.Equal(t, true, spans[1].Attributes["cache.hit"])
+}
+
+func TestKeyAuthSpanStatus(t *testing.T) {
+	rec := &spanRecorder{}
+	trace.RegisterExporter(rec)
+	defer trace.UnregisterExporter(rec)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	cfg := DefaultClientConfig()
+	cfg.Retries = 0
+	client, _ := NewClient(cfg)
+	ka := NewHTTPKeyAuth(HTTPConfig{Address: srv.URL, Cache: cache.NewMemoryCache(0), Client: client})
+
+	ctx, parent := trace.StartSpan(context.Background(), "request", trace.WithSampler(trace.AlwaysSample()))
+	_, err := ka.Validate(ctx, testInput("key1"))
+	parent.End()
+	assert.True(t, IsUnavailable(err))
+
+	spans := rec.find("keyauth.validate")
+	assert.Len(t, spans, 1)
+	assert.Equal(t, int32(trace.StatusCodeUnavailable), spans[0].Status.Code)
+	assert.Equal(t, resultUnavailable, spans[0].Attributes["result"])
+}
+
+func TestLatencyViews(t *testing.T) {
+	if err := view.Register(OpenCensusViews...); err != nil {
+		t.Fatal(err)
+	}
+	defer view.Unregister(OpenCensusViews...)
+
+	for _, allowed := range []bool{true, false, true} {
+		_, c := startCall(context.Background(), "extauthz", TransportHTTP, "extauthz.check")
+		c.end(allowed, nil)
+	}
+
+	rows, err := view.RetrieveData("krakend.io/ext/calls")
+	assert.Nil(t, err)
+
+	counts := map[string]int64{}
+	for _, row := range rows {
+		for _, tg := range row.Tags {
+			if tg.Key == resultKey {
+				counts[tg.Value] = row.Data.(*view.CountData).Value
+			}
+		}
+	}
+	assert.Equal(t, map[string]int64{resultAllow: 2, resultDeny: 1}, counts)
+
+	rows, err = view.RetrieveData("krakend.io/ext/latency")
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(rows))
+}
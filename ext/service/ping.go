@@ -0,0 +1,66 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	grpcHealthCheckMethod = "/grpc.health.v1.Health/Check"
+	grpcHealthServing     = 1
+)
+
+//Pinger services able to report whether they are reachable, used by the readiness checks
+type Pinger interface {
+	Ping(ctx context.Context) error
+}
+
+//Ping check the policy service is reachable
+func (h *HTTPOPA) Ping(ctx context.Context) error {
+	return h.client.ping(ctx, h.address)
+}
+
+//Ping check the keyAuth service is reachable
+func (h *HTTPKeyAuth) Ping(ctx context.Context) error {
+	return h.client.ping(ctx, h.address)
+}
+
+//Ping check the policy service is reachable
+func (g *GRPCOPA) Ping(ctx context.Context) error {
+	return g.conn.ping(ctx)
+}
+
+//Ping check the keyAuth service is reachable
+func (g *GRPCKeyAuth) Ping(ctx context.Context) error {
+	return g.conn.ping(ctx)
+}
+
+//ping any answer of the server counts, only the connection errors are reported
+func (c *Client) ping(ctx context.Context, url string) error {
+	if _, err := c.send(ctx, http.MethodHead, url, nil, nil); err != nil {
+		return &UnavailableError{Err: err}
+	}
+	return nil
+}
+
+//ping call the standard gRPC health service. Servers not implementing it are reachable as long
+//as they answer
+func (c *grpcConn) ping(ctx context.Context) error {
+	var out healthCheckResponse
+	err := c.conn.Invoke(ctx, grpcHealthCheckMethod, &healthCheckRequest{}, &out)
+	switch status.Code(err) {
+	case codes.OK:
+		if out.Status != grpcHealthServing {
+			return &UnavailableError{Err: fmt.Errorf("service not serving, status %d", out.Status)}
+		}
+		return nil
+	case codes.Unavailable, codes.DeadlineExceeded, codes.Canceled:
+		return &UnavailableError{Err: err}
+	default:
+		return nil
+	}
+}
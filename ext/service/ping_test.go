@@ -0,0 +1,82 @@
+package service
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	cache "github.com/devopsfaith/krakend-ce/ext/cache"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+)
+
+func TestHTTPPing(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodHead, r.Method)
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}))
+
+	opa := NewHTTPOPA(HTTPConfig{Address: srv.URL, BasePath: "/v1/data/"})
+	keyAuth := NewHTTPKeyAuth(HTTPConfig{Address: srv.URL, BasePath: "/keys"})
+	assert.Nil(t, opa.Ping(context.Background()), "Any answer should count as reachable")
+	assert.Nil(t, keyAuth.Ping(context.Background()))
+
+	srv.Close()
+	err := opa.Ping(context.Background())
+	assert.True(t, IsUnavailable(err))
+}
+
+func TestGRPCPing(t *testing.T) {
+	status := int32(grpcHealthServing)
+	addr, stop := newGRPCServer(t, &grpc.ServiceDesc{
+		ServiceName: "grpc.health.v1.Health",
+		HandlerType: (*interface{})(nil),
+		Methods: []grpc.MethodDesc{{
+			MethodName: "Check",
+			Handler: unaryHandler(func(ctx context.Context, dec func(interface{}) error) (interface{}, error) {
+				var in healthCheckRequest
+				if err := dec(&in); err != nil {
+					return nil, err
+				}
+				return &healthCheckResponse{Status: atomic.LoadInt32(&status)}, nil
+			}),
+		}},
+	})
+	defer stop()
+
+	opa, err := NewGRPCOPA(GRPCConfig{Address: addr, Cache: cache.NewMemoryCache(0), Client: DefaultClientConfig()})
+	assert.Nil(t, err)
+	assert.Nil(t, opa.Ping(context.Background()))
+
+	atomic.StoreInt32(&status, 2)
+	assert.True(t, IsUnavailable(opa.Ping(context.Background())))
+}
+
+func TestGRPCPingUnimplemented(t *testing.T) {
+	addr, stop := newGRPCServer(t, &grpc.ServiceDesc{
+		ServiceName: "krakend.keyauth.v1.KeyAuth",
+		HandlerType: (*interface{})(nil),
+	})
+	defer stop()
+
+	keyAuth, err := NewGRPCKeyAuth(GRPCConfig{Address: addr, Cache: cache.NewMemoryCache(0), Client: DefaultClientConfig()})
+	assert.Nil(t, err)
+	assert.Nil(t, keyAuth.Ping(context.Background()), "Servers without the health service should be reachable")
+}
+
+func TestGRPCPingUnavailable(t *testing.T) {
+	ln, _ := net.Listen("tcp", "127.0.0.1:0")
+	addr := ln.Addr().String()
+	ln.Close()
+
+	keyAuth, err := NewGRPCKeyAuth(GRPCConfig{Address: addr, Cache: cache.NewMemoryCache(0), Client: DefaultClientConfig()})
+	assert.Nil(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	assert.True(t, IsUnavailable(keyAuth.Ping(ctx)))
+}
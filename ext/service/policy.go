@@ -1,10 +1,12 @@
 package service
 
 import (
+	"context"
+	"errors"
 	"strings"
-	"time"
 
 	cache "github.com/devopsfaith/krakend-ce/ext/cache"
+	"go.opencensus.io/trace"
 )
 
 //Cacheable cacheable object
@@ -14,7 +16,7 @@ type Cacheable interface {
 
 //Policy policy service interface
 type Policy interface {
-	Evaluate(pkg, directive string, data Cacheable) (bool, error)
+	Evaluate(ctx context.Context, pkg, directive string, data Cacheable) (bool, error)
 }
 
 //HTTPOPA http OPA service
@@ -22,6 +24,9 @@ type HTTPOPA struct {
 	address  string
 	basePath string
 	cache    cache.Local
+	ttlPath  string
+	client   *Client
+	breaker  *breaker
 }
 
 //DummyOPA dummy OPA service
@@ -36,20 +41,14 @@ type Response struct {
 }
 
 //NewHTTPOPA create new http OPA service instance
-func NewHTTPOPA(address, basePath string, cacheDuration, cacheSize int) *HTTPOPA {
-	var c cache.Local
-	if cacheSize > 0 {
-		c, _ = cache.NewLRU(cacheSize)
-	}
-
-	if c == nil {
-		c = cache.NewMemoryCache(time.Duration(cacheDuration) * time.Second)
-	}
-
+func NewHTTPOPA(cfg HTTPConfig) *HTTPOPA {
 	return &HTTPOPA{
-		address:  address,
-		basePath: basePath,
-		cache:    c,
+		address:  cfg.Address,
+		basePath: cfg.BasePath,
+		cache:    cfg.Cache,
+		ttlPath:  cfg.TTLPath,
+		client:   cfg.client(),
+		breaker:  newBreaker("opa:"+cfg.Address+cfg.BasePath, cfg.Breaker),
 	}
 }
 
@@ -58,27 +57,47 @@ func NewDummyOPA() *DummyOPA {
 	return &DummyOPA{}
 }
 
+//policyAttributes span attributes of a policy evaluation
+func policyAttributes(pkg, directive string) []trace.Attribute {
+	return []trace.Attribute{
+		trace.StringAttribute("opa.package", pkg),
+		trace.StringAttribute("opa.directive", directive),
+	}
+}
+
 //Evaluate evaluate input request against policy
-func (d *DummyOPA) Evaluate(pkg, directive string, data Cacheable) (bool, error) {
+func (d *DummyOPA) Evaluate(ctx context.Context, pkg, directive string, data Cacheable) (bool, error) {
 	return d.Result, d.Error
 }
 
 //Evaluate evaluate input request against policy
-func (h *HTTPOPA) Evaluate(pkg, directive string, data Cacheable) (bool, error) {
+func (h *HTTPOPA) Evaluate(ctx context.Context, pkg, directive string, data Cacheable) (res bool, err error) {
+	ctx, c := startCall(ctx, "opa", TransportHTTP, "opa.evaluate", policyAttributes(pkg, directive)...)
+	defer func() { c.end(res, err) }()
 
 	hs := data.Hash()
 
 	if rsp, ok := h.cache.Get(hs); ok {
-		return rsp.(bool), nil
+		if res, ok := rsp.(bool); ok {
+			c.hit()
+			return res, nil
+		}
 	}
 
 	path := h.basePath + strings.ReplaceAll(pkg, ".", "/") + "/" + directive
-	var rsp Response
-	if err := post(h.address, path, data, &rsp); err != nil {
+	var rsp map[string]interface{}
+	if err := h.breaker.call(func() error {
+		return h.client.Post(ctx, h.address+path, data, &rsp)
+	}); err != nil {
 		return false, err
 	}
 
-	h.cache.Set(hs, rsp.Result)
+	res, ok := rsp["result"].(bool)
+	if _, exists := rsp["result"]; exists && !ok {
+		return false, errors.New("Invalid policy result")
+	}
+
+	cache.SetWithTTL(h.cache, hs, res, responseTTL(h.ttlPath, rsp))
 
-	return rsp.Result, nil
+	return res, nil
 }
@@ -0,0 +1,73 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	cache "github.com/devopsfaith/krakend-ce/ext/cache"
+	"github.com/stretchr/testify/assert"
+)
+
+type testInput string
+
+func (t testInput) Hash() [32]byte {
+	return sha256.Sum256([]byte(t))
+}
+
+func TestHTTPOPA(t *testing.T) {
+	calls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		assert.Equal(t, "/v1/data/opa/test/allow", r.URL.Path)
+		json.NewEncoder(w).Encode(map[string]interface{}{"result": true, "ttl": 60})
+	}))
+	defer srv.Close()
+
+	c, _ := cache.NewLRUWithTTL(10, time.Millisecond)
+	opa := NewHTTPOPA(HTTPConfig{
+		Address:  srv.URL,
+		BasePath: "/v1/data/",
+		Cache:    c,
+		TTLPath:  "ttl",
+	})
+
+	res, err := opa.Evaluate(context.Background(), "opa.test", "allow", testInput("user1"))
+	assert.Nil(t, err)
+	assert.True(t, res)
+
+	time.Sleep(5 * time.Millisecond)
+	res, err = opa.Evaluate(context.Background(), "opa.test", "allow", testInput("user1"))
+	assert.Nil(t, err)
+	assert.True(t, res)
+	assert.Equal(t, 1, calls, "Decision should be cached with the response TTL")
+}
+
+func TestHTTPOPAInvalidResult(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"result":{"allow":true}}`))
+	}))
+	defer srv.Close()
+
+	opa := NewHTTPOPA(HTTPConfig{Address: srv.URL, Cache: cache.NewMemoryCache(0)})
+
+	res, err := opa.Evaluate(context.Background(), "opa.test", "allow", testInput("user1"))
+	assert.NotNil(t, err)
+	assert.False(t, res)
+}
+
+func TestResponseTTL(t *testing.T) {
+	rsp := map[string]interface{}{
+		"result": map[string]interface{}{"ttl": float64(30)},
+		"ttl":    "1.5",
+	}
+
+	assert.Equal(t, 30*time.Second, responseTTL("result.ttl", rsp))
+	assert.Equal(t, 1500*time.Millisecond, responseTTL("ttl", rsp))
+	assert.Equal(t, time.Duration(0), responseTTL("result.missing", rsp))
+	assert.Equal(t, time.Duration(0), responseTTL("", rsp))
+}
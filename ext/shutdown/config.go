@@ -0,0 +1,78 @@
+package shutdown
+
+import (
+	"errors"
+	"time"
+
+	"github.com/devopsfaith/krakend-ce/ext/lint"
+	"github.com/devopsfaith/krakend/config"
+)
+
+const (
+	namespace = "github_com/sahalzain/krakend-shutdown"
+
+	defaultDrainTimeout = 30 * time.Second
+	defaultFlushTimeout = 5 * time.Second
+)
+
+//Linter strict validation of the config block
+var Linter = lint.Linter{
+	Namespace: namespace,
+	Scope:     lint.ScopeService,
+	Fields: []lint.Field{
+		{Name: "grace_period", Kind: lint.KindDuration, Check: lint.Func(nonNegative)},
+		{Name: "drain_timeout", Kind: lint.KindDuration, Check: lint.Func(nonNegative)},
+		{Name: "flush_timeout", Kind: lint.KindDuration, Check: lint.Func(nonNegative)},
+	},
+}
+
+//xtraConfig the shutdown sequence timings
+type xtraConfig struct {
+	//GracePeriod time the gateway keeps serving after flipping the readiness, so the load balancers notice it
+	GracePeriod time.Duration
+	//DrainTimeout maximum time waiting for the in-flight requests once the listener is closed
+	DrainTimeout time.Duration
+	//FlushTimeout maximum time waiting for the exporters and the service discovery deregistration
+	FlushTimeout time.Duration
+}
+
+func configGetter(cfg config.ExtraConfig) xtraConfig {
+	conf := xtraConfig{
+		DrainTimeout: defaultDrainTimeout,
+		FlushTimeout: defaultFlushTimeout,
+	}
+
+	v, ok := cfg[namespace]
+	if !ok {
+		return conf
+	}
+	tmp, ok := v.(map[string]interface{})
+	if !ok {
+		return conf
+	}
+
+	conf.GracePeriod = parseDuration(tmp["grace_period"], conf.GracePeriod)
+	conf.DrainTimeout = parseDuration(tmp["drain_timeout"], conf.DrainTimeout)
+	conf.FlushTimeout = parseDuration(tmp["flush_timeout"], conf.FlushTimeout)
+
+	return conf
+}
+
+func parseDuration(v interface{}, def time.Duration) time.Duration {
+	s, ok := v.(string)
+	if !ok {
+		return def
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d < 0 {
+		return def
+	}
+	return d
+}
+
+func nonNegative(v interface{}) error {
+	if d, _ := time.ParseDuration(v.(string)); d < 0 {
+		return errors.New("must not be negative")
+	}
+	return nil
+}
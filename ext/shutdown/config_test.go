@@ -0,0 +1,26 @@
+package shutdown
+
+import (
+	"testing"
+	"time"
+
+	"github.com/devopsfaith/krakend/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConfig(t *testing.T) {
+	conf := configGetter(config.ExtraConfig{
+		namespace: map[string]interface{}{
+			"grace_period":  "5s",
+			"drain_timeout": "1m",
+			"flush_timeout": "wrong",
+		},
+	})
+	assert.Equal(t, 5*time.Second, conf.GracePeriod)
+	assert.Equal(t, time.Minute, conf.DrainTimeout)
+	assert.Equal(t, defaultFlushTimeout, conf.FlushTimeout)
+
+	conf = configGetter(config.ExtraConfig{})
+	assert.Equal(t, time.Duration(0), conf.GracePeriod)
+	assert.Equal(t, defaultDrainTimeout, conf.DrainTimeout)
+}
@@ -0,0 +1,151 @@
+package shutdown
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/devopsfaith/krakend-ce/ext/health"
+	"github.com/devopsfaith/krakend/config"
+	"github.com/devopsfaith/krakend/logging"
+	router "github.com/devopsfaith/krakend/router/gin"
+)
+
+//Exit codes reported by the sequence
+const (
+	ExitOK           = 0
+	ExitServerError  = 1
+	ExitDrainTimeout = 2
+)
+
+//ErrDrainTimeout the in-flight requests were not done before the drain timeout
+var ErrDrainTimeout = errors.New("drain timeout exceeded")
+
+//Sequence graceful shutdown of the gateway. Once stopped, the gateway is marked as not ready,
+//keeps serving during the grace period, closes the listener and waits for the in-flight requests
+//up to the drain timeout. The exporters and the service discovery are flushed at the end
+type Sequence struct {
+	stop     chan struct{}
+	stopOnce sync.Once
+	code     int32
+
+	mu  sync.Mutex
+	cfg xtraConfig
+}
+
+//New create a shutdown sequence
+func New() *Sequence {
+	return &Sequence{
+		stop: make(chan struct{}),
+		cfg:  configGetter(nil),
+	}
+}
+
+//Stop start the shutdown sequence. Calling it more than once has no effect
+func (s *Sequence) Stop() {
+	s.stopOnce.Do(func() { close(s.stop) })
+}
+
+//ExitCode the status the process should exit with once the sequence is done
+func (s *Sequence) ExitCode() int {
+	return int(atomic.LoadInt32(&s.code))
+}
+
+func (s *Sequence) setExitCode(code int) {
+	atomic.CompareAndSwapInt32(&s.code, ExitOK, int32(code))
+}
+
+//RunServer wrap the RunServer so the server is shut down following the sequence. The timings
+//are read from the service extra config. Cancelling the context skips the grace period
+func (s *Sequence) RunServer(l logging.Logger, next router.RunServerFunc) router.RunServerFunc {
+	return func(ctx context.Context, cfg config.ServiceConfig, h http.Handler) error {
+		conf := configGetter(cfg.ExtraConfig)
+		s.mu.Lock()
+		s.cfg = conf
+		s.mu.Unlock()
+
+		srvCtx, cancel := context.WithCancel(ctx)
+		defer cancel()
+
+		done := make(chan error, 1)
+		go func() { done <- next(srvCtx, cfg, h) }()
+
+		select {
+		case err := <-done:
+			if err != nil && err != http.ErrServerClosed {
+				s.setExitCode(ExitServerError)
+			}
+			return err
+		case <-s.stop:
+		case <-ctx.Done():
+		}
+
+		health.SetReady(false)
+
+		if conf.GracePeriod > 0 && ctx.Err() == nil {
+			l.Info("[Shutdown] Marked as not ready, closing the listener in", conf.GracePeriod)
+			select {
+			case <-time.After(conf.GracePeriod):
+			case <-ctx.Done():
+			case err := <-done:
+				return s.serverDone(err)
+			}
+		}
+
+		l.Info("[Shutdown] Closing the listener and draining the in-flight requests")
+		cancel()
+
+		select {
+		case err := <-done:
+			return s.serverDone(err)
+		case <-time.After(conf.DrainTimeout):
+			l.Error("[Shutdown] In-flight requests still running after", conf.DrainTimeout)
+			s.setExitCode(ExitDrainTimeout)
+			return ErrDrainTimeout
+		}
+	}
+}
+
+func (s *Sequence) serverDone(err error) error {
+	if err != nil && err != http.ErrServerClosed {
+		s.setExitCode(ExitServerError)
+		return err
+	}
+	return nil
+}
+
+//Flush cancel the context of the gateway components, so the exporters and the service discovery
+//registrations bound to it are released, and run the hooks. It waits for the hooks up to the
+//flush timeout
+func (s *Sequence) Flush(l logging.Logger, cancel context.CancelFunc, hooks ...func()) {
+	s.mu.Lock()
+	timeout := s.cfg.FlushTimeout
+	s.mu.Unlock()
+
+	cancel()
+
+	var wg sync.WaitGroup
+	for _, hook := range hooks {
+		wg.Add(1)
+		go func(hook func()) {
+			defer wg.Done()
+			hook()
+		}(hook)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		l.Info("[Shutdown] Done")
+	case <-time.After(timeout):
+		l.Warning("[Shutdown] Flush still running after", timeout)
+	}
+}
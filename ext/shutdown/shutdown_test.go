@@ -0,0 +1,131 @@
+package shutdown
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/devopsfaith/krakend-ce/ext/health"
+	"github.com/devopsfaith/krakend/config"
+	"github.com/devopsfaith/krakend/logging"
+	"github.com/stretchr/testify/assert"
+)
+
+var logger, _ = logging.NewLogger("CRITICAL", ioutil.Discard, "")
+
+func newConfig(grace, drain string) config.ServiceConfig {
+	return config.ServiceConfig{
+		ExtraConfig: config.ExtraConfig{
+			namespace: map[string]interface{}{
+				"grace_period":  grace,
+				"drain_timeout": drain,
+				"flush_timeout": "100ms",
+			},
+		},
+	}
+}
+
+//server fake server taking drain to shut down once its context is cancelled. It is marked as ready
+//on start, as the health RunServer does once the port accepts connections
+func server(closed chan<- time.Time, drain time.Duration) func(context.Context, config.ServiceConfig, http.Handler) error {
+	return func(ctx context.Context, _ config.ServiceConfig, _ http.Handler) error {
+		health.SetReady(true)
+		<-ctx.Done()
+		closed <- time.Now()
+		time.Sleep(drain)
+		return nil
+	}
+}
+
+func TestSequence(t *testing.T) {
+	s := New()
+	closed := make(chan time.Time, 1)
+	done := make(chan error, 1)
+
+	go func() {
+		done <- s.RunServer(logger, server(closed, 10*time.Millisecond))(context.Background(), newConfig("100ms", "1s"), nil)
+	}()
+
+	time.Sleep(20 * time.Millisecond)
+	assert.True(t, health.Ready())
+
+	stopped := time.Now()
+	s.Stop()
+	s.Stop()
+
+	time.Sleep(20 * time.Millisecond)
+	assert.False(t, health.Ready(), "The readiness should flip before closing the listener")
+	assert.Equal(t, 0, len(closed), "The listener should stay open during the grace period")
+
+	assert.True(t, (<-closed).Sub(stopped) >= 100*time.Millisecond)
+	assert.Nil(t, <-done)
+	assert.Equal(t, ExitOK, s.ExitCode())
+}
+
+func TestSequenceDrainTimeout(t *testing.T) {
+	s := New()
+	closed := make(chan time.Time, 1)
+	done := make(chan error, 1)
+
+	go func() {
+		done <- s.RunServer(logger, server(closed, time.Second))(context.Background(), newConfig("0s", "50ms"), nil)
+	}()
+
+	time.Sleep(10 * time.Millisecond)
+	s.Stop()
+
+	assert.Equal(t, ErrDrainTimeout, <-done)
+	assert.Equal(t, ExitDrainTimeout, s.ExitCode())
+}
+
+func TestSequenceServerError(t *testing.T) {
+	s := New()
+	errListen := errors.New("address already in use")
+
+	err := s.RunServer(logger, func(context.Context, config.ServiceConfig, http.Handler) error {
+		return errListen
+	})(context.Background(), newConfig("1s", "1s"), nil)
+
+	assert.Equal(t, errListen, err)
+	assert.Equal(t, ExitServerError, s.ExitCode())
+}
+
+func TestSequenceContextCancelled(t *testing.T) {
+	s := New()
+	closed := make(chan time.Time, 1)
+	ctx, cancel := context.WithCancel(context.Background())
+
+	done := make(chan error, 1)
+	go func() {
+		done <- s.RunServer(logger, server(closed, 0))(ctx, newConfig("1m", "1s"), nil)
+	}()
+
+	time.Sleep(10 * time.Millisecond)
+	cancel()
+
+	select {
+	case err := <-done:
+		assert.Nil(t, err)
+	case <-time.After(time.Second):
+		t.Error("The grace period should be skipped when the context is cancelled")
+	}
+}
+
+func TestFlush(t *testing.T) {
+	s := New()
+	s.cfg = configGetter(newConfig("0s", "1s").ExtraConfig)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	flushed := make(chan struct{}, 1)
+	s.Flush(logger, cancel, func() { flushed <- struct{}{} })
+
+	assert.NotNil(t, ctx.Err())
+	assert.Equal(t, 1, len(flushed))
+
+	start := time.Now()
+	s.Flush(logger, func() {}, func() { time.Sleep(time.Second) })
+	assert.True(t, time.Since(start) < 500*time.Millisecond, "The flush should not wait longer than the flush timeout")
+}
@@ -0,0 +1,154 @@
+package transform
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/devopsfaith/krakend-ce/ext/lint"
+	"github.com/devopsfaith/krakend-ce/ext/selector"
+	"github.com/devopsfaith/krakend/config"
+)
+
+const (
+	namespace = "github_com/sahalzain/krakend-transform"
+
+	opRename  = "rename"
+	opMove    = "move"
+	opDelete  = "delete"
+	opSet     = "set"
+	opDefault = "default"
+)
+
+//rule body transformation step. Paths are gjson/sjson paths of the JSON body
+type rule struct {
+	Op string
+	//Path field written by set and default, removed by delete
+	Path string
+	//From field renamed or moved, or selector the set and default values are read from
+	From string
+	//To destination path of rename and move
+	To string
+	//Value constant written by set and default, fallback when From is not found
+	Value    interface{}
+	HasValue bool
+}
+
+type xtraConfig struct {
+	Rules []rule
+}
+
+func configGetter(cfg config.ExtraConfig) *xtraConfig {
+	v, ok := cfg[namespace]
+	if !ok {
+		return nil
+	}
+	tmp, ok := v.(map[string]interface{})
+	if !ok {
+		return nil
+	}
+
+	rules, ok := tmp["rules"].([]interface{})
+	if !ok {
+		return nil
+	}
+
+	conf := xtraConfig{}
+	for _, r := range rules {
+		m, ok := r.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if rl, err := parseRule(m); err == nil {
+			conf.Rules = append(conf.Rules, rl)
+		}
+	}
+
+	if len(conf.Rules) == 0 {
+		return nil
+	}
+
+	return &conf
+}
+
+func parseRule(m map[string]interface{}) (rule, error) {
+	rl := rule{}
+	rl.Op, _ = m["op"].(string)
+	rl.Path, _ = m["path"].(string)
+	rl.From, _ = m["from"].(string)
+	rl.To, _ = m["to"].(string)
+	rl.Value, rl.HasValue = m["value"]
+
+	switch strings.ToLower(rl.Op) {
+	case opRename:
+		if rl.From == "" || rl.To == "" {
+			return rl, errors.New("rename requires from and to")
+		}
+		if strings.Contains(rl.To, ".") {
+			return rl, errors.New("rename keeps the field under the same parent, to must be a field name")
+		}
+		//rename keeps the field under the same parent
+		if i := strings.LastIndex(rl.From, "."); i >= 0 {
+			rl.To = rl.From[:i+1] + rl.To
+		}
+	case opMove:
+		if rl.From == "" || rl.To == "" {
+			return rl, errors.New("move requires from and to")
+		}
+	case opDelete:
+		if rl.Path == "" {
+			return rl, errors.New("delete requires path")
+		}
+	case opSet, opDefault:
+		if rl.Path == "" || (rl.From == "" && !rl.HasValue) {
+			return rl, fmt.Errorf("%s requires path and either from or value", strings.ToLower(rl.Op))
+		}
+		if rl.From != "" {
+			if _, err := selector.Parse(rl.From); err != nil {
+				return rl, fmt.Errorf("invalid selector %q", rl.From)
+			}
+		}
+	default:
+		return rl, fmt.Errorf("unknown op %q", rl.Op)
+	}
+
+	rl.Op = strings.ToLower(rl.Op)
+	return rl, nil
+}
+
+//Linter validation of the transformation rules, the invalid rules are skipped at runtime
+var Linter = lint.Linter{
+	Namespace: namespace,
+	Scope:     lint.ScopeEndpoint,
+	Fields: []lint.Field{
+		{Name: "rules", Kind: lint.KindArray, Required: true, Check: lint.All(lint.NotEmpty, lint.NewCheck(nil, map[string]interface{}{
+			"items": map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"op":    map[string]interface{}{"type": "string", "description": "rename, move, delete, set or default"},
+					"path":  map[string]interface{}{"type": "string"},
+					"from":  map[string]interface{}{"type": "string"},
+					"to":    map[string]interface{}{"type": "string"},
+					"value": map[string]interface{}{},
+				},
+				"required":             []string{"op"},
+				"additionalProperties": false,
+			},
+		}))},
+	},
+	Check: func(tmp map[string]interface{}) []lint.Issue {
+		var issues []lint.Issue
+		for i, r := range tmp["rules"].([]interface{}) {
+			field := fmt.Sprintf("rules.%d", i)
+			m, ok := r.(map[string]interface{})
+			if !ok {
+				issues = append(issues, lint.Issue{Field: field, Msg: "must be an object"})
+				continue
+			}
+			if _, err := parseRule(m); err != nil {
+				issues = append(issues, lint.Issue{Field: field, Msg: err.Error()})
+			}
+		}
+		return issues
+	},
+}
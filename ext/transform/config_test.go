@@ -0,0 +1,81 @@
+package transform
+
+import (
+	"testing"
+
+	"github.com/devopsfaith/krakend-ce/ext/lint"
+	"github.com/devopsfaith/krakend/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConfigInvalidParse(t *testing.T) {
+	assert.Nil(t, configGetter(config.ExtraConfig{
+		namespace: "transform",
+	}), "Should nil")
+
+	assert.Nil(t, configGetter(config.ExtraConfig{
+		namespace: map[string]interface{}{
+			"rules": []interface{}{
+				map[string]interface{}{"op": "rename", "from": "a"},
+				map[string]interface{}{"op": "rename", "from": "a", "to": "b.c"},
+				map[string]interface{}{"op": "move", "to": "a"},
+				map[string]interface{}{"op": "delete"},
+				map[string]interface{}{"op": "set", "path": "a"},
+				map[string]interface{}{"op": "set", "path": "a", "from": "form.a"},
+				map[string]interface{}{"op": "copy", "from": "a", "to": "b"},
+				"delete",
+			},
+		},
+	}), "Should nil")
+}
+
+func TestConfigParse(t *testing.T) {
+	cfg := configGetter(config.ExtraConfig{
+		namespace: map[string]interface{}{
+			"rules": []interface{}{
+				map[string]interface{}{"op": "rename", "from": "user.user_name", "to": "username"},
+				map[string]interface{}{"op": "Move", "from": "legacy.id", "to": "id"},
+				map[string]interface{}{"op": "delete", "path": "legacy"},
+				map[string]interface{}{"op": "set", "path": "version", "value": 2},
+				map[string]interface{}{"op": "set", "path": "tenant", "from": "header.X-Tenant"},
+				map[string]interface{}{"op": "default", "path": "lang", "value": nil},
+			},
+		},
+	})
+
+	assert.NotNil(t, cfg, "Should not nil")
+	assert.Equal(t, []rule{
+		{Op: opRename, From: "user.user_name", To: "user.username"},
+		{Op: opMove, From: "legacy.id", To: "id"},
+		{Op: opDelete, Path: "legacy"},
+		{Op: opSet, Path: "version", Value: 2, HasValue: true},
+		{Op: opSet, Path: "tenant", From: "header.X-Tenant"},
+		{Op: opDefault, Path: "lang", HasValue: true},
+	}, cfg.Rules)
+}
+
+func TestLinter(t *testing.T) {
+	errs := lint.Lint(config.ServiceConfig{
+		Endpoints: []*config.EndpointConfig{
+			{
+				Endpoint: "/foo",
+				ExtraConfig: config.ExtraConfig{
+					namespace: map[string]interface{}{
+						"rules": []interface{}{
+							map[string]interface{}{"op": "rename", "from": "a.b", "to": "c"},
+							map[string]interface{}{"op": "rename", "from": "a", "to": "b.c"},
+							map[string]interface{}{"op": "copy", "from": "a", "to": "b"},
+							"delete",
+						},
+					},
+				},
+			},
+		},
+	}, Linter)
+
+	assert.Len(t, errs, 3)
+	assert.Equal(t, "rules.1", errs[0].Field)
+	assert.Equal(t, `endpoint GET /foo: github_com/sahalzain/krakend-transform.rules.2: unknown op "copy"`, errs[1].Error())
+	assert.Equal(t, "rules.3", errs[2].Field)
+	assert.Empty(t, errs.Security())
+}
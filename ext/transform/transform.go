@@ -0,0 +1,110 @@
+package transform
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+
+	"github.com/devopsfaith/krakend-ce/ext/reqctx"
+	"github.com/devopsfaith/krakend-ce/ext/selector"
+	"github.com/devopsfaith/krakend/config"
+	"github.com/devopsfaith/krakend/logging"
+	"github.com/devopsfaith/krakend/proxy"
+	krakendgin "github.com/devopsfaith/krakend/router/gin"
+	"github.com/gin-gonic/gin"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+//HandlerFactory request body transformation handler factory. The rules are applied in order to
+//JSON bodies, empty and non JSON bodies are sent untouched
+func HandlerFactory(l logging.Logger, next krakendgin.HandlerFactory) krakendgin.HandlerFactory {
+	return func(remote *config.EndpointConfig, p proxy.Proxy) gin.HandlerFunc {
+		handlerFunc := next(remote, p)
+
+		conf := configGetter(remote.ExtraConfig)
+
+		if conf == nil {
+			return func(c *gin.Context) {
+				handlerFunc(c)
+			}
+		}
+
+		l.Debug("[Transform] Body transformation is enabled for endpoint ", remote.Endpoint)
+
+		return func(c *gin.Context) {
+			reqctx.FromGin(c)
+
+			if err := conf.transform(c.Request); err != nil {
+				l.Warning("[Transform] Body not transformed ", err)
+			}
+
+			handlerFunc(c)
+		}
+	}
+}
+
+func (x *xtraConfig) transform(r *http.Request) error {
+	raw, err := reqctx.Body(r)
+	if err != nil {
+		return err
+	}
+
+	if len(bytes.TrimSpace(raw)) == 0 {
+		return nil
+	}
+	if !gjson.Valid(string(raw)) {
+		return errors.New("Body is not valid JSON")
+	}
+
+	body := string(raw)
+	for _, rl := range x.Rules {
+		if body, err = rl.apply(body, r); err != nil {
+			return err
+		}
+	}
+
+	reqctx.SetBody(r, []byte(body))
+
+	return nil
+}
+
+func (rl rule) apply(body string, r *http.Request) (string, error) {
+	switch rl.Op {
+	case opRename, opMove:
+		val := gjson.Get(body, rl.From)
+		if !val.Exists() || rl.From == rl.To {
+			return body, nil
+		}
+		res, err := sjson.Delete(body, rl.From)
+		if err != nil {
+			return body, err
+		}
+		return sjson.SetRaw(res, rl.To, val.Raw)
+	case opDelete:
+		return sjson.Delete(body, rl.Path)
+	case opDefault:
+		if gjson.Get(body, rl.Path).Exists() {
+			return body, nil
+		}
+		fallthrough
+	case opSet:
+		val, ok := rl.value(r)
+		if !ok {
+			return body, nil
+		}
+		return sjson.Set(body, rl.Path, val)
+	default:
+		return body, nil
+	}
+}
+
+//value reads the From selector, falling back to the constant value
+func (rl rule) value(r *http.Request) (interface{}, bool) {
+	if rl.From != "" {
+		if val, err := selector.Get(r, rl.From); err == nil {
+			return val, true
+		}
+	}
+	return rl.Value, rl.HasValue
+}
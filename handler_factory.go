@@ -1,10 +1,16 @@
 package krakend
 
 import (
+	"context"
+
 	botdetector "github.com/devopsfaith/krakend-botdetector/gin"
+	"github.com/devopsfaith/krakend-ce/ext/extauthz"
+	"github.com/devopsfaith/krakend-ce/ext/introspect"
 	"github.com/devopsfaith/krakend-ce/ext/jwtmap"
 	"github.com/devopsfaith/krakend-ce/ext/keyauth"
+	"github.com/devopsfaith/krakend-ce/ext/lint"
 	"github.com/devopsfaith/krakend-ce/ext/opa"
+	"github.com/devopsfaith/krakend-ce/ext/transform"
 	jose "github.com/devopsfaith/krakend-jose"
 	ginjose "github.com/devopsfaith/krakend-jose/gin"
 	lua "github.com/devopsfaith/krakend-lua/router/gin"
@@ -18,21 +24,105 @@ import (
 
 // NewHandlerFactory returns a HandlerFactory with a rate-limit and a metrics collector middleware injected
 func NewHandlerFactory(logger logging.Logger, metricCollector *metrics.Metrics, rejecter jose.RejecterFactory) router.HandlerFactory {
+	return NewHandlerFactoryWithContext(context.Background(), logger, metricCollector, rejecter)
+}
+
+// NewHandlerFactoryWithContext returns a HandlerFactory with the default middlewares injected. The background
+// tasks started by the middlewares are stopped when the received context is cancelled
+func NewHandlerFactoryWithContext(ctx context.Context, logger logging.Logger, metricCollector *metrics.Metrics, rejecter jose.RejecterFactory) router.HandlerFactory {
+	return defaultStacks().NewHandlerFactoryWithContext(ctx, logger, metricCollector, rejecter)
+}
+
+// NewHandlerFactory returns a HandlerFactory wrapping the rate-limit one with the handler stack
+func (s *MiddlewareStacks) NewHandlerFactory(l logging.Logger, m *metrics.Metrics, r jose.RejecterFactory) router.HandlerFactory {
+	return s.NewHandlerFactoryWithContext(context.Background(), l, m, r)
+}
+
+// NewHandlerFactoryWithContext returns a HandlerFactory wrapping the rate-limit one with the handler stack.
+// The background tasks started by the middlewares are stopped when the context is cancelled
+func (s *MiddlewareStacks) NewHandlerFactoryWithContext(ctx context.Context, l logging.Logger, m *metrics.Metrics, r jose.RejecterFactory) router.HandlerFactory {
+	deps := MiddlewareDeps{Context: ctx, Logger: l, Metrics: m, Rejecter: r}
 	handlerFactory := juju.HandlerFactory
-	handlerFactory = jwtmap.HandlerFactory(logger, handlerFactory)
-	handlerFactory = opa.HandlerFactory(logger, handlerFactory)
-	handlerFactory = lua.HandlerFactory(logger, handlerFactory)
-	handlerFactory = ginjose.HandlerFactory(handlerFactory, logger, rejecter)
-	handlerFactory = keyauth.HandlerFactory(logger, handlerFactory)
-	handlerFactory = metricCollector.NewHTTPHandlerFactory(handlerFactory)
-	handlerFactory = opencensus.New(handlerFactory)
-	handlerFactory = newrelic.HandlerFactory(handlerFactory)
-	handlerFactory = botdetector.New(handlerFactory, logger)
+	for i := len(s.handler) - 1; i >= 0; i-- {
+		handlerFactory = s.handler[i].Wrap(deps, handlerFactory)
+	}
 	return handlerFactory
 }
 
-type handlerFactory struct{}
+// handlerMiddlewares are the bundled handler middlewares in their default order, outermost first
+var handlerMiddlewares = []HandlerMiddleware{
+	{
+		Middleware: Middleware{Name: "botdetector", Namespaces: []string{"github_com/devopsfaith/krakend-botdetector"}, Priority: 100},
+		Wrap: func(d MiddlewareDeps, next router.HandlerFactory) router.HandlerFactory {
+			return botdetector.New(next, d.Logger)
+		},
+	},
+	{
+		Middleware: Middleware{Name: "newrelic", Namespaces: []string{"github_com/letgoapp/krakend-newrelic"}, Scope: lint.ScopeService, Priority: 200},
+		Wrap: func(_ MiddlewareDeps, next router.HandlerFactory) router.HandlerFactory {
+			return newrelic.HandlerFactory(next)
+		},
+	},
+	{
+		Middleware: Middleware{Name: "opencensus", Namespaces: []string{"github_com/devopsfaith/krakend-opencensus"}, Scope: lint.ScopeService, Priority: 300},
+		Wrap: func(_ MiddlewareDeps, next router.HandlerFactory) router.HandlerFactory {
+			return opencensus.New(next)
+		},
+	},
+	{
+		Middleware: Middleware{Name: "metrics", Namespaces: []string{"github_com/devopsfaith/krakend-metrics"}, Scope: lint.ScopeService, Priority: 400},
+		Wrap: func(d MiddlewareDeps, next router.HandlerFactory) router.HandlerFactory {
+			return d.Metrics.NewHTTPHandlerFactory(next)
+		},
+	},
+	{
+		Middleware: Middleware{Name: "keyauth", Namespaces: []string{keyauth.Linter.Namespace}, Priority: 500},
+		Wrap: func(d MiddlewareDeps, next router.HandlerFactory) router.HandlerFactory {
+			return keyauth.HandlerFactoryWithContext(d.Context, d.Logger, next)
+		},
+	},
+	{
+		Middleware: Middleware{Name: "jose", Namespaces: []string{
+			"github.com/devopsfaith/krakend-jose/signer",
+			"github.com/devopsfaith/krakend-jose/validator",
+		}, Priority: 600},
+		Wrap: func(d MiddlewareDeps, next router.HandlerFactory) router.HandlerFactory {
+			return ginjose.HandlerFactory(next, d.Logger, d.Rejecter)
+		},
+	},
+	{
+		Middleware: Middleware{Name: "lua", Namespaces: []string{"github.com/devopsfaith/krakend-lua/router"}, Priority: 700},
+		Wrap: func(d MiddlewareDeps, next router.HandlerFactory) router.HandlerFactory {
+			return lua.HandlerFactory(d.Logger, next)
+		},
+	},
+	{
+		Middleware: Middleware{Name: "extauthz", Namespaces: []string{extauthz.Linter.Namespace}, Priority: 800},
+		Wrap: func(d MiddlewareDeps, next router.HandlerFactory) router.HandlerFactory {
+			return extauthz.HandlerFactory(d.Logger, next)
+		},
+	},
+	{
+		Middleware: Middleware{Name: "opa", Namespaces: []string{opa.Linter.Namespace}, Priority: 900},
+		Wrap: func(d MiddlewareDeps, next router.HandlerFactory) router.HandlerFactory {
+			return opa.HandlerFactoryWithContext(d.Context, d.Logger, next)
+		},
+	},
+	{
+		Middleware: Middleware{Name: "jwtmap", Namespaces: []string{jwtmap.Linter.Namespace}, Priority: 1000},
+		Wrap: func(d MiddlewareDeps, next router.HandlerFactory) router.HandlerFactory {
+			return jwtmap.HandlerFactory(d.Logger, next)
+		},
+	},
+	{
+		Middleware: Middleware{Name: "transform", Namespaces: []string{transform.Linter.Namespace}, Priority: 1100},
+		Wrap: func(d MiddlewareDeps, next router.HandlerFactory) router.HandlerFactory {
+			return transform.HandlerFactory(d.Logger, next)
+		},
+	},
+}
 
-func (h handlerFactory) NewHandlerFactory(l logging.Logger, m *metrics.Metrics, r jose.RejecterFactory) router.HandlerFactory {
-	return NewHandlerFactory(l, m, r)
+// handlerBase describes the handler wrapped by the handler stack
+var handlerBase = introspect.Stack{
+	{Name: "ratelimit", Namespaces: []string{"github.com/devopsfaith/krakend-ratelimit/juju/router"}},
 }
@@ -0,0 +1,158 @@
+package krakend
+
+import (
+	"encoding/json"
+	"io"
+
+	"github.com/devopsfaith/krakend-ce/ext/bodylimit"
+	"github.com/devopsfaith/krakend-ce/ext/cacheadmin"
+	"github.com/devopsfaith/krakend-ce/ext/certs"
+	"github.com/devopsfaith/krakend-ce/ext/chain"
+	"github.com/devopsfaith/krakend-ce/ext/extauthz"
+	"github.com/devopsfaith/krakend-ce/ext/health"
+	"github.com/devopsfaith/krakend-ce/ext/introspect"
+	"github.com/devopsfaith/krakend-ce/ext/jwtmap"
+	"github.com/devopsfaith/krakend-ce/ext/keyauth"
+	"github.com/devopsfaith/krakend-ce/ext/lint"
+	"github.com/devopsfaith/krakend-ce/ext/listener"
+	"github.com/devopsfaith/krakend-ce/ext/opa"
+	"github.com/devopsfaith/krakend-ce/ext/shutdown"
+	"github.com/devopsfaith/krakend-ce/ext/transform"
+	"github.com/devopsfaith/krakend/config"
+	"github.com/devopsfaith/krakend/logging"
+)
+
+// ConfigLinters are the descriptions of the ext modules config blocks checked on startup
+var ConfigLinters = []lint.Linter{
+	keyauth.Linter,
+	opa.Linter,
+	extauthz.Linter,
+	jwtmap.Linter,
+	transform.Linter,
+	bodylimit.Linter,
+	cacheadmin.Linter,
+	introspect.Linter,
+	health.Linter,
+	listener.Linter,
+	listener.EndpointLinter,
+	certs.Linter,
+	shutdown.Linter,
+	middlewaresLinter(nil),
+}
+
+// middlewaresLinter checks the placed middlewares are registered in the stacks of the registry,
+// the default one when nil
+func middlewaresLinter(r *MiddlewareRegistry) lint.Linter {
+	l := chain.Linter
+	l.Check = func(tmp map[string]interface{}) []lint.Issue {
+		cfg := config.ServiceConfig{ExtraConfig: config.ExtraConfig{l.Namespace: tmp}}
+		if _, err := registryOrDefault(r).Stacks(cfg); err != nil {
+			return []lint.Issue{{Msg: err.Error()}}
+		}
+		return nil
+	}
+	return l
+}
+
+func registryOrDefault(r *MiddlewareRegistry) *MiddlewareRegistry {
+	if r == nil {
+		return DefaultMiddlewareRegistry()
+	}
+	return r
+}
+
+// configLinters returns the ConfigLinters checking the placed middlewares against the registry
+func configLinters(r *MiddlewareRegistry) []lint.Linter {
+	linters := make([]lint.Linter, len(ConfigLinters))
+	for i, l := range ConfigLinters {
+		if l.Namespace == chain.Linter.Namespace {
+			l = middlewaresLinter(r)
+		}
+		linters[i] = l
+	}
+	return linters
+}
+
+// lintServiceConfig checks the ext modules config blocks of the service, the middlewares against
+// the registry. Once the middlewares block is valid the stacks are placed with the whole config, so
+// removing a middleware enabled by the declared blocks is reported as well
+func lintServiceConfig(cfg config.ServiceConfig, r *MiddlewareRegistry) lint.Errors {
+	errs := lint.Lint(cfg, configLinters(r)...)
+	for _, err := range errs {
+		if err.Namespace == chain.Linter.Namespace {
+			return errs
+		}
+	}
+
+	if _, err := registryOrDefault(r).Stacks(cfg); err != nil {
+		errs = append(errs, &lint.Error{
+			Location:  "service",
+			Namespace: chain.Linter.Namespace,
+			Msg:       err.Error(),
+			Security:  true,
+		})
+	}
+	return errs
+}
+
+// WriteConfigSchema writes the JSON Schema of the configuration. The ext namespaces are described
+// by the same ConfigLinters validating them on startup.
+func WriteConfigSchema(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(lint.Schema(ConfigLinters...))
+}
+
+// NewLintParser wraps the parser so the ext modules config blocks are checked after parsing. The
+// invalid blocks of the security modules are always rejected, since the modules are disabled by
+// them. In strict mode every problem found is rejected, as the `check --lint` command does.
+func NewLintParser(p config.Parser, strict bool) config.Parser {
+	return NewLintParserWithRegistry(p, strict, nil)
+}
+
+// NewLintParserWithRegistry is a NewLintParser checking the placed middlewares against the registry
+// used by the ExecutorBuilder instead of the default one
+func NewLintParserWithRegistry(p config.Parser, strict bool, r *MiddlewareRegistry) config.Parser {
+	return lintParser{Parser: p, strict: strict, registry: r}
+}
+
+type lintParser struct {
+	config.Parser
+	strict   bool
+	registry *MiddlewareRegistry
+}
+
+func (p lintParser) Parse(file string) (config.ServiceConfig, error) {
+	cfg, err := p.Parser.Parse(file)
+	if err != nil {
+		return cfg, err
+	}
+
+	errs := lintServiceConfig(cfg, p.registry)
+	if !p.strict {
+		errs = errs.Security()
+	}
+	if len(errs) > 0 {
+		return cfg, errs
+	}
+	return cfg, nil
+}
+
+// lintConfig logs the problems found in the ext modules config blocks and reports whether the
+// gateway can start with the configuration and the middlewares of the registry
+func lintConfig(cfg config.ServiceConfig, r *MiddlewareRegistry, logger logging.Logger) bool {
+	errs := lintServiceConfig(cfg, r)
+	for _, err := range errs {
+		if err.Security {
+			logger.Error("config lint:", err.Error())
+			continue
+		}
+		logger.Warning("config lint:", err.Error())
+	}
+
+	if len(errs.Security()) > 0 {
+		logger.Critical("config lint: invalid security module configuration, refusing to start")
+		return false
+	}
+	return true
+}
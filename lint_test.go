@@ -0,0 +1,45 @@
+package krakend
+
+import (
+	"bytes"
+	"io/ioutil"
+	"testing"
+
+	"github.com/devopsfaith/krakend-ce/ext/chain"
+	"github.com/devopsfaith/krakend/config"
+)
+
+func TestWriteConfigSchema(t *testing.T) {
+	golden, err := ioutil.ReadFile("krakend.schema.json")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	buf := new(bytes.Buffer)
+	if err := WriteConfigSchema(buf); err != nil {
+		t.Error(err)
+		return
+	}
+	if !bytes.Equal(golden, buf.Bytes()) {
+		t.Error("krakend.schema.json does not match the ConfigLinters, run make schema to update it")
+	}
+}
+
+func TestLintServiceConfig_registry(t *testing.T) {
+	r := DefaultMiddlewareRegistry()
+	r.AddHandlerMiddleware(testHandlerMiddleware("audit", 1500, true))
+
+	cfg := config.ServiceConfig{ExtraConfig: config.ExtraConfig{
+		chain.Linter.Namespace: map[string]interface{}{
+			chain.StackHandler: map[string]interface{}{"after": map[string]interface{}{"audit": "transform"}},
+		},
+	}}
+
+	if errs := lintServiceConfig(cfg, nil); len(errs.Security()) == 0 {
+		t.Error("the middleware missing from the default registry should be reported")
+	}
+	if errs := lintServiceConfig(cfg, r); len(errs) != 0 {
+		t.Errorf("the middleware of the registry should be placed: %v", errs)
+	}
+}
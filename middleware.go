@@ -0,0 +1,352 @@
+package krakend
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	"github.com/devopsfaith/krakend-ce/ext/chain"
+	"github.com/devopsfaith/krakend-ce/ext/introspect"
+	"github.com/devopsfaith/krakend-ce/ext/lint"
+	jose "github.com/devopsfaith/krakend-jose"
+	metrics "github.com/devopsfaith/krakend-metrics/gin"
+	"github.com/devopsfaith/krakend/config"
+	"github.com/devopsfaith/krakend/logging"
+	"github.com/devopsfaith/krakend/proxy"
+	router "github.com/devopsfaith/krakend/router/gin"
+	"github.com/gin-gonic/gin"
+)
+
+// Middleware describes a middleware of a stack
+type Middleware struct {
+	// Name identifies the middleware in the config placing the stack middlewares
+	Name string
+	// Namespaces are the extra config namespaces enabling the middleware, reported by the
+	// introspection endpoint. Middlewares without namespaces are always enabled
+	Namespaces []string
+	// Scope are the config levels the namespaces are read from, the level of the stack when zero
+	Scope lint.Scope
+	// Optional middlewares are only part of the stack when the config places them
+	Optional bool
+	// Priority sets the default order of the stack, the lowest priority is the outermost
+	// middleware. Middlewares with the same priority keep their registration order
+	Priority int
+}
+
+// MiddlewareDeps are the collaborators available to the middlewares when a stack is assembled.
+// The background tasks started by the middlewares must stop when the Context is cancelled.
+type MiddlewareDeps struct {
+	Context  context.Context
+	Logger   logging.Logger
+	Metrics  *metrics.Metrics
+	Rejecter jose.RejecterFactory
+}
+
+// EngineHook registers its middlewares or endpoints in the router engine
+type EngineHook struct {
+	Middleware
+	Register func(config.ServiceConfig, logging.Logger, *gin.Engine)
+}
+
+// HandlerMiddleware wraps the endpoint handlers
+type HandlerMiddleware struct {
+	Middleware
+	Wrap func(MiddlewareDeps, router.HandlerFactory) router.HandlerFactory
+}
+
+// ProxyMiddleware wraps the endpoint proxies
+type ProxyMiddleware struct {
+	Middleware
+	Wrap func(MiddlewareDeps, proxy.Factory) proxy.Factory
+}
+
+// BackendMiddleware wraps the backend proxies
+type BackendMiddleware struct {
+	Middleware
+	Wrap func(MiddlewareDeps, proxy.BackendFactory) proxy.BackendFactory
+}
+
+// MiddlewareRegistry holds the middlewares of every stack sorted by priority, outermost first
+type MiddlewareRegistry struct {
+	mu     sync.RWMutex
+	stacks map[string][]registered
+}
+
+// registered is a middleware of a stack along with its hook or wrapper
+type registered struct {
+	Middleware
+	impl interface{}
+}
+
+// stackScopes are the config levels wrapped by every stack
+var stackScopes = map[string]lint.Scope{
+	chain.StackEngine:  lint.ScopeService,
+	chain.StackHandler: lint.ScopeEndpoint,
+	chain.StackProxy:   lint.ScopeEndpoint,
+	chain.StackBackend: lint.ScopeBackend,
+}
+
+// NewMiddlewareRegistry returns an empty registry
+func NewMiddlewareRegistry() *MiddlewareRegistry {
+	return &MiddlewareRegistry{stacks: map[string][]registered{}}
+}
+
+// DefaultMiddlewareRegistry returns a registry with the middlewares bundled with the gateway and
+// the ones registered by the imported modules
+func DefaultMiddlewareRegistry() *MiddlewareRegistry {
+	return middlewares.clone()
+}
+
+func (r *MiddlewareRegistry) clone() *MiddlewareRegistry {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	c := NewMiddlewareRegistry()
+	for stack, ms := range r.stacks {
+		c.stacks[stack] = append([]registered{}, ms...)
+	}
+	return c
+}
+
+// AddEngineHook adds the hook to the engine stack after the ones with the same or lower priority.
+// A hook with the same name is replaced.
+func (r *MiddlewareRegistry) AddEngineHook(h EngineHook) {
+	checkMiddleware(h.Middleware, h.Register != nil)
+	r.add(chain.StackEngine, h.Middleware, h)
+}
+
+// AddHandlerMiddleware adds the middleware to the handler stack after the ones with the same or
+// lower priority. A middleware with the same name is replaced.
+func (r *MiddlewareRegistry) AddHandlerMiddleware(h HandlerMiddleware) {
+	checkMiddleware(h.Middleware, h.Wrap != nil)
+	r.add(chain.StackHandler, h.Middleware, h)
+}
+
+// AddProxyMiddleware adds the middleware to the proxy stack after the ones with the same or lower
+// priority. A middleware with the same name is replaced.
+func (r *MiddlewareRegistry) AddProxyMiddleware(p ProxyMiddleware) {
+	checkMiddleware(p.Middleware, p.Wrap != nil)
+	r.add(chain.StackProxy, p.Middleware, p)
+}
+
+// AddBackendMiddleware adds the middleware to the backend stack after the ones with the same or
+// lower priority. A middleware with the same name is replaced.
+func (r *MiddlewareRegistry) AddBackendMiddleware(b BackendMiddleware) {
+	checkMiddleware(b.Middleware, b.Wrap != nil)
+	r.add(chain.StackBackend, b.Middleware, b)
+}
+
+// add inserts the middleware in the stack after the ones with the same or lower priority,
+// replacing the one with the same name
+func (r *MiddlewareRegistry) add(stack string, m Middleware, impl interface{}) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.stacks == nil {
+		r.stacks = map[string][]registered{}
+	}
+
+	ms := r.stacks[stack]
+	for i, e := range ms {
+		if e.Name == m.Name {
+			ms = append(ms[:i:i], ms[i+1:]...)
+			break
+		}
+	}
+	i := sort.Search(len(ms), func(i int) bool { return ms[i].Priority > m.Priority })
+	r.stacks[stack] = append(ms[:i:i], append([]registered{{Middleware: m, impl: impl}}, ms[i:]...)...)
+}
+
+func checkMiddleware(m Middleware, ok bool) {
+	if m.Name == "" {
+		panic("krakend: middleware without name")
+	}
+	if !ok {
+		panic("krakend: middleware " + m.Name + " without function")
+	}
+}
+
+// middlewares is the default registry, holding the bundled middlewares and the ones registered
+// by the imported modules
+var middlewares = bundledMiddlewares()
+
+func bundledMiddlewares() *MiddlewareRegistry {
+	r := NewMiddlewareRegistry()
+	for _, h := range engineHooks {
+		r.AddEngineHook(h)
+	}
+	for _, m := range handlerMiddlewares {
+		r.AddHandlerMiddleware(m)
+	}
+	for _, m := range proxyMiddlewares {
+		r.AddProxyMiddleware(m)
+	}
+	for _, m := range backendMiddlewares {
+		r.AddBackendMiddleware(m)
+	}
+	return r
+}
+
+// RegisterEngineHook adds the hook to the engine stack of the default registry. Modules call it
+// from their init function, so a blank import compiles them in the gateway:
+//
+//	func init() {
+//		krakend.RegisterEngineHook(krakend.EngineHook{
+//			Middleware: krakend.Middleware{Name: "audit", Priority: 450},
+//			Register:   audit.Register,
+//		})
+//	}
+//
+// A hook with the name of a bundled one replaces it. It panics when the name or the function are missing.
+func RegisterEngineHook(h EngineHook) {
+	middlewares.AddEngineHook(h)
+}
+
+// RegisterHandlerMiddleware adds the middleware to the handler stack of the default registry.
+// The bundled middlewares have priorities multiple of 100, see RegisterEngineHook.
+func RegisterHandlerMiddleware(m HandlerMiddleware) {
+	middlewares.AddHandlerMiddleware(m)
+}
+
+// RegisterProxyMiddleware adds the middleware to the proxy stack of the default registry.
+// The bundled middlewares have priorities multiple of 100, see RegisterEngineHook.
+func RegisterProxyMiddleware(m ProxyMiddleware) {
+	middlewares.AddProxyMiddleware(m)
+}
+
+// RegisterBackendMiddleware adds the middleware to the backend stack of the default registry.
+// The bundled middlewares have priorities multiple of 100, see RegisterEngineHook.
+func RegisterBackendMiddleware(m BackendMiddleware) {
+	middlewares.AddBackendMiddleware(m)
+}
+
+// Stacks returns the middleware stacks placed as declared in the service extra config. Placing
+// middlewares not registered in the stack, or removing the ones with their namespaces declared
+// where they run without allowing it, is an error.
+func (r *MiddlewareRegistry) Stacks(cfg config.ServiceConfig) (*MiddlewareStacks, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	conf := chain.ConfigGetter(cfg.ExtraConfig)
+	placed := map[string][]registered{}
+	for _, stack := range chain.Stacks {
+		ms, err := r.place(cfg, conf, stack)
+		if err != nil {
+			return nil, err
+		}
+		placed[stack] = ms
+	}
+
+	s := &MiddlewareStacks{}
+	for _, m := range placed[chain.StackEngine] {
+		s.engine = append(s.engine, m.impl.(EngineHook))
+	}
+	for _, m := range placed[chain.StackHandler] {
+		s.handler = append(s.handler, m.impl.(HandlerMiddleware))
+	}
+	for _, m := range placed[chain.StackProxy] {
+		s.proxy = append(s.proxy, m.impl.(ProxyMiddleware))
+	}
+	for _, m := range placed[chain.StackBackend] {
+		s.backend = append(s.backend, m.impl.(BackendMiddleware))
+	}
+	return s, nil
+}
+
+// place returns the middlewares of the stack placed as configured
+func (r *MiddlewareRegistry) place(cfg config.ServiceConfig, conf chain.Config, stack string) ([]registered, error) {
+	ms := r.stacks[stack]
+	entries := make([]chain.Entry, len(ms))
+	byName := make(map[string]registered, len(ms))
+	for i, m := range ms {
+		entries[i] = chain.Entry{Name: m.Name, Optional: m.Optional, Declared: m.declared(cfg, stackScopes[stack])}
+		byName[m.Name] = m
+	}
+
+	names, err := conf.Order(stack, entries)
+	if err != nil {
+		return nil, err
+	}
+	placed := make([]registered, len(names))
+	for i, n := range names {
+		placed[i] = byName[n]
+	}
+	return placed, nil
+}
+
+// declared reports whether any of the middleware namespaces is declared at the levels it reads
+// them from, the one of its stack when no Scope is set
+func (m Middleware) declared(cfg config.ServiceConfig, scope lint.Scope) bool {
+	if m.Scope != 0 {
+		scope = m.Scope
+	}
+	has := func(extra config.ExtraConfig) bool {
+		for _, ns := range m.Namespaces {
+			if _, ok := extra[ns]; ok {
+				return true
+			}
+		}
+		return false
+	}
+
+	if scope&lint.ScopeService != 0 && has(cfg.ExtraConfig) {
+		return true
+	}
+	for _, e := range cfg.Endpoints {
+		if scope&lint.ScopeEndpoint != 0 && has(e.ExtraConfig) {
+			return true
+		}
+		if scope&lint.ScopeBackend == 0 {
+			continue
+		}
+		for _, b := range e.Backend {
+			if has(b.ExtraConfig) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// defaultStacks returns the stacks of the bundled middlewares in their default order
+func defaultStacks() *MiddlewareStacks {
+	s, _ := DefaultMiddlewareRegistry().Stacks(config.ServiceConfig{})
+	return s
+}
+
+// MiddlewareStacks are the placed middlewares of every stack. They assemble the router engine, the
+// handler, proxy and backend factories, so they implement the EngineFactory, HandlerFactory,
+// ProxyFactory and BackendFactory interfaces.
+type MiddlewareStacks struct {
+	engine  []EngineHook
+	handler []HandlerMiddleware
+	proxy   []ProxyMiddleware
+	backend []BackendMiddleware
+}
+
+// Chains describes the stacks for the introspection endpoint, including the base of every stack
+func (s *MiddlewareStacks) Chains() introspect.Chains {
+	c := introspect.Chains{}
+	for _, m := range s.engine {
+		c.Engine = append(c.Engine, m.describe())
+	}
+	c.Engine = append(c.Engine, introspect.Middleware{Name: "introspect", Namespaces: []string{introspect.Linter.Namespace}})
+
+	for _, m := range s.handler {
+		c.Handler = append(c.Handler, m.describe())
+	}
+	c.Handler = append(c.Handler, handlerBase...)
+
+	for _, m := range s.proxy {
+		c.Proxy = append(c.Proxy, m.describe())
+	}
+	c.Proxy = append(c.Proxy, proxyBase...)
+
+	for _, m := range s.backend {
+		c.Backend = append(c.Backend, m.describe())
+	}
+	c.Backend = append(c.Backend, backendBase...)
+	return c
+}
+
+func (m Middleware) describe() introspect.Middleware {
+	return introspect.Middleware{Name: m.Name, Namespaces: m.Namespaces, Scope: m.Scope}
+}
@@ -0,0 +1,184 @@
+package krakend
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/devopsfaith/krakend-ce/ext/chain"
+	"github.com/devopsfaith/krakend/config"
+	router "github.com/devopsfaith/krakend/router/gin"
+)
+
+const middlewaresNamespace = "github_com/sahalzain/krakend-middlewares"
+
+func testHandlerMiddleware(name string, priority int, optional bool) HandlerMiddleware {
+	return HandlerMiddleware{
+		Middleware: Middleware{Name: name, Priority: priority, Optional: optional},
+		Wrap:       func(_ MiddlewareDeps, next router.HandlerFactory) router.HandlerFactory { return next },
+	}
+}
+
+func handlerNames(t *testing.T, r *MiddlewareRegistry, cfg config.ServiceConfig) []string {
+	s, err := r.Stacks(cfg)
+	if err != nil {
+		t.Error(err)
+		return nil
+	}
+	names := []string{}
+	for _, m := range s.handler {
+		names = append(names, m.Name)
+	}
+	return names
+}
+
+func TestMiddlewareRegistry_priority(t *testing.T) {
+	r := NewMiddlewareRegistry()
+	r.AddHandlerMiddleware(testHandlerMiddleware("c", 300, false))
+	r.AddHandlerMiddleware(testHandlerMiddleware("a1", 100, false))
+	r.AddHandlerMiddleware(testHandlerMiddleware("b", 200, false))
+	r.AddHandlerMiddleware(testHandlerMiddleware("a2", 100, false))
+	r.AddHandlerMiddleware(testHandlerMiddleware("first", -1, false))
+
+	want := []string{"first", "a1", "a2", "b", "c"}
+	if names := handlerNames(t, r, config.ServiceConfig{}); !reflect.DeepEqual(want, names) {
+		t.Errorf("unexpected order: %v", names)
+	}
+}
+
+func TestMiddlewareRegistry_replace(t *testing.T) {
+	r := NewMiddlewareRegistry()
+	r.AddHandlerMiddleware(testHandlerMiddleware("a", 100, false))
+	r.AddHandlerMiddleware(testHandlerMiddleware("b", 200, false))
+	defaults := r.clone()
+
+	wrapped := ""
+	r.AddHandlerMiddleware(HandlerMiddleware{
+		Middleware: Middleware{Name: "a", Priority: 300},
+		Wrap: func(_ MiddlewareDeps, next router.HandlerFactory) router.HandlerFactory {
+			wrapped = "a2"
+			return next
+		},
+	})
+
+	s, err := r.Stacks(config.ServiceConfig{})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if len(s.handler) != 2 || s.handler[0].Name != "b" || s.handler[1].Name != "a" {
+		t.Errorf("unexpected stack: %v", s.handler)
+		return
+	}
+	s.handler[1].Wrap(MiddlewareDeps{}, nil)
+	if wrapped != "a2" {
+		t.Error("the middleware should be replaced")
+	}
+
+	want := []string{"a", "b"}
+	if names := handlerNames(t, defaults, config.ServiceConfig{}); !reflect.DeepEqual(want, names) {
+		t.Errorf("the cloned registry should not change: %v", names)
+	}
+}
+
+func TestMiddlewareRegistry_panics(t *testing.T) {
+	for name, add := range map[string]func(*MiddlewareRegistry){
+		"no name": func(r *MiddlewareRegistry) {
+			r.AddHandlerMiddleware(HandlerMiddleware{Wrap: testHandlerMiddleware("a", 0, false).Wrap})
+		},
+		"no hook": func(r *MiddlewareRegistry) {
+			r.AddEngineHook(EngineHook{Middleware: Middleware{Name: "a"}})
+		},
+		"no handler": func(r *MiddlewareRegistry) {
+			r.AddHandlerMiddleware(HandlerMiddleware{Middleware: Middleware{Name: "a"}})
+		},
+		"no proxy": func(r *MiddlewareRegistry) {
+			r.AddProxyMiddleware(ProxyMiddleware{Middleware: Middleware{Name: "a"}})
+		},
+		"no backend": func(r *MiddlewareRegistry) {
+			r.AddBackendMiddleware(BackendMiddleware{Middleware: Middleware{Name: "a"}})
+		},
+	} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("%s: the registry should panic", name)
+				}
+			}()
+			add(NewMiddlewareRegistry())
+		}()
+	}
+}
+
+func TestMiddlewareRegistry_optional(t *testing.T) {
+	r := NewMiddlewareRegistry()
+	r.AddHandlerMiddleware(testHandlerMiddleware("a", 100, false))
+	r.AddHandlerMiddleware(testHandlerMiddleware("audit", 150, true))
+	r.AddHandlerMiddleware(testHandlerMiddleware("b", 200, false))
+
+	want := []string{"a", "b"}
+	if names := handlerNames(t, r, config.ServiceConfig{}); !reflect.DeepEqual(want, names) {
+		t.Errorf("the optional middleware should be left out: %v", names)
+	}
+
+	cfg := config.ServiceConfig{ExtraConfig: config.ExtraConfig{
+		middlewaresNamespace: map[string]interface{}{
+			chain.StackHandler: map[string]interface{}{"after": map[string]interface{}{"audit": "b"}},
+		},
+	}}
+	want = []string{"a", "b", "audit"}
+	if names := handlerNames(t, r, cfg); !reflect.DeepEqual(want, names) {
+		t.Errorf("the optional middleware should be placed: %v", names)
+	}
+}
+
+func TestDefaultMiddlewareRegistry(t *testing.T) {
+	// the bundled middlewares keep the order of the handler factory assembled before the registry,
+	// extauthz and transform included
+	want := []string{"botdetector", "newrelic", "opencensus", "metrics", "keyauth", "jose", "lua", "extauthz", "opa", "jwtmap", "transform"}
+	if names := handlerNames(t, DefaultMiddlewareRegistry(), config.ServiceConfig{}); !reflect.DeepEqual(want, names) {
+		t.Errorf("unexpected handler stack: %v", names)
+	}
+}
+
+func TestMiddlewareRegistry_Stacks_declared(t *testing.T) {
+	r := NewMiddlewareRegistry()
+	for _, name := range []string{"keyauth", "metrics"} {
+		r.AddHandlerMiddleware(HandlerMiddleware{
+			Middleware: Middleware{Name: name, Namespaces: []string{name}},
+			Wrap:       func(_ MiddlewareDeps, next router.HandlerFactory) router.HandlerFactory { return next },
+		})
+	}
+
+	cfg := config.ServiceConfig{
+		ExtraConfig: config.ExtraConfig{
+			middlewaresNamespace: map[string]interface{}{
+				chain.StackHandler: map[string]interface{}{"disable": []interface{}{"keyauth", "metrics"}},
+			},
+		},
+		Endpoints: []*config.EndpointConfig{
+			{Endpoint: "/public"},
+			{Endpoint: "/private", ExtraConfig: config.ExtraConfig{"keyauth": map[string]interface{}{}}},
+		},
+	}
+
+	_, err := r.Stacks(cfg)
+	want := `handler stack: middleware "keyauth" removed while its config is declared, add it to allow_removal to run without it`
+	if err == nil || err.Error() != want {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	cfg.ExtraConfig[middlewaresNamespace] = map[string]interface{}{
+		chain.StackHandler: map[string]interface{}{
+			"disable":       []interface{}{"keyauth", "metrics"},
+			"allow_removal": []interface{}{"keyauth"},
+		},
+	}
+	s, err := r.Stacks(cfg)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if len(s.handler) != 0 {
+		t.Errorf("unexpected handler stack: %v", s.handler)
+	}
+}
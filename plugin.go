@@ -1,12 +1,16 @@
 package krakend
 
 import (
+	"context"
+
+	"github.com/devopsfaith/krakend-ce/ext/health"
 	"github.com/devopsfaith/krakend/logging"
 	client "github.com/devopsfaith/krakend/transport/http/client/plugin"
 	server "github.com/devopsfaith/krakend/transport/http/server/plugin"
 )
 
-// LoadPlugins loads and registers the plugins so they can be used if enabled at the configuration
+// LoadPlugins loads and registers the plugins so they can be used if enabled at the configuration.
+// The loading errors fail the readiness checks.
 func LoadPlugins(folder, pattern string, logger logging.Logger) {
 	n, err := client.Load(
 		folder,
@@ -15,6 +19,7 @@ func LoadPlugins(folder, pattern string, logger logging.Logger) {
 	)
 	if err != nil {
 		logger.Warning("loading plugins:", err)
+		registerPluginCheck("plugins:client", err)
 	}
 	logger.Info("total http executor plugins loaded:", n)
 
@@ -25,10 +30,15 @@ func LoadPlugins(folder, pattern string, logger logging.Logger) {
 	)
 	if err != nil {
 		logger.Warning("loading plugins:", err)
+		registerPluginCheck("plugins:server", err)
 	}
 	logger.Info("total http handler plugins loaded:", n)
 }
 
+func registerPluginCheck(name string, err error) {
+	health.RegisterCheck(context.Background(), name, func(context.Context) error { return err })
+}
+
 type pluginLoader struct{}
 
 func (d pluginLoader) Load(folder, pattern string, logger logging.Logger) {
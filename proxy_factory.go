@@ -1,6 +1,8 @@
 package krakend
 
 import (
+	"github.com/devopsfaith/krakend-ce/ext/introspect"
+	"github.com/devopsfaith/krakend-ce/ext/lint"
 	cel "github.com/devopsfaith/krakend-cel"
 	jsonschema "github.com/devopsfaith/krakend-jsonschema"
 	lua "github.com/devopsfaith/krakend-lua/proxy"
@@ -13,19 +15,66 @@ import (
 
 // NewProxyFactory returns a new ProxyFactory wrapping the injected BackendFactory with the default proxy stack and a metrics collector
 func NewProxyFactory(logger logging.Logger, backendFactory proxy.BackendFactory, metricCollector *metrics.Metrics) proxy.Factory {
+	return defaultStacks().NewProxyFactory(logger, backendFactory, metricCollector)
+}
+
+// NewProxyFactory returns a ProxyFactory wrapping the default one with the proxy stack
+func (s *MiddlewareStacks) NewProxyFactory(logger logging.Logger, backendFactory proxy.BackendFactory, metricCollector *metrics.Metrics) proxy.Factory {
+	deps := MiddlewareDeps{Logger: logger, Metrics: metricCollector}
 	proxyFactory := proxy.NewDefaultFactory(backendFactory, logger)
-	proxyFactory = proxy.NewShadowFactory(proxyFactory)
-	proxyFactory = jsonschema.ProxyFactory(proxyFactory)
-	proxyFactory = cel.ProxyFactory(logger, proxyFactory)
-	proxyFactory = lua.ProxyFactory(logger, proxyFactory)
-	proxyFactory = metricCollector.ProxyFactory("pipe", proxyFactory)
-	proxyFactory = opencensus.ProxyFactory(proxyFactory)
-	proxyFactory = newrelic.ProxyFactory("pipe", proxyFactory)
+	for i := len(s.proxy) - 1; i >= 0; i-- {
+		proxyFactory = s.proxy[i].Wrap(deps, proxyFactory)
+	}
 	return proxyFactory
 }
 
-type proxyFactory struct{}
+// proxyMiddlewares are the bundled proxy middlewares in their default order, outermost first
+var proxyMiddlewares = []ProxyMiddleware{
+	{
+		Middleware: Middleware{Name: "newrelic", Namespaces: []string{"github_com/letgoapp/krakend-newrelic"}, Scope: lint.ScopeService, Priority: 100},
+		Wrap: func(_ MiddlewareDeps, next proxy.Factory) proxy.Factory {
+			return newrelic.ProxyFactory("pipe", next)
+		},
+	},
+	{
+		Middleware: Middleware{Name: "opencensus", Namespaces: []string{"github_com/devopsfaith/krakend-opencensus"}, Scope: lint.ScopeService, Priority: 200},
+		Wrap: func(_ MiddlewareDeps, next proxy.Factory) proxy.Factory {
+			return opencensus.ProxyFactory(next)
+		},
+	},
+	{
+		Middleware: Middleware{Name: "metrics", Namespaces: []string{"github_com/devopsfaith/krakend-metrics"}, Scope: lint.ScopeService, Priority: 300},
+		Wrap: func(d MiddlewareDeps, next proxy.Factory) proxy.Factory {
+			return d.Metrics.ProxyFactory("pipe", next)
+		},
+	},
+	{
+		Middleware: Middleware{Name: "lua", Namespaces: []string{"github.com/devopsfaith/krakend-lua/proxy"}, Priority: 400},
+		Wrap: func(d MiddlewareDeps, next proxy.Factory) proxy.Factory {
+			return lua.ProxyFactory(d.Logger, next)
+		},
+	},
+	{
+		Middleware: Middleware{Name: "cel", Namespaces: []string{"github.com/devopsfaith/krakend-cel"}, Priority: 500},
+		Wrap: func(d MiddlewareDeps, next proxy.Factory) proxy.Factory {
+			return cel.ProxyFactory(d.Logger, next)
+		},
+	},
+	{
+		Middleware: Middleware{Name: "jsonschema", Namespaces: []string{"github.com/devopsfaith/krakend-jsonschema"}, Priority: 600},
+		Wrap: func(_ MiddlewareDeps, next proxy.Factory) proxy.Factory {
+			return jsonschema.ProxyFactory(next)
+		},
+	},
+	{
+		Middleware: Middleware{Name: "shadow", Namespaces: []string{"github.com/devopsfaith/krakend/proxy"}, Scope: lint.ScopeBackend, Priority: 700},
+		Wrap: func(_ MiddlewareDeps, next proxy.Factory) proxy.Factory {
+			return proxy.NewShadowFactory(next)
+		},
+	},
+}
 
-func (p proxyFactory) NewProxyFactory(logger logging.Logger, backendFactory proxy.BackendFactory, metricCollector *metrics.Metrics) proxy.Factory {
-	return NewProxyFactory(logger, backendFactory, metricCollector)
+// proxyBase describes the proxy wrapped by the proxy stack
+var proxyBase = introspect.Stack{
+	{Name: "proxy"},
 }
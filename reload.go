@@ -0,0 +1,384 @@
+package krakend
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/devopsfaith/krakend-ce/ext/certs"
+	"github.com/devopsfaith/krakend-ce/ext/listener"
+	"github.com/devopsfaith/krakend/config"
+	"github.com/devopsfaith/krakend/logging"
+	router "github.com/devopsfaith/krakend/router/gin"
+	"github.com/fsnotify/fsnotify"
+)
+
+const (
+	// reloadDebounce is the quiet period after the last file change before reloading, so editors
+	// writing the file in several steps trigger a single reload
+	reloadDebounce = 500 * time.Millisecond
+	// reloadDrainTimeout is the maximum time the previous router is kept alive for its in-flight
+	// requests after a reload
+	reloadDrainTimeout = time.Minute
+)
+
+// ConfigReloader parses again the running configuration. Files returns the files and folders
+// the configuration depends on, watched for changes.
+type ConfigReloader interface {
+	Reload() (config.ServiceConfig, error)
+	Files() []string
+}
+
+// NewReloadableParser wraps the parser so the last parsed configuration file can be parsed again.
+// The extra paths, like the flexible config partials, templates and settings folders, are
+// watched along with the configuration file. Empty paths are ignored.
+func NewReloadableParser(p config.Parser, paths ...string) *ReloadableParser {
+	return &ReloadableParser{Parser: p, paths: paths}
+}
+
+// ReloadableParser is a config.Parser implementing the ConfigReloader interface
+type ReloadableParser struct {
+	config.Parser
+	paths []string
+
+	mu   sync.Mutex
+	file string
+}
+
+// Parse parses the configuration file and remembers its path
+func (p *ReloadableParser) Parse(file string) (config.ServiceConfig, error) {
+	p.mu.Lock()
+	p.file = file
+	p.mu.Unlock()
+
+	return p.Parser.Parse(file)
+}
+
+// Reload parses again the last parsed configuration file
+func (p *ReloadableParser) Reload() (config.ServiceConfig, error) {
+	p.mu.Lock()
+	file := p.file
+	p.mu.Unlock()
+
+	if file == "" {
+		return config.ServiceConfig{}, errors.New("no configuration file parsed")
+	}
+	return p.Parser.Parse(file)
+}
+
+// Files returns the configuration file and the extra paths
+func (p *ReloadableParser) Files() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	files := []string{}
+	for _, f := range append([]string{p.file}, p.paths...) {
+		if f != "" {
+			files = append(files, f)
+		}
+	}
+	return files
+}
+
+// routerBuilder sets up a router for the configuration and passes it to the RunServer
+type routerBuilder func(context.Context, config.ServiceConfig, router.RunServerFunc)
+
+// hotReloader keeps a single server running and swaps the router behind it every time the
+// configuration changes
+type hotReloader struct {
+	logger   logging.Logger
+	reloader ConfigReloader
+	registry *MiddlewareRegistry
+	build    routerBuilder
+	run      router.RunServerFunc
+	handler  *swappableHandler
+
+	cfg  config.ServiceConfig
+	hash string
+}
+
+func newHotReloader(l logging.Logger, r ConfigReloader, registry *MiddlewareRegistry, build routerBuilder, run router.RunServerFunc) *hotReloader {
+	return &hotReloader{
+		logger:   l,
+		reloader: r,
+		registry: registry,
+		build:    build,
+		run:      run,
+		handler:  &swappableHandler{logger: l, timeout: reloadDrainTimeout},
+	}
+}
+
+// Run builds the first router and starts the server. Every SIGHUP or change of the watched files
+// builds a new router while the server keeps running. It blocks until the server stops.
+func (h *hotReloader) Run(ctx context.Context, cfg config.ServiceConfig) {
+	h.cfg = cfg
+	h.hash, _ = cfg.Hash()
+	h.logger.Info(fmt.Sprintf("config hot reload enabled, config hash '%s'", h.hash))
+
+	go func() {
+		for range watchConfig(ctx, h.logger, h.reloader.Files()) {
+			h.reload(ctx)
+		}
+	}()
+
+	genCtx, cancel := context.WithCancel(ctx)
+	h.build(genCtx, cfg, func(_ context.Context, cfg config.ServiceConfig, handler http.Handler) error {
+		h.handler.swap(handler, cancel)
+		// the server lives as long as the gateway, not as long as the first router
+		return h.run(ctx, cfg, h.handler)
+	})
+}
+
+func (h *hotReloader) reload(ctx context.Context) {
+	cfg, err := h.reloader.Reload()
+	if err != nil {
+		h.logger.Error("config reload:", err.Error())
+		return
+	}
+
+	// the listener and the command line flags outlive the reloads
+	if cfg.Port != h.cfg.Port {
+		h.logger.Warning("config reload: the port can not be changed without a restart")
+		cfg.Port = h.cfg.Port
+	}
+	cfg.Debug = cfg.Debug || h.cfg.Debug
+	if ns := listener.Linter.Namespace; !reflect.DeepEqual(cfg.ExtraConfig[ns], h.cfg.ExtraConfig[ns]) {
+		h.logger.Warning("config reload: the listeners can not be changed without a restart")
+		keepExtraConfig(&cfg, h.cfg, ns)
+	}
+	// the certificate files are reloaded by the listener, but not the list of certificates
+	if ns := certs.Linter.Namespace; !reflect.DeepEqual(cfg.ExtraConfig[ns], h.cfg.ExtraConfig[ns]) {
+		h.logger.Warning("config reload: the certificates can not be changed without a restart")
+		keepExtraConfig(&cfg, h.cfg, ns)
+	}
+
+	hash, err := cfg.Hash()
+	if err != nil {
+		h.logger.Error("config reload: unable to hash the service configuration:", err.Error())
+		return
+	}
+	if hash == h.hash {
+		h.logger.Debug("config reload: no changes")
+		return
+	}
+	if !lintConfig(cfg, h.registry, h.logger) {
+		h.logger.Error("config reload: keeping the running configuration")
+		return
+	}
+
+	genCtx, cancel := context.WithCancel(ctx)
+	swapped := false
+	func() {
+		defer func() {
+			if r := recover(); r != nil {
+				h.logger.Error("config reload: unable to build the router:", r)
+			}
+		}()
+		h.build(genCtx, cfg, func(_ context.Context, _ config.ServiceConfig, handler http.Handler) error {
+			h.handler.swap(handler, cancel)
+			swapped = true
+			return nil
+		})
+	}()
+	if !swapped {
+		cancel()
+		return
+	}
+
+	h.cfg = cfg
+	h.hash = hash
+	h.logger.Info(fmt.Sprintf("config reloaded, config hash '%s'", hash))
+}
+
+// keepExtraConfig replaces the namespace of the config with the one of the previous config
+func keepExtraConfig(cfg *config.ServiceConfig, prev config.ServiceConfig, namespace string) {
+	v, ok := prev.ExtraConfig[namespace]
+	if !ok {
+		delete(cfg.ExtraConfig, namespace)
+		return
+	}
+	if cfg.ExtraConfig == nil {
+		cfg.ExtraConfig = config.ExtraConfig{}
+	}
+	cfg.ExtraConfig[namespace] = v
+}
+
+// swappableHandler serves every request with the current router. The replaced routers are
+// released once their in-flight requests are done.
+type swappableHandler struct {
+	logger  logging.Logger
+	timeout time.Duration
+
+	mu      sync.RWMutex
+	current *generation
+}
+
+// generation is a router along with the context of the components created for it
+type generation struct {
+	handler http.Handler
+	cancel  context.CancelFunc
+	wg      sync.WaitGroup
+}
+
+func (s *swappableHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.RLock()
+	g := s.current
+	if g != nil {
+		g.wg.Add(1)
+	}
+	s.mu.RUnlock()
+
+	if g == nil {
+		http.Error(w, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+		return
+	}
+	defer g.wg.Done()
+
+	g.handler.ServeHTTP(w, r)
+}
+
+func (s *swappableHandler) swap(h http.Handler, cancel context.CancelFunc) {
+	next := &generation{handler: h, cancel: cancel}
+
+	s.mu.Lock()
+	prev := s.current
+	s.current = next
+	s.mu.Unlock()
+
+	if prev != nil {
+		go s.drain(prev)
+	}
+}
+
+func (s *swappableHandler) drain(g *generation) {
+	done := make(chan struct{})
+	go func() {
+		g.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(s.timeout):
+		s.logger.Warning("config reload: releasing the previous router with requests still in flight")
+	}
+	g.cancel()
+}
+
+// watchConfig notifies every SIGHUP and every change of the given files. Folders are watched
+// as a whole. The notifications stop when the context is cancelled.
+func watchConfig(ctx context.Context, l logging.Logger, files []string) <-chan struct{} {
+	out := make(chan struct{}, 1)
+	notify := func() {
+		select {
+		case out <- struct{}{}:
+		default:
+		}
+	}
+
+	sighup := make(chan os.Signal, 1)
+	signal.Notify(sighup, syscall.SIGHUP)
+
+	events, errs := watchFiles(ctx, l, files)
+
+	go func() {
+		defer close(out)
+		defer signal.Stop(sighup)
+
+		var debounce <-chan time.Time
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-sighup:
+				l.Info("config reload: SIGHUP received")
+				notify()
+			case _, ok := <-events:
+				if !ok {
+					events = nil
+					continue
+				}
+				debounce = time.After(reloadDebounce)
+			case <-debounce:
+				debounce = nil
+				l.Info("config reload: configuration files changed")
+				notify()
+			case err, ok := <-errs:
+				// the watcher closes its channels once the context is cancelled
+				if !ok {
+					errs = nil
+					continue
+				}
+				l.Warning("config reload: watcher:", err.Error())
+			}
+		}
+	}()
+
+	return out
+}
+
+// watchFiles returns the events of the given files and folders. Files are watched through their
+// folder, so editors and orchestrators replacing the file instead of writing it are noticed.
+func watchFiles(ctx context.Context, l logging.Logger, files []string) (<-chan string, <-chan error) {
+	if len(files) == 0 {
+		return nil, nil
+	}
+
+	w, err := fsnotify.NewWatcher()
+	if err != nil {
+		l.Warning("config reload: unable to watch the configuration files:", err.Error())
+		return nil, nil
+	}
+
+	folders := map[string]bool{}
+	names := map[string]bool{}
+	for _, f := range files {
+		f = filepath.Clean(f)
+		info, err := os.Stat(f)
+		if err != nil {
+			l.Warning("config reload: unable to watch", f, err.Error())
+			continue
+		}
+		dir := f
+		if !info.IsDir() {
+			dir = filepath.Dir(f)
+			names[f] = true
+		} else {
+			folders[f] = true
+		}
+		if err := w.Add(dir); err != nil {
+			l.Warning("config reload: unable to watch", dir, err.Error())
+		}
+	}
+
+	go func() {
+		<-ctx.Done()
+		w.Close()
+	}()
+
+	events := make(chan string)
+	go func() {
+		defer close(events)
+		for e := range w.Events {
+			name := filepath.Clean(e.Name)
+			if e.Op == fsnotify.Chmod || !(names[name] || folders[filepath.Dir(name)]) {
+				continue
+			}
+			select {
+			case events <- name:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return events, w.Errors
+}
@@ -0,0 +1,290 @@
+package krakend
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/devopsfaith/krakend-ce/ext/chain"
+	"github.com/devopsfaith/krakend-ce/ext/keyauth"
+	"github.com/devopsfaith/krakend/config"
+	"github.com/devopsfaith/krakend/logging"
+	router "github.com/devopsfaith/krakend/router/gin"
+)
+
+var testLogger, _ = logging.NewLogger("CRITICAL", ioutil.Discard, "")
+
+type fakeReloader struct {
+	mu  sync.Mutex
+	cfg config.ServiceConfig
+	err error
+}
+
+func (f *fakeReloader) Reload() (config.ServiceConfig, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.cfg, f.err
+}
+
+func (f *fakeReloader) Files() []string { return nil }
+
+func (f *fakeReloader) set(cfg config.ServiceConfig) {
+	f.mu.Lock()
+	f.cfg = cfg
+	f.mu.Unlock()
+}
+
+// reloadTest runs a hot reloader whose routers answer with the name of their config. The requests
+// to /slow block until release is closed
+type reloadTest struct {
+	reloader *fakeReloader
+	hot      *hotReloader
+	server   http.Handler
+	started  chan struct{}
+	release  chan struct{}
+
+	mu     sync.Mutex
+	builds []context.Context
+}
+
+func newReloadTest(t *testing.T, ctx context.Context, build func(config.ServiceConfig)) *reloadTest {
+	rt := &reloadTest{
+		reloader: &fakeReloader{},
+		started:  make(chan struct{}, 1),
+		release:  make(chan struct{}),
+	}
+	served := make(chan http.Handler)
+
+	registry := DefaultMiddlewareRegistry()
+	registry.AddHandlerMiddleware(testHandlerMiddleware("audit", 1500, true))
+	rt.hot = newHotReloader(testLogger, rt.reloader, registry, func(ctx context.Context, cfg config.ServiceConfig, run router.RunServerFunc) {
+		rt.mu.Lock()
+		rt.builds = append(rt.builds, ctx)
+		rt.mu.Unlock()
+		if build != nil {
+			build(cfg)
+		}
+
+		name := cfg.Name
+		run(ctx, cfg, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/slow" {
+				rt.started <- struct{}{}
+				<-rt.release
+			}
+			w.Write([]byte(name))
+		}))
+	}, func(ctx context.Context, _ config.ServiceConfig, h http.Handler) error {
+		served <- h
+		<-ctx.Done()
+		return nil
+	})
+
+	go rt.hot.Run(ctx, config.ServiceConfig{Name: "v1"})
+	select {
+	case rt.server = <-served:
+	case <-time.After(time.Second):
+		t.Fatal("the server should start with the first router")
+	}
+	return rt
+}
+
+func (rt *reloadTest) get(path string) string {
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "http://localhost"+path, nil)
+	rt.server.ServeHTTP(w, req)
+	return w.Body.String()
+}
+
+func (rt *reloadTest) build(i int) context.Context {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	return rt.builds[i]
+}
+
+func (rt *reloadTest) buildCount() int {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	return len(rt.builds)
+}
+
+func TestHotReloader_reload(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	rt := newReloadTest(t, ctx, nil)
+
+	slow := make(chan string)
+	go func() { slow <- rt.get("/slow") }()
+	<-rt.started
+
+	rt.reloader.set(config.ServiceConfig{Name: "v2"})
+	rt.hot.reload(ctx)
+
+	if res := rt.get("/"); res != "v2" {
+		t.Errorf("the new router should serve the requests, got %q", res)
+	}
+	if rt.hot.cfg.Name != "v2" {
+		t.Errorf("unexpected running config: %s", rt.hot.cfg.Name)
+	}
+	select {
+	case <-rt.build(0).Done():
+		t.Error("the previous router should be kept while its requests are in flight")
+	default:
+	}
+
+	close(rt.release)
+	if res := <-slow; res != "v1" {
+		t.Errorf("the in-flight request should be served by the previous router, got %q", res)
+	}
+	select {
+	case <-rt.build(0).Done():
+	case <-time.After(time.Second):
+		t.Error("the previous router should be released once its requests are done")
+	}
+	select {
+	case <-rt.build(1).Done():
+		t.Error("the new router should not be released")
+	default:
+	}
+}
+
+func TestHotReloader_reloadUnchanged(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	rt := newReloadTest(t, ctx, nil)
+
+	rt.reloader.set(config.ServiceConfig{Name: "v1"})
+	rt.hot.reload(ctx)
+
+	if n := rt.buildCount(); n != 1 {
+		t.Errorf("an unchanged config should not build a router, %d builds", n)
+	}
+	if res := rt.get("/"); res != "v1" {
+		t.Errorf("unexpected router: %q", res)
+	}
+}
+
+func TestHotReloader_reloadFailed(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	rt := newReloadTest(t, ctx, func(cfg config.ServiceConfig) {
+		if cfg.Name == "broken" {
+			panic("unable to build")
+		}
+	})
+
+	invalid := config.ServiceConfig{
+		Name: "invalid",
+		Endpoints: []*config.EndpointConfig{
+			{Endpoint: "/private", ExtraConfig: config.ExtraConfig{keyauth.Linter.Namespace: map[string]interface{}{}}},
+		},
+	}
+	for _, cfg := range []config.ServiceConfig{{Name: "broken"}, invalid} {
+		builds := rt.buildCount()
+		rt.reloader.set(cfg)
+		rt.hot.reload(ctx)
+
+		if res := rt.get("/"); res != "v1" {
+			t.Errorf("%s: the previous router should keep serving, got %q", cfg.Name, res)
+		}
+		if rt.hot.cfg.Name != "v1" {
+			t.Errorf("%s: unexpected running config: %s", cfg.Name, rt.hot.cfg.Name)
+		}
+		if n := rt.buildCount(); n > builds {
+			select {
+			case <-rt.build(n - 1).Done():
+			default:
+				t.Errorf("%s: the components of the failed build should be released", cfg.Name)
+			}
+		}
+	}
+	if n := rt.buildCount(); n != 2 {
+		t.Errorf("the invalid config should not be built, %d builds", n)
+	}
+
+	rt.reloader.mu.Lock()
+	rt.reloader.err = errors.New("unable to parse")
+	rt.reloader.mu.Unlock()
+	rt.hot.reload(ctx)
+	if res := rt.get("/"); res != "v1" {
+		t.Errorf("the previous router should keep serving when the config can not be parsed, got %q", res)
+	}
+}
+
+func TestHotReloader_reloadCustomMiddleware(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	rt := newReloadTest(t, ctx, nil)
+
+	// the audit middleware is only registered in the registry of the executor
+	rt.reloader.set(config.ServiceConfig{Name: "v2", ExtraConfig: config.ExtraConfig{
+		chain.Linter.Namespace: map[string]interface{}{
+			chain.StackHandler: map[string]interface{}{"after": map[string]interface{}{"audit": "transform"}},
+		},
+	}})
+	rt.hot.reload(ctx)
+
+	if res := rt.get("/"); res != "v2" {
+		t.Errorf("the config placing a middleware of the executor registry should be loaded, got %q", res)
+	}
+}
+
+func TestSwappableHandler_drainTimeout(t *testing.T) {
+	s := &swappableHandler{logger: testLogger, timeout: 10 * time.Millisecond}
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "http://localhost/", nil)
+	s.ServeHTTP(w, req)
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("unexpected status without router: %d", w.Code)
+	}
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	defer close(release)
+	released := make(chan struct{})
+	s.swap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+	}), func() { close(released) })
+
+	go s.ServeHTTP(httptest.NewRecorder(), req)
+	<-started
+
+	s.swap(http.NotFoundHandler(), func() {})
+	select {
+	case <-released:
+	case <-time.After(time.Second):
+		t.Error("the previous router should be released after the drain timeout")
+	}
+
+	w = httptest.NewRecorder()
+	s.ServeHTTP(w, req)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("the new router should serve the requests, got %d", w.Code)
+	}
+}
+
+func TestWatchConfig_cancel(t *testing.T) {
+	dir, err := ioutil.TempDir("", "krakend-reload")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	for i := 0; i < 20; i++ {
+		ctx, cancel := context.WithCancel(context.Background())
+		out := watchConfig(ctx, testLogger, []string{dir})
+		cancel()
+		select {
+		case <-out:
+		case <-time.After(time.Second):
+			t.Fatal("the notifications should stop once the context is cancelled")
+		}
+	}
+}
@@ -4,6 +4,11 @@ import (
 	"io"
 
 	botdetector "github.com/devopsfaith/krakend-botdetector/gin"
+	"github.com/devopsfaith/krakend-ce/ext/bodylimit"
+	cacheadmin "github.com/devopsfaith/krakend-ce/ext/cacheadmin"
+	"github.com/devopsfaith/krakend-ce/ext/health"
+	"github.com/devopsfaith/krakend-ce/ext/introspect"
+	"github.com/devopsfaith/krakend-ce/ext/listener"
 	httpsecure "github.com/devopsfaith/krakend-httpsecure/gin"
 	lua "github.com/devopsfaith/krakend-lua/router/gin"
 	"github.com/devopsfaith/krakend/config"
@@ -13,6 +18,12 @@ import (
 
 // NewEngine creates a new gin engine with some default values and a secure middleware
 func NewEngine(cfg config.ServiceConfig, logger logging.Logger, w io.Writer) *gin.Engine {
+	return defaultStacks().NewEngine(cfg, logger, w)
+}
+
+// NewEngine creates a new gin engine with some default values and registers the engine stack hooks.
+// The introspection endpoint is registered last, reporting the stacks.
+func (s *MiddlewareStacks) NewEngine(cfg config.ServiceConfig, logger logging.Logger, w io.Writer) *gin.Engine {
 	if !cfg.Debug {
 		gin.SetMode(gin.ReleaseMode)
 	}
@@ -24,19 +35,55 @@ func NewEngine(cfg config.ServiceConfig, logger logging.Logger, w io.Writer) *gi
 	engine.RedirectFixedPath = true
 	engine.HandleMethodNotAllowed = true
 
-	if err := httpsecure.Register(cfg.ExtraConfig, engine); err != nil {
-		logger.Warning(err)
+	for _, h := range s.engine {
+		h.Register(cfg, logger, engine)
 	}
 
-	lua.Register(logger, cfg.ExtraConfig, engine)
-
-	botdetector.Register(cfg, logger, engine)
+	introspect.Register(cfg, s.Chains(), logger, engine)
 
 	return engine
 }
 
-type engineFactory struct{}
-
-func (e engineFactory) NewEngine(cfg config.ServiceConfig, l logging.Logger, w io.Writer) *gin.Engine {
-	return NewEngine(cfg, l, w)
+// engineHooks are the bundled engine hooks in their default order
+var engineHooks = []EngineHook{
+	{
+		// the listener filter goes first, so it covers the routes of every other hook
+		Middleware: Middleware{Name: "listeners", Namespaces: []string{listener.Linter.Namespace}, Priority: 0},
+		Register:   listener.Register,
+	},
+	{
+		// the probes answer before the security and bot detection middlewares
+		Middleware: Middleware{Name: "health", Priority: 100},
+		Register:   health.Register,
+	},
+	{
+		Middleware: Middleware{Name: "httpsecure", Namespaces: []string{"github_com/devopsfaith/krakend-httpsecure"}, Priority: 200},
+		Register: func(cfg config.ServiceConfig, l logging.Logger, engine *gin.Engine) {
+			if err := httpsecure.Register(cfg.ExtraConfig, engine); err != nil {
+				l.Warning(err)
+			}
+		},
+	},
+	{
+		Middleware: Middleware{Name: "lua", Namespaces: []string{"github.com/devopsfaith/krakend-lua/router"}, Priority: 300},
+		Register: func(cfg config.ServiceConfig, l logging.Logger, engine *gin.Engine) {
+			lua.Register(l, cfg.ExtraConfig, engine)
+		},
+	},
+	{
+		Middleware: Middleware{Name: "botdetector", Namespaces: []string{"github_com/devopsfaith/krakend-botdetector"}, Priority: 400},
+		Register: func(cfg config.ServiceConfig, l logging.Logger, engine *gin.Engine) {
+			botdetector.Register(cfg, l, engine)
+		},
+	},
+	{
+		Middleware: Middleware{Name: "bodylimit", Namespaces: []string{bodylimit.Linter.Namespace}, Priority: 500},
+		Register:   bodylimit.Register,
+	},
+	{
+		Middleware: Middleware{Name: "cacheadmin", Namespaces: []string{cacheadmin.Linter.Namespace}, Priority: 600},
+		Register: func(cfg config.ServiceConfig, l logging.Logger, engine *gin.Engine) {
+			cacheadmin.Register(cfg.ExtraConfig, l, engine)
+		},
+	},
 }
@@ -2,8 +2,11 @@ package krakend
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"strings"
 
+	"github.com/devopsfaith/krakend-ce/ext/health"
 	consul "github.com/devopsfaith/krakend-consul"
 	"github.com/devopsfaith/krakend-etcd"
 	"github.com/devopsfaith/krakend/config"
@@ -19,10 +22,11 @@ func RegisterSubscriberFactories(ctx context.Context, cfg config.ServiceConfig,
 	if err != nil {
 		logger.Warning("building the etcd client:", err.Error())
 	}
-	sd.RegisterSubscriberFactory("etcd", etcd.SubscriberFactory(ctx, etcdClient))
+	sd.RegisterSubscriberFactory("etcd", checkedSubscriberFactory(ctx, "etcd", etcd.SubscriberFactory(ctx, etcdClient)))
 
 	// register the dns service discovery
 	dnssrv.Register()
+	sd.RegisterSubscriberFactory(dnssrv.Namespace, checkedSubscriberFactory(ctx, dnssrv.Namespace, dnssrv.SubscriberFactory))
 
 	return func(name string, port int) {
 		if err := consul.Register(ctx, cfg.ExtraConfig, port, name, logger); err != nil {
@@ -33,6 +37,25 @@ func RegisterSubscriberFactories(ctx context.Context, cfg config.ServiceConfig,
 	}
 }
 
+// checkedSubscriberFactory adds a readiness check for every created subscriber, failing while
+// the subscriber resolves no hosts
+func checkedSubscriberFactory(ctx context.Context, name string, sf sd.SubscriberFactory) sd.SubscriberFactory {
+	return func(cfg *config.Backend) sd.Subscriber {
+		s := sf(cfg)
+		health.RegisterCheck(ctx, fmt.Sprintf("sd:%s:%s", name, strings.Join(cfg.Host, ",")), func(context.Context) error {
+			hosts, err := s.Hosts()
+			if err != nil {
+				return err
+			}
+			if len(hosts) == 0 {
+				return errors.New("no hosts resolved")
+			}
+			return nil
+		})
+		return s
+	}
+}
+
 type registerSubscriberFactories struct{}
 
 func (d registerSubscriberFactories) Register(ctx context.Context, cfg config.ServiceConfig, logger logging.Logger) func(n string, p int) {
@@ -0,0 +1,67 @@
+package krakend
+
+import (
+	"context"
+	"time"
+
+	"github.com/devopsfaith/krakend-ce/ext/certs"
+	metrics "github.com/devopsfaith/krakend-metrics/gin"
+	gometrics "github.com/rcrowley/go-metrics"
+	"go.opencensus.io/stats"
+	"go.opencensus.io/stats/view"
+	"go.opencensus.io/tag"
+)
+
+const certificateStatsInterval = time.Minute
+
+var (
+	certificateKey = tag.MustNewKey("krakend.io/tls/certificate")
+
+	certificateExpiry = stats.Int64("krakend.io/tls/certificate_expiry", "Seconds until the served certificate expires", stats.UnitSeconds)
+
+	// CertificateOpenCensusViews are the OpenCensus views exposing the expiry of the served certificates
+	CertificateOpenCensusViews = []*view.View{
+		{
+			Name:        certificateExpiry.Name(),
+			Description: certificateExpiry.Description(),
+			Measure:     certificateExpiry,
+			Aggregation: view.LastValue(),
+			TagKeys:     []tag.Key{certificateKey},
+		},
+	}
+)
+
+// publishCertificateExpiry periodically records the seconds left until the certificates served by
+// the certs module expire, both into the metrics collector registry and as OpenCensus measurements
+func publishCertificateExpiry(ctx context.Context, metricCollector *metrics.Metrics, interval time.Duration) {
+	var registry gometrics.Registry
+	if metricCollector != nil && metricCollector.Registry != nil {
+		registry = *metricCollector.Registry
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		now := time.Now()
+		for name, notAfter := range certs.Expiry() {
+			left := int64(notAfter.Sub(now) / time.Second)
+
+			if registry != nil {
+				gometrics.GetOrRegisterGauge("tls."+name+".expiry_seconds", registry).Update(left)
+			}
+
+			tctx, err := tag.New(ctx, tag.Upsert(certificateKey, name))
+			if err != nil {
+				continue
+			}
+			stats.Record(tctx, certificateExpiry.M(left))
+		}
+	}
+}